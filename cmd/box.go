@@ -0,0 +1,24 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"github.com/mas2020-golang/cryptex/cmd/box"
+	"github.com/spf13/cobra"
+)
+
+func newBoxCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "box",
+		Short: "Manage the boxes",
+		Long:  `Manage the boxes: the members who can open a shared box, the recovery shares, the layout and the
+policy.`,
+	}
+	c.AddCommand(box.NewMemberCmd())
+	c.AddCommand(box.NewRecoveryCmd())
+	c.AddCommand(box.NewConvertCmd())
+	c.AddCommand(box.NewPolicyCmd())
+
+	return c
+}
@@ -0,0 +1,70 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+*/
+package box
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mas2020-golang/cryptex/packages/utils"
+	"github.com/mas2020-golang/cryptex/pkg/vault"
+	"github.com/mas2020-golang/goutils/output"
+	"github.com/spf13/cobra"
+)
+
+// NewConvertCmd creates and returns the convert command
+func NewConvertCmd() *cobra.Command {
+	var boxName, layout string
+	c := &cobra.Command{
+		Use:   "convert",
+		Args:  cobra.NoArgs,
+		Short: "Convert the box to another layout or to the protobuf encoding",
+		Long: `Convert the box to another layout and to the protobuf encoding. The boxes written by
+the previous versions are encoded with YAML: they are migrated to protobuf by convert or
+by any change saved. The single layout seals the whole box at once.
+The indexed layout seals the index of the box (names, tags and metadata) and every
+secret separately: listing the box doesn't decrypt the passwords, a secret is
+decrypted only when it's accessed and a save encrypts again only the changed secrets.
+The password, the members and the key file of the box don't change.`,
+		Example: `$ raptor box convert --box team
+$ raptor box convert --box team --layout indexed
+$ raptor box convert --box team --layout single`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			layout, err := convert(cmd.Context(), boxName, layout)
+			if err != nil {
+				return err
+			}
+			utils.Success(output.BoldS(fmt.Sprintf("box converted to the %s layout and the protobuf encoding", layout)))
+			return nil
+		},
+	}
+	c.Flags().StringVarP(&boxName, "box", "b", "", "The name of the box")
+	c.Flags().StringVarP(&layout, "layout", "l", "", "The layout of the box: single or indexed (default the current one)")
+
+	return c
+}
+
+// convert writes the box in the layout with the protobuf encoding and returns
+// the layout
+func convert(ctx context.Context, boxName, layout string) (string, error) {
+	if len(layout) > 0 {
+		if err := vault.ValidLayout(layout); err != nil {
+			return "", err
+		}
+	}
+	v, err := utils.OpenBox(ctx, boxName, "")
+	if err != nil {
+		return "", err
+	}
+	defer utils.CloseBox(ctx, v)
+	box := v.Box()
+	if len(layout) == 0 {
+		layout = box.Layout
+	}
+	if box.Layout == layout && box.Encoding == vault.EncodingProto {
+		return "", fmt.Errorf("the box %s has already the %s layout and the protobuf encoding", box.Name, layout)
+	}
+	box.Layout = layout
+	return layout, v.Save(ctx)
+}
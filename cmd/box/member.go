@@ -0,0 +1,179 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+*/
+package box
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mas2020-golang/cryptex/packages/utils"
+	"github.com/mas2020-golang/cryptex/pkg/vault"
+	"github.com/mas2020-golang/goutils/output"
+	"github.com/spf13/cobra"
+)
+
+// defaultMember is the name of the first member when the box has no owner
+const defaultMember = "owner"
+
+// NewMemberCmd creates and returns the member command
+func NewMemberCmd() *cobra.Command {
+	var boxName string
+	c := &cobra.Command{
+		Use:   "member",
+		Short: "Manage the members of a shared box",
+		Long: `A shared box is sealed with a random data key wrapped separately for every member:
+each member opens it with their own password or age secret key, nobody shares a password.
+Adding the first member converts the box: the current password becomes the one of the
+owner member. Removing a member rotates the data key.`,
+	}
+	c.PersistentFlags().StringVarP(&boxName, "box", "b", "", "The name of the box")
+
+	var recipient string
+	add := &cobra.Command{
+		Use:   "add <NAME>",
+		Args:  cobra.ExactArgs(1),
+		Short: "Add a member to the box",
+		Long: `Add a member to the box. The member opens the box with the password asked now,
+or with the age secret key matching the public key given with --recipient
+(see 'raptor keygen'): the secret key can be typed at the password prompt.`,
+		Example: `$ raptor box member add alice --box team
+$ raptor box member add bob --box team --recipient age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := addMember(cmd.Context(), boxName, args[0], recipient); err != nil {
+				return err
+			}
+			utils.Success(output.BoldS(fmt.Sprintf("member %s added", args[0])))
+			return nil
+		},
+	}
+	add.Flags().StringVarP(&recipient, "recipient", "r", "", "The age public key of the member (default ask for a password)")
+
+	remove := &cobra.Command{
+		Use:     "remove <NAME>",
+		Aliases: []string{"rm"},
+		Args:    cobra.ExactArgs(1),
+		Short:   "Remove a member from the box",
+		Long: `Remove a member from the box and rotate the data key: the member can't open the
+new versions of the box anymore. A copy of the box taken before the removal can still be
+opened, change the secrets the member knows.`,
+		Example: `$ raptor box member remove alice --box team`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := removeMember(cmd.Context(), boxName, args[0]); err != nil {
+				return err
+			}
+			utils.Success(output.BoldS(fmt.Sprintf("member %s removed, data key rotated", args[0])))
+			return nil
+		},
+	}
+
+	list := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Args:    cobra.NoArgs,
+		Short:   "List the members of the box",
+		Example: `$ raptor box member list --box team`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return listMembers(cmd.Context(), boxName)
+		},
+	}
+
+	c.AddCommand(add, remove, list)
+	return c
+}
+
+// addMember adds the member converting the box to a shared box if needed
+func addMember(ctx context.Context, boxName, name, recipient string) error {
+	v, err := utils.OpenBox(ctx, boxName, "")
+	if err != nil {
+		return err
+	}
+	defer utils.CloseBox(ctx, v)
+	box := v.Box()
+	if err := share(ctx, v); err != nil {
+		return err
+	}
+	if box.Keyring.Member(name) != nil {
+		return fmt.Errorf("the member %s already exists", name)
+	}
+
+	if len(recipient) > 0 {
+		err = box.Keyring.AddRecipientMember(name, recipient)
+	} else {
+		var pwd string
+		if pwd, err = utils.AskForPassword(fmt.Sprintf("Password for %s: ", name), true); err != nil {
+			return err
+		}
+		memberKey := []byte(pwd)
+		if box.KeyFile {
+			if memberKey, err = utils.WithKeyFile(memberKey); err != nil {
+				return err
+			}
+		}
+		err = box.Keyring.AddPassphraseMember(name, memberKey)
+		clear(memberKey)
+	}
+	if err != nil {
+		return err
+	}
+	return v.Save(ctx)
+}
+
+// share converts the box into a shared box, the password opening it becomes
+// the one of the owner member
+func share(ctx context.Context, v *vault.Vault) error {
+	owner := v.Box().Owner
+	if len(owner) == 0 {
+		owner = defaultMember
+	}
+	shared, err := v.Share(ctx, owner)
+	if err != nil || !shared {
+		return err
+	}
+	output.Warning("", fmt.Sprintf("the box is now shared, your password belongs to the member %s", owner))
+	return nil
+}
+
+func removeMember(ctx context.Context, boxName, name string) error {
+	v, err := utils.OpenBox(ctx, boxName, "")
+	if err != nil {
+		return err
+	}
+	defer utils.CloseBox(ctx, v)
+	box := v.Box()
+	if box.Keyring == nil {
+		return fmt.Errorf("the box is not shared, it has no members")
+	}
+	if err := box.Keyring.RemoveMember(name); err != nil {
+		return err
+	}
+	return v.Save(ctx)
+}
+
+func listMembers(ctx context.Context, boxName string) error {
+	v, err := utils.OpenBox(ctx, boxName, "")
+	if err != nil {
+		return err
+	}
+	defer utils.CloseBox(ctx, v)
+	box := v.Box()
+	if box.Keyring == nil {
+		return fmt.Errorf("the box is not shared, it has no members")
+	}
+	fmt.Printf("%-20s%-12s%s\n", "NAME", "TYPE", "RECIPIENT")
+	for _, m := range box.Keyring.Members {
+		kind := "key"
+		switch {
+		case m.IsPassphrase():
+			kind = "password"
+		case m.Recovery:
+			kind = "recovery"
+		}
+		name := m.Name
+		if name == box.Keyring.Unlocked() {
+			name += " (you)"
+		}
+		fmt.Printf("%-20s%-12s%s\n", name, kind, m.Recipient)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+*/
+package box
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+
+	"github.com/mas2020-golang/cryptex/packages/utils"
+	"github.com/mas2020-golang/cryptex/pkg/vault"
+	"github.com/mas2020-golang/goutils/output"
+	"github.com/spf13/cobra"
+)
+
+// NewPolicyCmd creates and returns the policy command
+func NewPolicyCmd() *cobra.Command {
+	var boxName string
+	c := &cobra.Command{
+		Use:   "policy",
+		Short: "Manage the policy of a box",
+		Long: `The policy of a box marks the secrets that are not exportable: their values are never
+given by 'raptor serve' and written by 'raptor export'. The secrets are matched by name
+with patterns ('*' matches any sequence of characters, '?' any single character).`,
+	}
+	c.PersistentFlags().StringVarP(&boxName, "box", "b", "", "The name of the box")
+
+	add := &cobra.Command{
+		Use:   "add <PATTERN>",
+		Args:  cobra.ExactArgs(1),
+		Short: "Mark the secrets matching the pattern as not exportable",
+		Example: `$ raptor box policy add 'prod-*' --box team
+$ raptor box policy add bank --box personal`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := addPolicy(cmd.Context(), boxName, args[0]); err != nil {
+				return err
+			}
+			utils.Success(output.BoldS(fmt.Sprintf("the secrets matching %s are not exportable", args[0])))
+			return nil
+		},
+	}
+
+	remove := &cobra.Command{
+		Use:     "remove <PATTERN>",
+		Aliases: []string{"rm"},
+		Args:    cobra.ExactArgs(1),
+		Short:   "Remove a pattern from the policy",
+		Example: `$ raptor box policy remove 'prod-*' --box team`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := removePolicy(cmd.Context(), boxName, args[0]); err != nil {
+				return err
+			}
+			utils.Success(output.BoldS(fmt.Sprintf("pattern %s removed", args[0])))
+			return nil
+		},
+	}
+
+	list := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Args:    cobra.NoArgs,
+		Short:   "List the patterns and the secrets not exportable",
+		Example: `$ raptor box policy list --box team`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return listPolicy(cmd.Context(), boxName)
+		},
+	}
+
+	c.AddCommand(add, remove, list)
+	return c
+}
+
+func addPolicy(ctx context.Context, boxName, pattern string) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid pattern %q: %v", pattern, err)
+	}
+	v, err := utils.OpenBox(ctx, boxName, "")
+	if err != nil {
+		return err
+	}
+	defer utils.CloseBox(ctx, v)
+	box := v.Box()
+	if box.Policy == nil {
+		box.Policy = &vault.Policy{}
+	}
+	if slices.Contains(box.Policy.NotExportable, pattern) {
+		return fmt.Errorf("the pattern %s already exists", pattern)
+	}
+	box.Policy.NotExportable = append(box.Policy.NotExportable, pattern)
+	return v.Save(ctx)
+}
+
+func removePolicy(ctx context.Context, boxName, pattern string) error {
+	v, err := utils.OpenBox(ctx, boxName, "")
+	if err != nil {
+		return err
+	}
+	defer utils.CloseBox(ctx, v)
+	box := v.Box()
+	if box.Policy == nil || !slices.Contains(box.Policy.NotExportable, pattern) {
+		return fmt.Errorf("the pattern %s doesn't exist", pattern)
+	}
+	box.Policy.NotExportable = slices.DeleteFunc(box.Policy.NotExportable, func(p string) bool { return p == pattern })
+	return v.Save(ctx)
+}
+
+func listPolicy(ctx context.Context, boxName string) error {
+	v, err := utils.OpenBox(ctx, boxName, "")
+	if err != nil {
+		return err
+	}
+	defer utils.CloseBox(ctx, v)
+	box := v.Box()
+	if box.Policy == nil || len(box.Policy.NotExportable) == 0 {
+		fmt.Println("every secret is exportable")
+		return nil
+	}
+	fmt.Println(output.BoldS("patterns:"))
+	for _, p := range box.Policy.NotExportable {
+		fmt.Printf("  %s\n", p)
+	}
+	fmt.Println(output.BoldS("secrets not exportable:"))
+	for _, s := range box.Secrets {
+		if !box.Exportable(s.Name) {
+			fmt.Printf("  - %s\n", s.Name)
+		}
+	}
+	return nil
+}
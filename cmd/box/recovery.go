@@ -0,0 +1,280 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+*/
+package box
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mas2020-golang/cryptex/packages/security"
+	"github.com/mas2020-golang/cryptex/packages/utils"
+	"github.com/mas2020-golang/cryptex/pkg/vault"
+	"github.com/mas2020-golang/goutils/output"
+	"github.com/skip2/go-qrcode"
+	"github.com/spf13/cobra"
+)
+
+// recoveryMember is the name of the member whose key is split into shares
+const recoveryMember = "recovery"
+
+type splitOptions struct {
+	shares, threshold int
+	format, output    string
+}
+
+type combineOptions struct {
+	member string
+	pwdFd  int
+	pwdEnv string
+}
+
+// NewRecoveryCmd creates and returns the recovery command
+func NewRecoveryCmd() *cobra.Command {
+	var boxName string
+	c := &cobra.Command{
+		Use:   "recovery",
+		Short: "Split the recovery key of a box into shares",
+		Long: `A recovery key is added to the box as a member and split into shares given to
+different people: any threshold of them rebuilds the access to the box when the
+password is lost. A single share reveals nothing. Everything works offline.`,
+	}
+	c.PersistentFlags().StringVarP(&boxName, "box", "b", "", "The name of the box")
+
+	opts := splitOptions{}
+	split := &cobra.Command{
+		Use:   "split",
+		Args:  cobra.NoArgs,
+		Short: "Create the recovery key and print its shares",
+		Long: `Create the recovery key of the box and print its shares as text or QR codes. The box
+becomes a shared box if it's not yet. Running split again replaces the recovery key:
+the old shares can't open the new versions of the box anymore.`,
+		Example: `$ raptor box recovery split --box team --shares 5 --threshold 3
+$ raptor box recovery split --box team --format qr --output /media/usb/shares`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return splitRecovery(cmd.Context(), boxName, opts)
+		},
+	}
+	split.Flags().IntVarP(&opts.shares, "shares", "n", 5, "The number of shares")
+	split.Flags().IntVarP(&opts.threshold, "threshold", "t", 3, "The number of shares needed to rebuild the key")
+	split.Flags().StringVarP(&opts.format, "format", "f", "text", "The format of the shares: text or qr")
+	split.Flags().StringVarP(&opts.output, "output", "o", "", "Write every share into a file in this folder instead of printing it")
+
+	copts := combineOptions{}
+	combine := &cobra.Command{
+		Use:   "combine",
+		Args:  cobra.NoArgs,
+		Short: "Rebuild the access to the box from the shares",
+		Long: `Read the shares from the standard input (one per line) until the threshold is
+reached, open the box with the rebuilt recovery key and ask for a new password of the
+member. The used recovery key is then removed: run split again to create new shares.
+When the shares are piped, give the new password with --pwd-fd or --pwd-env.`,
+		Example: `$ raptor box recovery combine --box team
+$ cat share-1.txt share-3.txt share-4.txt | raptor box recovery combine --box team --member alice --pwd-fd 3 3<pwd.txt`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name, err := combineRecovery(cmd.Context(), boxName, copts, os.Stdin)
+			if err != nil {
+				return err
+			}
+			utils.Success(output.BoldS(fmt.Sprintf("password of %s reset, box saved!", name)))
+			output.Warning("", "the recovery key has been used and removed, run 'raptor box recovery split' again")
+			return nil
+		},
+	}
+	combine.Flags().StringVarP(&copts.member, "member", "m", "", "The member whose password is reset (default the owner)")
+	combine.Flags().IntVar(&copts.pwdFd, "pwd-fd", -1, "Read the new password from the first line of this file descriptor")
+	combine.Flags().StringVar(&copts.pwdEnv, "pwd-env", "", "Read the new password from this environment variable")
+
+	c.AddCommand(split, combine)
+	return c
+}
+
+func splitRecovery(ctx context.Context, boxName string, opts splitOptions) error {
+	if opts.format != "text" && opts.format != "qr" {
+		return fmt.Errorf("invalid format %q: text or qr", opts.format)
+	}
+	v, err := utils.OpenBox(ctx, boxName, "")
+	if err != nil {
+		return err
+	}
+	defer utils.CloseBox(ctx, v)
+	box := v.Box()
+	if err := share(ctx, v); err != nil {
+		return err
+	}
+	identity, err := box.Keyring.SetRecovery(recoveryMember)
+	if err != nil {
+		return err
+	}
+	shares, err := security.SplitSecret([]byte(identity), opts.shares, opts.threshold)
+	if err != nil {
+		return err
+	}
+	if err := v.Save(ctx); err != nil {
+		return err
+	}
+
+	fmt.Printf("%d shares of the recovery key of %s, %d are needed to open the box:\n\n", opts.shares, box.Name, opts.threshold)
+	for i, s := range shares {
+		if err := printShare(i+1, s.String(), opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printShare prints the share or writes it into the output folder
+func printShare(n int, text string, opts splitOptions) error {
+	if len(opts.output) > 0 {
+		if err := os.MkdirAll(opts.output, 0700); err != nil {
+			return err
+		}
+		path := filepath.Join(opts.output, fmt.Sprintf("share-%d.txt", n))
+		data := []byte(text + "\n")
+		if opts.format == "qr" {
+			path = filepath.Join(opts.output, fmt.Sprintf("share-%d.png", n))
+			var err error
+			if data, err = qrcode.Encode(text, qrcode.Medium, 512); err != nil {
+				return err
+			}
+		}
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			return fmt.Errorf("failed to write the share %d: %v", n, err)
+		}
+		fmt.Printf("share %d written into %s\n", n, path)
+		return nil
+	}
+
+	fmt.Println(output.BoldS(fmt.Sprintf("Share %d:", n)))
+	if opts.format == "qr" {
+		qr, err := qrcode.New(text, qrcode.Medium)
+		if err != nil {
+			return err
+		}
+		fmt.Println(qr.ToSmallString(false))
+	}
+	fmt.Printf("%s\n\n", text)
+	return nil
+}
+
+// readShares reads the shares, one per line, until the threshold is reached
+func readShares(in io.Reader) ([]security.Share, error) {
+	var shares []security.Share
+	seen := map[byte]bool{}
+	r := bufio.NewReader(in)
+	interactive := in == os.Stdin && utils.IsTerminal(os.Stdin)
+	for {
+		if interactive {
+			fmt.Fprintf(os.Stderr, "Share %d: ", len(shares)+1)
+		}
+		line, err := r.ReadString('\n')
+		if len(strings.TrimSpace(line)) > 0 {
+			s, perr := security.ParseShare(line)
+			if perr != nil {
+				if !interactive {
+					return nil, perr
+				}
+				output.Error("", perr.Error())
+			} else if !seen[s.X] {
+				seen[s.X] = true
+				shares = append(shares, s)
+				if len(shares) >= s.Threshold {
+					return shares, nil
+				}
+			}
+		}
+		if err == io.EOF {
+			if len(shares) == 0 {
+				return nil, fmt.Errorf("no share given")
+			}
+			return nil, fmt.Errorf("%d shares given, %d are needed", len(shares), shares[0].Threshold)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// combineRecovery opens the box with the recovery key rebuilt from the shares
+// and resets the password of the member. It returns the member name.
+func combineRecovery(ctx context.Context, boxName string, opts combineOptions, in io.Reader) (string, error) {
+	// the piped shares take the standard input, the password can't be asked
+	if opts.pwdFd < 0 && len(opts.pwdEnv) == 0 && in == os.Stdin && !utils.IsTerminal(os.Stdin) &&
+		len(os.Getenv("CRYPTEX_DBGPWD")) == 0 {
+		return "", fmt.Errorf("the standard input is used for the shares, give the new password with --pwd-fd or --pwd-env")
+	}
+	shares, err := readShares(in)
+	if err != nil {
+		return "", err
+	}
+	identity, err := security.CombineShares(shares)
+	if err != nil {
+		return "", err
+	}
+	v, err := utils.OpenBox(ctx, boxName, string(identity))
+	if err != nil {
+		return "", fmt.Errorf("the shares don't open the box: %w", err)
+	}
+	defer utils.CloseBox(ctx, v)
+	box := v.Box()
+	if box.Keyring == nil {
+		return "", fmt.Errorf("the box has no recovery key")
+	}
+
+	member := opts.member
+	if len(member) == 0 {
+		member = resetMember(box)
+	}
+	pwd, err := opts.password(member)
+	if err != nil {
+		return "", err
+	}
+	memberKey := []byte(pwd)
+	if box.KeyFile {
+		if memberKey, err = utils.WithKeyFile(memberKey); err != nil {
+			return "", err
+		}
+	}
+	err = box.Keyring.ResetPassphrase(member, memberKey)
+	clear(memberKey)
+	if err != nil {
+		return "", err
+	}
+	// the shares have been revealed, they must not open the box anymore
+	if rm := box.Keyring.RecoveryMember(); rm != nil {
+		if err := box.Keyring.RemoveMember(rm.Name); err != nil {
+			return "", err
+		}
+	}
+	return member, v.Save(ctx)
+}
+
+// password returns the new password of the member from the file descriptor,
+// the env variable or the terminal
+func (o combineOptions) password(member string) (string, error) {
+	switch {
+	case o.pwdFd >= 0:
+		return utils.ReadPasswordFromFd(o.pwdFd)
+	case len(o.pwdEnv) > 0:
+		return utils.ReadPasswordFromEnv(o.pwdEnv)
+	}
+	return utils.AskForPassword(fmt.Sprintf("New password for %s: ", member), true)
+}
+
+// resetMember returns the owner, or the first member with a password
+func resetMember(box *vault.Box) string {
+	if len(box.Owner) > 0 {
+		return box.Owner
+	}
+	for _, m := range box.Keyring.Members {
+		if m.IsPassphrase() {
+			return m.Name
+		}
+	}
+	return defaultMember
+}
@@ -20,15 +20,15 @@ func newCreateCmd() *cobra.Command {
 		Long: `Add an object to raptor: you can create a box, a secret or add an item
 to an existing secret as well. (not available in interactive mode)`,
 		Run: func(cmd *cobra.Command, args []string) {
-			// if utils.BufferBox != nil {
+			// if utils.Session(cmd.Context()) != nil {
 			// 	output.Error("", "create is not available in interactive mode")
 			// 	os.Exit(1)
 			// }
 		},
 	}
 	// Here you will define your flags and configuration settings.
-	c.AddCommand(create.AddBoxCmd)
-	c.AddCommand(create.AddSecretCmd)
+	c.AddCommand(create.NewBoxCmd())
+	c.AddCommand(create.NewSecretCmd())
 	c.AddCommand(create.NewPasswordCmd())
 
 	return c
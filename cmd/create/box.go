@@ -4,56 +4,63 @@ Copyright © 2022 NAME HERE <EMAIL ADDRESS>
 package create
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"log/slog"
 	"os"
-	"path"
+	"path/filepath"
 	"time"
 
-	"github.com/mas2020-golang/cryptex/packages/security"
 	"github.com/mas2020-golang/cryptex/packages/utils"
-	"github.com/mas2020-golang/goutils/output"
+	"github.com/mas2020-golang/cryptex/pkg/vault"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v2"
 )
 
-var (
-	owner string
-	force bool
-)
-
-// boxCmd represents the box command
-var AddBoxCmd = &cobra.Command{
-	Use:     "box <NAME>",
-	Aliases: []string{"bo", "box"},
-	Args:    cobra.MinimumNArgs(1),
-	Short:   "Create a new box",
-	Long: `Create a new box and the .raptor folder structure in case
-it doesn't exist yet`,
-	Example: `$ raptor create box 'test' --owner me`,
-	Run: func(cmd *cobra.Command, args []string) {
-		if err := create(args); err != nil {
-			output.Error("", err.Error())
-			os.Exit(1)
-		}
-	},
-}
+// NewBoxCmd creates and returns the create box command
+func NewBoxCmd() *cobra.Command {
+	var (
+		owner, layout string
+		force         bool
+	)
+	c := &cobra.Command{
+		Use:     "box <NAME>",
+		Aliases: []string{"bo", "box"},
+		Args:    cobra.MinimumNArgs(1),
+		Short:   "Create a new box",
+		Long: `Create a new box and the .raptor folder structure in case
+it doesn't exist yet. With --keyfile (or RAPTOR_KEYFILE) the box can be opened only
+with both the password and the key file. The indexed layout seals every secret
+separately: listing the box doesn't decrypt the passwords and a save encrypts again
+only the changed secrets. With --store (or RAPTOR_STORE) the box is created in the store.`,
+		Example: `$ raptor create box 'test' --owner me
+$ raptor create box 'test' --keyfile /media/usb/raptor.key
+$ raptor create box 'test' --layout indexed
+$ raptor create box 'team' --store 's3://boxes?endpoint=http://localhost:9000'`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return create(args[0], owner, layout, force)
+		},
+	}
+	c.Flags().StringVarP(&owner, "owner", "o", "", "The owner of the box (e.g. --owner bar)")
+	c.Flags().BoolVarP(&force, "force", "f", false, "Create the box at the corresponding path")
+	c.Flags().StringVarP(&layout, "layout", "l", vault.LayoutSingle, "The layout of the box: single or indexed")
 
-func init() {
-	AddBoxCmd.Flags().StringVarP(&owner, "owner", "o", "", "The owner of the box (e.g. --owner bar)")
-	AddBoxCmd.Flags().BoolVarP(&force, "force", "f", false, "Create the box at the corresponding path")
+	return c
 }
 
-func create(args []string) error {
-	var err error
+func create(name, owner, layout string, force bool) error {
+	err := vault.ValidLayout(layout)
+	if err != nil {
+		return err
+	}
 	if !force {
 		if err = createHomeFolder(); err != nil {
 			return err
 		}
 	}
 
-	return createBox(args[0], owner)
+	return createBox(name, owner, layout, force)
 }
 
 func createHomeFolder() error {
@@ -68,44 +75,45 @@ func createHomeFolder() error {
 	if err != nil {
 		if os.IsNotExist(err) {
 			// create the directory structure
-			err = os.MkdirAll(boxPath, 0777)
-			utils.Check(err, "")
+			if err = os.MkdirAll(boxPath, 0777); err != nil {
+				return err
+			}
 			utils.Success(fmt.Sprintf("folder created in %s", boxPath))
 		}
 	}
 	return err
 }
 
-func createBox(name, owner string) error {
-	slog.Debug("create.createBox()", "name", name, "owner", owner)
-	var boxPath string
+func createBox(name, owner, layout string, force bool) error {
+	slog.Debug("create.createBox()", "name", name, "owner", owner, "layout", layout)
+	// the box is written in the store (the box folder by default), with
+	// --force at the given path
+	var (
+		store   vault.BoxStore
+		boxName = name
+		err     error
+	)
 	if force {
-		boxPath = name
-	}
-	// get the folder box
-	boxFolder, err := utils.InitFolderBox()
-	if err != nil {
+		store, boxName = vault.NewFileStore(filepath.Dir(name)), filepath.Base(name)
+	} else if store, err = utils.Store(); err != nil {
 		return err
 	}
-	slog.Debug(fmt.Sprintf("boxFolder is %s", boxFolder))
+	slog.Debug(fmt.Sprintf("store is %v", store))
 
-	b := utils.Box{
+	b := vault.Box{
 		Name:        name,
 		Owner:       owner,
 		Version:     "1",
 		LastUpdated: time.Now().Format(time.RFC3339),
+		Layout:      layout,
 	}
 
-	if len(boxPath) == 0 {
-		boxPath = path.Join(boxFolder, b.Name)
-	}
-
-	slog.Debug("create.createBox()", "boxPath", boxPath)
-
 	// check if the box already exists
-	slog.Debug(fmt.Sprintf("boxPath is %s", boxPath))
-	if _, err := os.Stat(boxPath); err == nil {
-		return fmt.Errorf("boxPath already exists: %v", boxPath)
+	ctx := context.Background()
+	if _, _, err := store.Read(ctx, boxName); err == nil {
+		return fmt.Errorf("the box %s already exists in %v", boxName, store)
+	} else if !errors.Is(err, vault.ErrBoxNotFound) {
+		return err
 	}
 
 	out, err := yaml.Marshal(b)
@@ -119,16 +127,16 @@ func createBox(name, owner string) error {
 	if err != nil {
 		return err
 	}
-	// encrypt the box
-	encOut, err := security.EncryptBox(out, key)
+	digest, err := utils.KeyFileDigest()
 	if err != nil {
 		return err
 	}
-	// write the box into the disk
-	err = ioutil.WriteFile(boxPath, encOut, 0644)
+	// encrypt the box and write it into the disk
+	v, err := vault.CreateIn(ctx, store, boxName, &b, vault.Key{Passphrase: key, KeyFile: digest})
 	if err != nil {
-		return fmt.Errorf("failed to write the box: %v", err)
+		return err
 	}
+	v.Close()
 	fmt.Println()
 	utils.Success(fmt.Sprintf("Box %q created successfully!", name))
 	return nil
@@ -5,50 +5,58 @@ package create
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
 	"strings"
 	"time"
 
 	"github.com/mas2020-golang/cryptex/packages/utils"
+	"github.com/mas2020-golang/cryptex/pkg/vault"
 	"github.com/mas2020-golang/goutils/output"
 	"github.com/spf13/cobra"
 )
 
-var key, boxName string
-
-// boxCmd represents the box command
-var AddSecretCmd = &cobra.Command{
-	Use:     "secret <NAME>",
-	Aliases: []string{"sr"},
-	Args:    cobra.MinimumNArgs(1),
-	Short:   "Create a new secret",
-	Long:    `Create a new secret adding the one to the existing secret for the box`,
-	Example: `$ raptor secret add 'new-secret' --box test`,
-	Run: func(cmd *cobra.Command, args []string) {
-		add(args[0])
-	},
-}
+// NewSecretCmd creates and returns the create secret command
+func NewSecretCmd() *cobra.Command {
+	var boxName string
+	c := &cobra.Command{
+		Use:     "secret <NAME>",
+		Aliases: []string{"sr"},
+		Args:    cobra.MinimumNArgs(1),
+		Short:   "Create a new secret",
+		Long:    `Create a new secret adding the one to the existing secret for the box`,
+		Example: `$ raptor secret add 'new-secret' --box test`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return add(cmd.Context(), boxName, args[0])
+		},
+	}
+	c.PersistentFlags().StringVarP(&boxName, "box", "b", "", "The name of the box where to add the secret")
 
-func init() {
-	AddSecretCmd.PersistentFlags().StringVarP(&boxName, "box", "b", "", "The name of the box where to add the secret")
+	return c
 }
 
-func add(name string) {
+func add(ctx context.Context, boxName, name string) error {
 	// open the box
-	boxPath, key, box, err := utils.OpenBox(boxName, "")
-	utils.Check(err, "")
+	v, err := utils.OpenBox(ctx, boxName, "")
+	if err != nil {
+		return err
+	}
+	defer utils.CloseBox(ctx, v)
 	// add the secret
-	err = addSecret(name, box)
-	utils.Check(err, "")
+	if err = addSecret(name, v.Box()); err != nil {
+		return err
+	}
 	fmt.Println()
 	// save the box
-	err = utils.SaveBox(boxPath, key, box)
-	utils.Check(err, "")
+	if err = v.Save(ctx); err != nil {
+		return err
+	}
 	utils.Success(output.BoldS("box saved!"))
+	return nil
 }
 
-func addSecret(name string, box *utils.Box) error {
+func addSecret(name string, box *vault.Box) error {
 	if err := search(name, box); err != nil {
 		return err
 	}
@@ -56,10 +64,10 @@ func addSecret(name string, box *utils.Box) error {
 	output.RedOut("(to exit without saving press CTRL+C)\n")
 	fmt.Println(output.GreenS(strings.Repeat("-", 35)))
 	if box.Secrets == nil {
-		box.Secrets = make([]*utils.Secret, 0)
+		box.Secrets = make([]*vault.Secret, 0)
 	}
 	// new secret
-	s := utils.Secret{}
+	s := vault.Secret{}
 	s.Name = name
 	// read from standard input
 	r := bufio.NewReader(os.Stdin)
@@ -73,11 +81,15 @@ func addSecret(name string, box *utils.Box) error {
 	s.Login = utils.GetText(r)
 	fmt.Print(output.BlueS("Password: "))
 	input, err := utils.ReadPassword("")
-	utils.Check(err, "")
+	if err != nil {
+		return err
+	}
 	if len(input) != 0 {
 		fmt.Printf("\n%s [%s]: ", output.BlueS("Confirm pwd"), output.BoldS("xxx"))
 		input2, err := utils.ReadPassword("")
-		utils.Check(err, "")
+		if err != nil {
+			return err
+		}
 		if input != input2 {
 			fmt.Println()
 			return fmt.Errorf("the pwd mismatched")
@@ -117,17 +129,15 @@ func addSecret(name string, box *utils.Box) error {
 		}
 	}
 	s.LastUpdated = time.Now().Format(time.RFC3339)
-	box.Secrets = append(box.Secrets, &s)
+	box.Put(&s)
 	return nil
 }
 
 // search goes into the secret and throws an error if a secret with the same
 // name already exists
-func search(name string, box *utils.Box) error {
-	for _, s := range box.Secrets {
-		if (*s).Name == name {
-			return fmt.Errorf("a secret with the name %s already exists", name)
-		}
+func search(name string, box *vault.Box) error {
+	if box.Secret(name) != nil {
+		return fmt.Errorf("a secret with the name %s already exists", name)
 	}
 	return nil
 }
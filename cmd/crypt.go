@@ -0,0 +1,288 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mas2020-golang/cryptex/packages/security"
+	"github.com/mas2020-golang/cryptex/packages/utils"
+	"github.com/mas2020-golang/cryptex/pkg/vault"
+	"github.com/mas2020-golang/goutils/output"
+	"github.com/spf13/cobra"
+)
+
+// stdio is the path used to refer to the standard input or output
+const stdio = "-"
+
+// cryptOptions are the options shared by the encrypt and decrypt commands
+type cryptOptions struct {
+	output      string
+	pwdFd       int
+	pwdEnv      string
+	keep, force bool
+	// archive options: the encrypt command creates one, the decrypt command
+	// applies the symlinks policy on extraction
+	archive, compress bool
+	symlinks          string
+	encryptNames      bool
+	// recipients are the age public keys to encrypt for, identities the age
+	// secret keys read from the box given with --box
+	recipients []string
+	box        string
+	identities []string
+	// wipe is the policy applied to the source files removed
+	wipe string
+	// resume and rollback complete or undo an interrupted folder encryption
+	resume, rollback bool
+	// include, exclude and the sizes select the files of a folder
+	include, exclude []string
+	minSize, maxSize string
+	min, max         int64
+	dryRun           bool
+	// ignoreManifest decrypts a folder not matching its manifest
+	ignoreManifest bool
+	// replaceManifest encrypts a folder whose manifest can't be opened
+	replaceManifest bool
+}
+
+// wipeEnv is the env variable with the default wipe policy
+const wipeEnv = "RAPTOR_WIPE"
+
+func addCryptFlags(c *cobra.Command, opts *cryptOptions) {
+	c.Flags().StringVarP(&opts.output, "output", "o", "", "The folder where to write the results mirroring the tree (- for the standard output)")
+	c.Flags().IntVar(&opts.pwdFd, "pwd-fd", -1, "Read the password from the first line of this file descriptor")
+	c.Flags().StringVar(&opts.pwdEnv, "pwd-env", "", "Read the password from this environment variable")
+	c.Flags().BoolVarP(&opts.keep, "keep", "k", false, "Keep the source files instead of wiping them")
+	c.Flags().BoolVarP(&opts.force, "force", "f", false, "Overwrite the existing destination files")
+	c.Flags().StringVar(&opts.wipe, "wipe", defaultWipe(), "The policy wiping the source files: none, zero, random or dod (default from RAPTOR_WIPE)")
+	c.Flags().StringArrayVar(&opts.include, "include", nil, "Process only the files of a folder matching this gitignore-style pattern (repeatable)")
+	c.Flags().StringArrayVar(&opts.exclude, "exclude", nil, "Skip the files of a folder matching this gitignore-style pattern (repeatable)")
+	c.Flags().StringVar(&opts.minSize, "min-size", "", "Skip the files of a folder smaller than this size (e.g. 10K)")
+	c.Flags().StringVar(&opts.maxSize, "max-size", "", "Skip the files of a folder bigger than this size (e.g. 100M)")
+	c.Flags().BoolVar(&opts.dryRun, "dry-run", false, "List the files that would be processed without touching them")
+}
+
+// parseSize parses a size in bytes with an optional K, M, G or T suffix (powers
+// of 1024), empty is 0
+func parseSize(s string) (int64, error) {
+	if len(s) == 0 {
+		return 0, nil
+	}
+	units := map[byte]int64{'K': 1 << 10, 'M': 1 << 20, 'G': 1 << 30, 'T': 1 << 40}
+	v := strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "B")
+	mult := int64(1)
+	if len(v) > 0 {
+		if m, ok := units[v[len(v)-1]]; ok {
+			mult, v = m, v[:len(v)-1]
+		}
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * mult, nil
+}
+
+// checkFilters validates the selection of the files and the links policy
+func (o *cryptOptions) checkFilters(links ...string) error {
+	var err error
+	if o.min, err = parseSize(o.minSize); err != nil {
+		return err
+	}
+	if o.max, err = parseSize(o.maxSize); err != nil {
+		return err
+	}
+	for _, l := range links {
+		if o.symlinks == l {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid --symlinks value %q (%s)", o.symlinks, strings.Join(links, ", "))
+}
+
+// dryRun prints the files that would be processed: the encrypted files for the
+// decryption, the others for the encryption
+func dryRun(path string, info os.FileInfo, opts *cryptOptions, decrypting bool) error {
+	files := []string{path}
+	if info.IsDir() {
+		var err error
+		if files, err = security.SelectFiles(path, opts.fileOptions()); err != nil {
+			return err
+		}
+	}
+	verb, n := "encrypted", 0
+	if decrypting {
+		verb = "decrypted"
+	}
+	for _, f := range files {
+		encrypted := strings.HasSuffix(f, ".enc") || security.IsAgeFile(f)
+		if encrypted == decrypting || opts.archive {
+			fmt.Println(f)
+			n++
+		}
+	}
+	utils.Success(fmt.Sprintf("%d files would be %s", n, verb))
+	return nil
+}
+
+// defaultWipe returns the wipe policy from RAPTOR_WIPE, dod if not set
+func defaultWipe() string {
+	if p := os.Getenv(wipeEnv); len(p) > 0 {
+		return p
+	}
+	return security.WipeDoD
+}
+
+// checkWipe validates the wipe policy and warns when the source files are going
+// to be overwritten on a filesystem where that can't be trusted
+func (o *cryptOptions) checkWipe(path string) error {
+	if err := security.ValidWipePolicy(o.wipe); err != nil {
+		return err
+	}
+	if !o.keep {
+		warnWipe(path, o.wipe)
+	}
+	return nil
+}
+
+// warnWipe warns when the filesystem of the path keeps the overwritten data
+// somewhere else (copy-on-write, journaling of data, network)
+func warnWipe(path, policy string) {
+	if policy == security.WipeNone {
+		return
+	}
+	if fs, untrusted := security.UntrustedFS(path); untrusted {
+		output.Warning("", fmt.Sprintf("%s is on %s: overwriting can't be trusted to destroy the old data, the files are removed anyway", path, fs))
+	}
+}
+
+// fileOptions returns the options for the security package
+func (o *cryptOptions) fileOptions() *security.Options {
+	return &security.Options{
+		OutputDir: o.output,
+		Keep:      o.keep,
+		Force:     o.force,
+		Compress:  o.compress,
+		Symlinks:  o.symlinks,
+		// the decryption always restores the names
+		EncryptNames: o.encryptNames,
+		Recipients:   o.recipients,
+		Identities:   o.identities,
+		Wipe:         o.wipe,
+		Include:      o.include,
+		Exclude:      o.exclude,
+		MinSize:      o.min,
+		MaxSize:      o.max,
+		// the decryption of a folder verifies its manifest
+		IgnoreManifest:  o.ignoreManifest,
+		ReplaceManifest: o.replaceManifest,
+	}
+}
+
+// streaming returns true when the data is read from stdin or written to stdout
+func (o *cryptOptions) streaming(path string) bool {
+	return path == stdio || o.output == stdio
+}
+
+// passphrase returns the password from the file descriptor, the env variable
+// or asking for it, combined with the key file if given. The standard input
+// can't be used to ask for the password when it brings the data to encrypt or
+// decrypt.
+func (o *cryptOptions) passphrase(path string, twice bool) (string, error) {
+	var pwd string
+	var err error
+	switch {
+	case o.pwdFd >= 0:
+		pwd, err = utils.ReadPasswordFromFd(o.pwdFd)
+	case len(o.pwdEnv) > 0:
+		pwd, err = utils.ReadPasswordFromEnv(o.pwdEnv)
+	case path == stdio && len(os.Getenv("CRYPTEX_DBGPWD")) == 0:
+		return "", fmt.Errorf("the standard input is used for the data, give the password with --pwd-fd or --pwd-env")
+	default:
+		pwd, err = utils.AskForPassword("Password: ", twice)
+	}
+	if err != nil {
+		return "", err
+	}
+	key, err := utils.WithKeyFile([]byte(pwd))
+	return string(key), err
+}
+
+// needsPassphrase returns false when the public keys are used
+func (o *cryptOptions) needsPassphrase() bool {
+	return len(o.recipients) == 0 && len(o.identities) == 0
+}
+
+// streamPaths returns the reader and the writer for the streaming mode: the
+// path is the input file or - for the standard input
+func (o *cryptOptions) streamPaths(path string) (io.ReadCloser, io.WriteCloser, error) {
+	if len(o.output) > 0 && o.output != stdio {
+		return nil, nil, fmt.Errorf("reading from the standard input the output can only be the standard output (-o -)")
+	}
+
+	var in io.ReadCloser
+	if path == stdio {
+		stdin := utils.GetBytesFromPipe()
+		if stdin == nil {
+			return nil, nil, fmt.Errorf("no data piped into the standard input")
+		}
+		in = stdin
+	} else {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error accessing the path %s: %v", path, err)
+		}
+		in = f
+	}
+	return in, os.Stdout, nil
+}
+
+// runStream encrypts or decrypts the data from the input to the standard output
+func runStream(path string, opts *cryptOptions, twice bool, transform func(io.Reader, io.Writer, string) error) error {
+	var passphrase string
+	if opts.needsPassphrase() {
+		var err error
+		if passphrase, err = opts.passphrase(path, twice); err != nil {
+			return err
+		}
+	}
+	in, out, err := opts.streamPaths(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	return transform(in, out, passphrase)
+}
+
+// streamError is the error of a stream: it is printed on the standard error,
+// the standard output is used by the data
+type streamError struct{ error }
+
+func (e streamError) Unwrap() error { return e.error }
+
+// codedError is an error of the security package with the sentinel error of
+// vault giving its exit code, the message is the one of the error
+type codedError struct{ err, code error }
+
+func (e codedError) Error() string { return e.err.Error() }
+
+func (e codedError) Unwrap() []error { return []error{e.err, e.code} }
+
+// cryptError maps the failed authentication to vault.ErrWrongPassword and the
+// truncated or invalid data to vault.ErrCorrupt
+func cryptError(err error) error {
+	switch {
+	case errors.Is(err, security.ErrAuthentication):
+		return codedError{err, vault.ErrWrongPassword}
+	case errors.Is(err, security.ErrTruncated), errors.Is(err, security.ErrFormat):
+		return codedError{err, vault.ErrCorrupt}
+	}
+	return err
+}
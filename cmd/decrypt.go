@@ -4,10 +4,13 @@ Copyright © 2022 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"path/filepath"
 
 	"github.com/mas2020-golang/cryptex/packages/security"
 	"github.com/mas2020-golang/cryptex/packages/utils"
@@ -15,37 +18,89 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var ()
-
 func newDecryptCmd() *cobra.Command {
+	opts := cryptOptions{}
 	c := &cobra.Command{
-		Use:     "decrypt <FILE|FOLDER>",
+		Use:     "decrypt <FILE|FOLDER|->",
 		Args:    cobra.MinimumNArgs(1),
-		Aliases: []string{"en", "encrypt"},
-		// Args:    cobra.MinimumNArgs(1),
-		Short: "Decrypt a file or a folder",
+		Aliases: []string{"de"},
+		Short:   "Decrypt a file or a folder",
 		Long: `The decryption is accepting a file or a folder. The command will automatically delete
-each file in in the path.`,
-		Example: `$ raptor decrypt /test/file`,
-		Run: func(cmd *cobra.Command, args []string) {
-			slog.Debug("encrypt run", "path", args[0])
-			if err := decrypt(args[0]); err != nil {
-				if !errors.Is(err, security.ErrInvalidFile) {
-					console.Error(err.Error(), true)
-					//output.Error("", err.Error())
+each file in in the path, use --keep to keep them.
+Use --output to write the decrypted files into another folder mirroring the tree: an
+existing destination file is never overwritten unless --force is given.
+
+Use - as path to decrypt the standard input and -o - to write the decrypted data on
+the standard output (the encrypted file is kept). In this case the password can be given
+with --pwd-fd or --pwd-env so that the standard input stays free for the data.
+
+A .raptor archive is restored as a folder with the original name. The entries that
+would be written outside of it are refused; --symlinks decides what to do with the links:
+'safe' (default) restores only those pointing inside the folder, 'skip' never restores
+them, 'error' fails on a link pointing outside.
+
+The files of a folder can be selected with --include, --exclude, --min-size, --max-size and
+the .raptorignore file as for the encryption: the patterns are matched against the names
+without the .enc extension. In a folder the links are skipped unless --symlinks is 'follow'
+or 'error'. Use --dry-run to list the files that would be decrypted.
+
+A folder is verified against its manifest before the decryption, see 'raptor verify': the
+decryption is refused when files are missing, added, swapped or stale, or when a folder
+with .enc files has no manifest, unless --ignore-manifest is given.
+
+The .age files are decrypted with the age secret keys stored in the box given with --box
+(or CRYPTEX_BOX), see 'raptor keygen'. Without a key the password is asked: age files
+encrypted with a passphrase are supported too.
+
+The encrypted files are wiped with the --wipe policy before being removed, see
+'raptor encrypt --help'.`,
+		Example: `$ raptor decrypt /test/file
+$ raptor decrypt --symlinks skip docs.raptor
+$ raptor decrypt --box test report.pdf.age
+$ raptor decrypt -o - backup.enc | psql
+$ cat dir.tar.enc | raptor decrypt - --pwd-env BACKUP_PWD | tar x`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			slog.Debug("decrypt run", "path", args[0])
+			if opts.streaming(args[0]) {
+				transform := security.DecryptStream
+				if len(opts.box) > 0 {
+					ids, err := boxIdentities(cmd.Context(), opts.box)
+					if err != nil {
+						return streamError{err}
+					}
+					opts.identities = ids
+					transform = func(r io.Reader, w io.Writer, _ string) error {
+						return security.DecryptAge(r, w, opts.identities, "")
+					}
 				}
-			} else {
+				if err := runStream(args[0], &opts, false, transform); err != nil {
+					return streamError{cryptError(err)}
+				}
+				return nil
+			}
+			if err := decrypt(cmd.Context(), args[0], &opts); err != nil {
+				// the skipped file has been reported with a warning
+				if errors.Is(err, security.ErrInvalidFile) {
+					return nil
+				}
+				return cryptError(err)
+			}
+			if !opts.dryRun {
 				console.OK("Decryption succeded")
 			}
+			return nil
 		},
 	}
 	// Here you will define your flags and configuration settings.
-	//	c.Flags().StringVarP(&pwd, "pwd", "p", "", "pwd to open the box (use ONLY FOR DEBUG MODE)")
+	addCryptFlags(c, &opts)
+	c.Flags().StringVarP(&opts.box, "box", "b", "", "The box with the age secret keys for the .age files")
+	c.Flags().BoolVar(&opts.ignoreManifest, "ignore-manifest", false, "Decrypt a folder even if it doesn't match its manifest or has none")
+	c.Flags().StringVar(&opts.symlinks, "symlinks", security.SymlinkSafe, "The policy for the links: safe, skip, follow (folders only) or error")
 
 	return c
 }
 
-func decrypt(path string) error {
+func decrypt(ctx context.Context, path string, opts *cryptOptions) error {
 	// does the path exists
 	info, err := os.Stat(path)
 	utils.Verbosity(fmt.Sprintf("decryption starting on path %s", path), verbose)
@@ -57,15 +112,60 @@ func decrypt(path string) error {
 		}
 	}
 
-	passphrase, err := utils.AskForPassword("Password: ", false)
-	if err != nil {
-		return fmt.Errorf("%s", err.Error())
+	if err := opts.checkFilters(security.SymlinkSafe, security.SymlinkSkip, security.SymlinkFollow, security.SymlinkError); err != nil {
+		return err
+	}
+	if opts.dryRun {
+		return dryRun(path, info, opts, true)
+	}
+	if err := opts.checkWipe(path); err != nil {
+		return err
 	}
 
-	// encrypt the file or the folder
+	// the age files are decrypted with the keys in the box, the password is
+	// asked for the other files or when no key is found
+	hasAge, hasOther := scanEncrypted(path, info)
+	if hasAge {
+		if opts.identities, err = boxIdentities(ctx, opts.box); err != nil {
+			return err
+		}
+	}
+	var passphrase string
+	if hasOther || len(opts.identities) == 0 {
+		if passphrase, err = opts.passphrase(path, false); err != nil {
+			return err
+		}
+	}
+	// decrypt the archive, the file or the folder
+	if !info.IsDir() && security.IsArchive(path) {
+		dir, err := security.DecryptArchive(path, passphrase, opts.fileOptions())
+		if err == nil {
+			utils.Verbosity(fmt.Sprintf("archive restored into %s", dir), verbose)
+		}
+		return err
+	}
 	if info.IsDir() {
-		return security.DecryptDirectory(path, passphrase)
+		return security.DecryptDirectory(path, passphrase, opts.fileOptions())
 	} else {
-		return security.DecryptFile(path, passphrase)
+		return security.DecryptFile(path, passphrase, opts.fileOptions())
 	}
 }
+
+// scanEncrypted tells if the path has .age files and other encrypted files
+func scanEncrypted(path string, info os.FileInfo) (hasAge, hasOther bool) {
+	if !info.IsDir() {
+		return security.IsAgeFile(path), !security.IsAgeFile(path)
+	}
+	filepath.Walk(path, func(p string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return nil
+		}
+		if security.IsAgeFile(p) {
+			hasAge = true
+		} else {
+			hasOther = true
+		}
+		return nil
+	})
+	return hasAge, hasOther
+}
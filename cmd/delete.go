@@ -16,7 +16,7 @@ func newDeleteCmd() *cobra.Command {
 		Long:  `Delete a raptor object: secret`,
 	}
 	// Here you will define your flags and configuration settings.
-	c.AddCommand(delete.DeleteSecretCmd)
+	c.AddCommand(delete.NewSecretCmd())
 	c.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "to get more information use the verbose mode")
 
 	return c
@@ -3,16 +3,16 @@ package delete
 import (
 	"testing"
 
-	"github.com/mas2020-golang/cryptex/packages/utils"
+	"github.com/mas2020-golang/cryptex/pkg/vault"
 )
 
 // TestRemoveSecret_SecretExists tests removing a secret that exists in the box
 func TestRemoveSecret_SecretExists(t *testing.T) {
 	// Setup: Create a box with multiple secrets
-	box := &utils.Box{
+	box := &vault.Box{
 		Name:    "test-box",
 		Version: "1.0.0",
-		Secrets: []*utils.Secret{
+		Secrets: []*vault.Secret{
 			{Name: "secret1", Pwd: "password1"},
 			{Name: "secret2", Pwd: "password2"},
 			{Name: "secret3", Pwd: "password3"},
@@ -53,10 +53,10 @@ func TestRemoveSecret_SecretExists(t *testing.T) {
 // TestRemoveSecret_SecretDoesNotExist tests removing a secret that doesn't exist
 func TestRemoveSecret_SecretDoesNotExist(t *testing.T) {
 	// Setup: Create a box with secrets
-	box := &utils.Box{
+	box := &vault.Box{
 		Name:    "test-box",
 		Version: "1.0.0",
-		Secrets: []*utils.Secret{
+		Secrets: []*vault.Secret{
 			{Name: "secret1", Pwd: "password1"},
 			{Name: "secret2", Pwd: "password2"},
 		},
@@ -84,10 +84,10 @@ func TestRemoveSecret_SecretDoesNotExist(t *testing.T) {
 // TestRemoveSecret_EmptyBox tests removing from a box with an empty secrets slice
 func TestRemoveSecret_EmptyBox(t *testing.T) {
 	// Setup: Create a box with empty secrets slice
-	box := &utils.Box{
+	box := &vault.Box{
 		Name:    "test-box",
 		Version: "1.0.0",
-		Secrets: []*utils.Secret{},
+		Secrets: []*vault.Secret{},
 	}
 
 	// Execute: Try to remove a secret
@@ -110,7 +110,7 @@ func TestRemoveSecret_EmptyBox(t *testing.T) {
 // TestRemoveSecret_NilSecrets tests removing from a box with nil secrets
 func TestRemoveSecret_NilSecrets(t *testing.T) {
 	// Setup: Create a box with nil secrets
-	box := &utils.Box{
+	box := &vault.Box{
 		Name:    "test-box",
 		Version: "1.0.0",
 		Secrets: nil,
@@ -136,10 +136,10 @@ func TestRemoveSecret_NilSecrets(t *testing.T) {
 // TestRemoveSecret_FirstSecret tests removing the first secret in the list
 func TestRemoveSecret_FirstSecret(t *testing.T) {
 	// Setup: Create a box with multiple secrets
-	box := &utils.Box{
+	box := &vault.Box{
 		Name:    "test-box",
 		Version: "1.0.0",
-		Secrets: []*utils.Secret{
+		Secrets: []*vault.Secret{
 			{Name: "secret1", Pwd: "password1"},
 			{Name: "secret2", Pwd: "password2"},
 			{Name: "secret3", Pwd: "password3"},
@@ -171,10 +171,10 @@ func TestRemoveSecret_FirstSecret(t *testing.T) {
 // TestRemoveSecret_LastSecret tests removing the last secret in the list
 func TestRemoveSecret_LastSecret(t *testing.T) {
 	// Setup: Create a box with multiple secrets
-	box := &utils.Box{
+	box := &vault.Box{
 		Name:    "test-box",
 		Version: "1.0.0",
-		Secrets: []*utils.Secret{
+		Secrets: []*vault.Secret{
 			{Name: "secret1", Pwd: "password1"},
 			{Name: "secret2", Pwd: "password2"},
 			{Name: "secret3", Pwd: "password3"},
@@ -206,10 +206,10 @@ func TestRemoveSecret_LastSecret(t *testing.T) {
 // TestRemoveSecret_OnlySecret tests removing the only secret in the box
 func TestRemoveSecret_OnlySecret(t *testing.T) {
 	// Setup: Create a box with only one secret
-	box := &utils.Box{
+	box := &vault.Box{
 		Name:    "test-box",
 		Version: "1.0.0",
-		Secrets: []*utils.Secret{
+		Secrets: []*vault.Secret{
 			{Name: "only-secret", Pwd: "password1"},
 		},
 	}
@@ -234,10 +234,10 @@ func TestRemoveSecret_OnlySecret(t *testing.T) {
 // TestRemoveSecret_ComplexSecret tests removing a secret with all fields populated
 func TestRemoveSecret_ComplexSecret(t *testing.T) {
 	// Setup: Create a box with a complex secret
-	box := &utils.Box{
+	box := &vault.Box{
 		Name:    "test-box",
 		Version: "1.0.0",
-		Secrets: []*utils.Secret{
+		Secrets: []*vault.Secret{
 			{
 				Name:        "simple-secret",
 				Pwd:         "password1",
@@ -301,10 +301,10 @@ func TestRemoveSecret_ComplexSecret(t *testing.T) {
 // TestRemoveSecret_CaseSensitive tests that secret names are case-sensitive
 func TestRemoveSecret_CaseSensitive(t *testing.T) {
 	// Setup: Create a box with a secret
-	box := &utils.Box{
+	box := &vault.Box{
 		Name:    "test-box",
 		Version: "1.0.0",
-		Secrets: []*utils.Secret{
+		Secrets: []*vault.Secret{
 			{Name: "MySecret", Pwd: "password1"},
 		},
 	}
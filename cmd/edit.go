@@ -17,7 +17,7 @@ func newEditCmd() *cobra.Command {
 	}
 	// Here you will define your flags and configuration settings.
 	// Here you will define your flags and configuration settings.
-	c.AddCommand(edit.EditSecretCmd)
+	c.AddCommand(edit.NewSecretCmd())
 	c.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "to get more information use the verbose mode")
 
 	return c
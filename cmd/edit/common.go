@@ -1,5 +0,0 @@
-package edit
-
-var (
-	boxName string
-)
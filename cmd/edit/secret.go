@@ -5,60 +5,74 @@ package edit
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
 	"strings"
 	"time"
 
+	"github.com/mas2020-golang/cryptex/internal/secretutil"
 	"github.com/mas2020-golang/cryptex/packages/utils"
+	"github.com/mas2020-golang/cryptex/pkg/vault"
 	"github.com/mas2020-golang/goutils/output"
 	"github.com/spf13/cobra"
 )
 
-// boxCmd represents the box command
-var EditSecretCmd = &cobra.Command{
-	Use:   "secret <NAME>",
-	Args:  cobra.MinimumNArgs(1),
-	Aliases: []string{"secret", "sr"},
-	Short: "Edit an existing secret",
-	Long: `Edit a secret by name:
+// NewSecretCmd creates and returns the edit secret command
+func NewSecretCmd() *cobra.Command {
+	var boxName string
+	c := &cobra.Command{
+		Use:     "secret <NAME>",
+		Args:    cobra.MinimumNArgs(1),
+		Aliases: []string{"secret", "sr"},
+		Short:   "Edit an existing secret",
+		Long: `Edit a secret by name:
 The <NAME> argument is in the following format:
   - 'secret name.item': to update an item
   - 'secret name.pwd': to update only the pwd
   - 'secret name': to update every elements
 `,
-	Example: `$ raptor secret edit 'new-secret' --box test`,
-	Run: func(cmd *cobra.Command, args []string) {
-		edit(args[0])
-	},
-}
+		Example:           `$ raptor secret edit 'new-secret' --box test`,
+		ValidArgsFunction: secretutil.CompleteNames,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return edit(cmd.Context(), boxName, args[0])
+		},
+	}
+	c.PersistentFlags().StringVarP(&boxName, "box", "b", "", "The name of the box where to add the secret")
 
-func init() {
-	EditSecretCmd.PersistentFlags().StringVarP(&boxName, "box", "b", "", "The name of the box where to add the secret")
+	return c
 }
 
-func edit(name string) {
+func edit(ctx context.Context, boxName, name string) error {
 	// open the box
-	boxPath, key, box, err := utils.OpenBox(boxName, "")
-	utils.Check(err, "")
-	// add the secret
-	err = editSecret(name, box, boxPath)
+	v, err := utils.OpenBox(ctx, boxName, "")
 	if err != nil {
-		output.Error("", err.Error())
-		return
+		return err
+	}
+	defer utils.CloseBox(ctx, v)
+	// add the secret
+	if err = EditSecret(name, v.Box(), v.Source()); err != nil {
+		return err
 	}
 	fmt.Println()
 	// save the box
-	err = utils.SaveBox(boxPath, key, box)
-	utils.Check(err, "")
+	if err = v.Save(ctx); err != nil {
+		return err
+	}
 	utils.Success(output.BoldS("box saved!"))
+	return nil
 }
 
-func editSecret(name string, box *utils.Box, boxPath string) error {
+// EditSecret asks the new values of the secret on the standard input, the box
+// is not saved
+func EditSecret(name string, box *vault.Box, boxPath string) error {
 	// get the secret to edit
 	s := findSecret(name, box)
 	if s == nil {
-		return fmt.Errorf("the secret %q doesn't exist in the box %q", name, boxPath)
+		return fmt.Errorf("%w: %q in the box %s", vault.ErrSecretNotFound, name, boxPath)
+	}
+	if err := s.Load(); err != nil {
+		return err
 	}
 
 	utils.Note(output.BoldS("\npress ENTER without typing to skip the field"))
@@ -83,11 +97,15 @@ func editSecret(name string, box *utils.Box, boxPath string) error {
 	}
 	fmt.Printf("%s [%s]: ", output.BlueS("Pwd"), output.BoldS("xxx"))
 	input, err := utils.ReadPassword("")
-	utils.Check(err, "")
+	if err != nil {
+		return err
+	}
 	if len(input) != 0 {
 		fmt.Printf("\n%s [%s]: ", output.BlueS("Confirm pwd"), output.BoldS("xxx"))
 		input2, err := utils.ReadPassword("")
-		utils.Check(err, "")
+		if err != nil {
+			return err
+		}
 		if input != input2 {
 			fmt.Println()
 			return fmt.Errorf("the pwd mismatched")
@@ -132,11 +150,6 @@ func editSecret(name string, box *utils.Box, boxPath string) error {
 
 // findSecret searches for the secret into the box and returns the one corresponding or nil
 // value
-func findSecret(name string, box *utils.Box) *utils.Secret {
-	for _, s := range box.Secrets {
-		if (*s).Name == name {
-			return s
-		}
-	}
-	return nil
+func findSecret(name string, box *vault.Box) *vault.Secret {
+	return box.Secret(name)
 }
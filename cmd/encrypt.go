@@ -6,46 +6,118 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 
 	"github.com/mas2020-golang/cryptex/packages/security"
 	"github.com/mas2020-golang/cryptex/packages/utils"
 	"github.com/mas2020-golang/goutils/console"
-
 	"github.com/spf13/cobra"
 )
 
-var ()
-
 func newEncryptCmd() *cobra.Command {
+	opts := cryptOptions{}
 	c := &cobra.Command{
-		Use:     "encrypt <FILE|FOLDER>",
+		Use:     "encrypt <FILE|FOLDER|->",
 		Args:    cobra.MinimumNArgs(1),
-		Aliases: []string{"en", "encrypt"},
-		// Args:    cobra.MinimumNArgs(1),
-		Short: "Encrypt a file or a folder",
+		Aliases: []string{"en"},
+		Short:   "Encrypt a file or a folder",
 		Long: `The encryption is accepting a file or a folder. The command will automatically delete
-each file in in the path.`,
-		Example: `$ raptor encrypt /test/file`,
-		Run: func(cmd *cobra.Command, args []string) {
+each file in in the path, use --keep to keep them.
+Use --output to write the encrypted files into another folder mirroring the tree: an
+existing destination file is never overwritten unless --force is given.
+
+Use - as path to encrypt the standard input and -o - to write the encrypted data on
+the standard output (the source file is kept). In this case the password can be given
+with --pwd-fd or --pwd-env so that the standard input stays free for the data.
+
+Use --archive to encrypt a folder into a single <folder>.raptor file: names, structure
+and sizes of the files are not visible anymore. Add --compress to gzip the content.
+The symlinks are stored as links, the special files are skipped.
+
+Use --encrypt-names to replace the names of the files and folders with encrypted tokens:
+the same name always gives the same token, so sync tools still upload only the changed
+files. The decrypt command restores the original names.
+
+Use --recipient (once per key) to encrypt for the age public keys of other people
+instead of a password: the files are written in the age format with the .age extension
+and can be decrypted by 'raptor decrypt --box <BOX>' or by any age implementation.
+See 'raptor keygen' to create a key pair.
+
+The progress of a folder encryption is recorded in an encrypted journal (.raptor-journal)
+inside the folder. If the run is interrupted (Ctrl+C, power loss, an error) use --resume
+to complete it with the options of the interrupted run, or --rollback to get the folder
+back as it was; a new run is refused until then. At the end a manifest (.raptor-manifest)
+listing the encrypted files is written, see 'raptor verify'. A manifest that can't be
+opened with the password (modified, or written with another password) is replaced only
+with --replace-manifest.
+
+The files of a folder are selected with gitignore-style patterns given with --include and
+--exclude (repeatable) and read from the .raptorignore file of the folder; --min-size and
+--max-size skip the files out of the range. The symlinks are skipped unless --symlinks is
+'follow' (a linked folder is walked as part of the tree, a linked file is replaced by the
+encrypted file leaving the target untouched) or 'error'. The special files (sockets, pipes,
+devices) are always skipped. Use --dry-run to list the files
+that would be encrypted.
+
+The source files are wiped with the --wipe policy before being removed: 'dod' (default,
+3 passes), 'random' or 'zero' (1 pass) or 'none'. Set RAPTOR_WIPE to change the default.`,
+		Example: `$ raptor encrypt /test/file
+$ raptor encrypt --archive --compress ~/docs
+$ raptor encrypt --encrypt-names ~/Dropbox/private
+$ raptor encrypt --recipient age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p report.pdf
+$ raptor encrypt --keep --output /backup/docs ~/docs
+$ raptor encrypt --resume ~/docs
+$ raptor encrypt --exclude .git/ --exclude '*.log' --max-size 100M --dry-run ~/project
+$ tar c dir | raptor encrypt - --pwd-env BACKUP_PWD > dir.tar.enc
+$ raptor encrypt -o - --pwd-fd 3 db.sql 3<pwd.txt > db.sql.enc`,
+		RunE: func(cmd *cobra.Command, args []string) error {
 			slog.Debug("encrypt run", "path", args[0])
-			if err := encrypt(args[0]); err != nil {
-				if !errors.Is(err, security.ErrInvalidFile) {
-					console.Error(err.Error(), true)
+			if opts.streaming(args[0]) {
+				if utils.IsTerminal(os.Stdout) {
+					return streamError{fmt.Errorf("refusing to write encrypted data to a terminal, redirect the output")}
 				}
-			} else {
+				transform := security.EncryptStream
+				if len(opts.recipients) > 0 {
+					transform = func(r io.Reader, w io.Writer, _ string) error {
+						return security.EncryptAge(r, w, opts.recipients)
+					}
+				}
+				if err := runStream(args[0], &opts, true, transform); err != nil {
+					return streamError{err}
+				}
+				return nil
+			}
+			if err := encrypt(args[0], &opts); err != nil {
+				// the skipped file has been reported with a warning
+				if errors.Is(err, security.ErrInvalidFile) {
+					return nil
+				}
+				return cryptError(err)
+			}
+			if !opts.dryRun {
 				console.OK("Encryption succeded")
 			}
+			return nil
 		},
 	}
 	// Here you will define your flags and configuration settings.
-	//	c.Flags().StringVarP(&pwd, "pwd", "p", "", "pwd to open the box (use ONLY FOR DEBUG MODE)")
+	addCryptFlags(c, &opts)
+	c.Flags().BoolVarP(&opts.archive, "archive", "a", false, "Encrypt the folder into a single .raptor archive")
+	c.Flags().StringArrayVarP(&opts.recipients, "recipient", "r", nil, "Encrypt for this age public key instead of a password (repeatable)")
+	c.Flags().BoolVar(&opts.encryptNames, "encrypt-names", false, "Encrypt the names of the files and folders too")
+	c.Flags().BoolVarP(&opts.compress, "compress", "z", false, "Compress the archive content (requires --archive)")
+	c.Flags().BoolVar(&opts.resume, "resume", false, "Complete the interrupted encryption of the folder")
+	c.Flags().BoolVar(&opts.rollback, "rollback", false, "Undo the interrupted encryption of the folder")
+	c.MarkFlagsMutuallyExclusive("resume", "rollback")
+	c.Flags().BoolVar(&opts.replaceManifest, "replace-manifest", false, "Write a new manifest in place of one that can't be opened with the password")
+	c.Flags().StringVar(&opts.symlinks, "symlinks", security.SymlinkSkip, "The policy for the links in a folder: skip, follow or error")
 
 	return c
 }
 
-func encrypt(path string) error {
+func encrypt(path string, opts *cryptOptions) error {
 	// does the path exists
 	info, err := os.Stat(path)
 	utils.Verbosity(fmt.Sprintf("encryption starting on path %s", path), verbose)
@@ -57,15 +129,73 @@ func encrypt(path string) error {
 		}
 	}
 
-	passphrase, err := utils.AskForPassword("Password: ", true)
-	if err != nil {
-		return fmt.Errorf("%s", err.Error())
+	if opts.resume || opts.rollback {
+		return resumeOrRollback(path, info, opts)
+	}
+	if opts.archive && !info.IsDir() {
+		return fmt.Errorf("--archive requires a folder")
+	}
+	if opts.compress && !opts.archive {
+		return fmt.Errorf("--compress requires --archive")
+	}
+	if opts.encryptNames && (!info.IsDir() || opts.archive) {
+		return fmt.Errorf("--encrypt-names requires a folder and can't be used with --archive")
+	}
+	if len(opts.recipients) > 0 && (opts.archive || opts.encryptNames) {
+		return fmt.Errorf("--recipient can't be used with --archive or --encrypt-names")
+	}
+	if err := opts.checkFilters(security.SymlinkSkip, security.SymlinkFollow, security.SymlinkError); err != nil {
+		return err
+	}
+	if opts.dryRun {
+		return dryRun(path, info, opts, false)
+	}
+	if err := opts.checkWipe(path); err != nil {
+		return err
 	}
 
+	var passphrase string
+	if opts.needsPassphrase() {
+		if passphrase, err = opts.passphrase(path, true); err != nil {
+			return err
+		}
+	}
 	// encrypt the file or the folder
+	if opts.archive {
+		archive, err := security.EncryptArchive(path, passphrase, opts.fileOptions())
+		if err == nil {
+			utils.Verbosity(fmt.Sprintf("archive written into %s", archive), verbose)
+		}
+		return err
+	}
 	if info.IsDir() {
-		return security.EncryptDirectory(path, passphrase)
+		err := security.EncryptDirectory(path, passphrase, opts.fileOptions())
+		if err != nil && security.HasJournal(path) {
+			err = fmt.Errorf("%w\nuse 'raptor encrypt --resume' or 'raptor encrypt --rollback' on the folder", err)
+		}
+		return err
 	} else {
-		return security.EncryptFile(path, passphrase)
+		return security.EncryptFile(path, passphrase, opts.fileOptions())
+	}
+}
+
+// resumeOrRollback completes or undoes the interrupted encryption of the folder
+func resumeOrRollback(path string, info os.FileInfo, opts *cryptOptions) error {
+	if !info.IsDir() {
+		return fmt.Errorf("--resume and --rollback require a folder")
+	}
+	if opts.archive || len(opts.recipients) > 0 || len(opts.output) > 0 {
+		return fmt.Errorf("--resume and --rollback use the options of the interrupted run")
+	}
+	if !security.HasJournal(path) {
+		return fmt.Errorf("no interrupted encryption found in %s", path)
+	}
+	passphrase, err := opts.passphrase(path, false)
+	if err != nil {
+		return err
+	}
+	if opts.rollback {
+		return security.RollbackEncryptDirectory(path, passphrase)
 	}
+	return security.ResumeEncryptDirectory(path, passphrase)
 }
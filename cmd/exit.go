@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/mas2020-golang/cryptex/pkg/vault"
+)
+
+// Exit codes of raptor, documented in the help of the root command and in the
+// README: scripts can tell the failures apart without parsing the messages
+const (
+	exitOK = iota
+	exitError
+	exitWrongPassword
+	exitBoxNotFound
+	exitSecretNotFound
+	exitCorrupt
+	exitLocked
+	exitConflict
+)
+
+// exitCode returns the exit code of the error returned by a command
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, vault.ErrWrongPassword), errors.Is(err, vault.ErrKeyFileRequired):
+		return exitWrongPassword
+	case errors.Is(err, vault.ErrBoxNotFound):
+		return exitBoxNotFound
+	case errors.Is(err, vault.ErrSecretNotFound):
+		return exitSecretNotFound
+	case errors.Is(err, vault.ErrCorrupt):
+		return exitCorrupt
+	case errors.Is(err, vault.ErrLocked):
+		return exitLocked
+	case errors.Is(err, vault.ErrConflict):
+		return exitConflict
+	}
+	return exitError
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mas2020-golang/cryptex/packages/security"
+	"github.com/mas2020-golang/cryptex/pkg/vault"
+)
+
+// TestExitCode tests that the sentinel errors are mapped to their exit codes
+// also when wrapped
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		code int
+	}{
+		{nil, 0},
+		{errors.New("failed"), 1},
+		{fmt.Errorf("failed to open the box b: %w", vault.ErrWrongPassword), 2},
+		{fmt.Errorf("%w, use --keyfile or RAPTOR_KEYFILE", vault.ErrKeyFileRequired), 2},
+		{fmt.Errorf("%w: b", vault.ErrBoxNotFound), 3},
+		{fmt.Errorf("%w: \"github\"", vault.ErrSecretNotFound), 4},
+		{fmt.Errorf("failed to open the box b: %w", vault.ErrCorrupt), 5},
+		{streamError{fmt.Errorf("%w: b", vault.ErrLocked)}, 6},
+		{fmt.Errorf("%w: s3://team/b", vault.ErrConflict), 7},
+		{cryptError(fmt.Errorf("%w\nuse 'raptor encrypt --resume' or 'raptor encrypt --rollback' on the folder", security.ErrAuthentication)), 2},
+	}
+	for _, tt := range tests {
+		if code := exitCode(tt.err); code != tt.code {
+			t.Errorf("exitCode(%v): expected %d, got %d", tt.err, tt.code, code)
+		}
+	}
+}
+
+// TestExitCode_Decrypt tests the exit codes of decrypt with a wrong password
+// and a truncated file
+func TestExitCode_Decrypt(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	os.WriteFile(path, []byte("secret data"), 0600)
+	if err := security.EncryptFile(path, "secret", &security.Options{Keep: true}); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path + ".enc")
+	truncated := filepath.Join(dir, "b.txt.enc")
+	os.WriteFile(truncated, data[:len(data)-20], 0600)
+
+	tests := []struct {
+		path, pwd string
+		code      int
+	}{
+		{path + ".enc", "wrong", exitWrongPassword},
+		{truncated, "secret", exitCorrupt},
+	}
+	for _, tt := range tests {
+		t.Setenv("RAPTOR_TEST_PWD", tt.pwd)
+		c := newDecryptCmd()
+		c.SetArgs([]string{tt.path, "--pwd-env", "RAPTOR_TEST_PWD", "--keep", "--output", t.TempDir()})
+		c.SilenceUsage, c.SilenceErrors = true, true
+		if code := exitCode(c.Execute()); code != tt.code {
+			t.Errorf("%s: expected exit code %d, got %d", filepath.Base(tt.path), tt.code, code)
+		}
+	}
+}
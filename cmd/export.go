@@ -0,0 +1,127 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mas2020-golang/cryptex/packages/exporter"
+	"github.com/mas2020-golang/cryptex/packages/security"
+	"github.com/mas2020-golang/cryptex/packages/utils"
+	"github.com/mas2020-golang/goutils/output"
+	"github.com/spf13/cobra"
+)
+
+type exportOptions struct {
+	boxName, format, outputPath, filter string
+	tags                                []string
+	encrypt, unsecure, force            bool
+}
+
+func newExportCmd() *cobra.Command {
+	opts := exportOptions{}
+	c := &cobra.Command{
+		Use:   "export",
+		Args:  cobra.NoArgs,
+		Short: "Export the secrets of a box to a portable format",
+		Long: fmt.Sprintf(`Export the secrets of a box to a portable format (%s).
+You can export only the secrets having one of the --tag values and/or a name
+matching the --filter regexp.
+
+The plaintext export contains every sensitive data in clear: raptor asks for an
+explicit confirmation unless --unsecure is given. Use --encrypt to write a
+passphrase-protected file instead, in the format of 'raptor encrypt': it can be
+decrypted with 'raptor decrypt'. An existing file is never overwritten unless
+--force is given.
+The secrets not exportable by the policy of the box are left out (see
+'raptor box policy').`, strings.Join(exporter.Formats(), ", ")),
+		Example: `$ raptor export --box test --format json --encrypt -o test.json.enc
+$ raptor export --box test --format csv --tag work --filter '^aws' -o aws.csv`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runExport(cmd.Context(), opts)
+		},
+	}
+	c.Flags().StringVarP(&opts.boxName, "box", "b", "", "The name of the box to export")
+	c.Flags().StringVarP(&opts.format, "format", "f", exporter.FormatJSON, fmt.Sprintf("The output format (%s)", strings.Join(exporter.Formats(), "|")))
+	c.Flags().StringVarP(&opts.outputPath, "output", "o", "", "The file to write (default stdout, or <BOX>.<FORMAT>.enc with --encrypt)")
+	c.Flags().StringSliceVarP(&opts.tags, "tag", "t", nil, "Export only the secrets with this tag (can be repeated)")
+	c.Flags().StringVar(&opts.filter, "filter", "", "Export only the secrets whose name matches the regexp (e.g. 'test.*')")
+	c.Flags().BoolVarP(&opts.encrypt, "encrypt", "e", false, "Encrypt the export with a new passphrase")
+	c.Flags().BoolVarP(&opts.unsecure, "unsecure", "u", false, "Confirm a plaintext export without asking")
+	c.Flags().BoolVar(&opts.force, "force", false, "Overwrite the output file if it exists")
+
+	return c
+}
+
+func runExport(ctx context.Context, opts exportOptions) error {
+	v, err := utils.OpenBox(ctx, opts.boxName, "")
+	if err != nil {
+		return err
+	}
+	defer utils.CloseBox(ctx, v)
+	box := v.Box()
+	if err := box.Load(); err != nil {
+		return err
+	}
+	secrets, err := exporter.Filter(box, opts.tags, opts.filter)
+	if err != nil {
+		return err
+	}
+	if box.Policy != nil && len(box.Policy.NotExportable) > 0 {
+		output.Warning("", fmt.Sprintf("the secrets matching %s are not exportable by the policy of the box", strings.Join(box.Policy.NotExportable, ", ")))
+	}
+	boxName := box.Name
+	if len(boxName) == 0 {
+		boxName = filepath.Base(v.Source())
+	}
+
+	var buf bytes.Buffer
+	if err := exporter.Export(&buf, opts.format, boxName, secrets); err != nil {
+		return err
+	}
+
+	// the encrypted export is written as a stream, like raptor encrypt does
+	write := func(w io.Writer) error {
+		_, err := io.Copy(w, &buf)
+		return err
+	}
+	if opts.encrypt {
+		key, err := utils.AskForPassword("Export password: ", true)
+		if err != nil {
+			return err
+		}
+		write = func(w io.Writer) error {
+			return security.EncryptStream(&buf, w, key)
+		}
+		if len(opts.outputPath) == 0 {
+			opts.outputPath = fmt.Sprintf("%s.%s.enc", boxName, opts.format)
+		}
+	} else if !opts.unsecure && !confirmPlaintext(len(secrets)) {
+		utils.Note("export aborted")
+		return nil
+	}
+
+	if len(opts.outputPath) == 0 {
+		return write(os.Stdout)
+	}
+	if err := security.WriteFile(opts.outputPath, opts.force, write); err != nil {
+		return err
+	}
+	utils.Success(fmt.Sprintf("%d secrets exported to %s", len(secrets), opts.outputPath))
+	return nil
+}
+
+// confirmPlaintext asks the user to type yes before writing the secrets in clear
+func confirmPlaintext(n int) bool {
+	output.Warning("", fmt.Sprintf("%d secrets are going to be exported in PLAINTEXT", n))
+	fmt.Fprint(os.Stderr, "Type 'yes' to continue: ")
+	return utils.GetText(bufio.NewReader(os.Stdin)) == "yes"
+}
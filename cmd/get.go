@@ -16,7 +16,7 @@ func newGetCmd() *cobra.Command {
 		Long:  "Get a raptor object: secret",
 	}
 	// Here you will define your flags and configuration settings.
-	c.AddCommand(get.GetSecretCmd)
+	c.AddCommand(get.NewSecretCmd())
 	c.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "to get more information use the verbose mode")
 
 	return c
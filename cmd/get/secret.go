@@ -4,10 +4,12 @@ Copyright © 2022 NAME HERE <EMAIL ADDRESS>
 package get
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/mas2020-golang/cryptex/internal/secretutil"
 	"github.com/mas2020-golang/cryptex/packages/utils"
+	"github.com/mas2020-golang/cryptex/pkg/vault"
 	"github.com/mas2020-golang/goutils/output"
 	"github.com/spf13/cobra"
 
@@ -15,40 +17,50 @@ import (
 	"github.com/atotto/clipboard"
 )
 
-var boxName string
-
-// boxCmd represents the box command
-var GetSecretCmd = &cobra.Command{
-	Use:     "secret <NAME>",
-	Args:    cobra.MinimumNArgs(1),
-	Aliases: []string{"sr"},
-	Short:   "Get the sensitive data from a secret",
-	Long: `Get the sensitive data from a secret. You can refer to the data as:
+// NewSecretCmd creates and returns the get secret command
+func NewSecretCmd() *cobra.Command {
+	var boxName string
+	c := &cobra.Command{
+		Use:     "secret <NAME>",
+		Args:    cobra.MinimumNArgs(1),
+		Aliases: []string{"sr"},
+		Short:   "Get the sensitive data from a secret",
+		Long: `Get the sensitive data from a secret. You can refer to the data as:
 - <SECRET_NAME>: retrieves the root sensitive data for the secret
 - <SECRET_NAME>.<ITEM_NAME>: retrieves the ITEM_NAME sensitive data in the items collection`,
-	Example: `$ raptor get secret foo --box test // to retrieve the pwd of the foo secret
+		Example: `$ raptor get secret foo --box test // to retrieve the pwd of the foo secret
 $ raptor get secret foo.test --box test // to retrieve the test secret item of the foo secret`,
-	Run: func(cmd *cobra.Command, args []string) {
-		get(args[0])
-	},
-}
+		ValidArgsFunction: secretutil.CompleteNames,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return get(cmd.Context(), boxName, args[0])
+		},
+	}
+	c.PersistentFlags().StringVarP(&boxName, "box", "b", "", "The name of the box where to add the secret")
 
-func init() {
-	GetSecretCmd.PersistentFlags().StringVarP(&boxName, "box", "b", "", "The name of the box where to add the secret")
+	return c
 }
 
-func get(name string) {
-	result, _, err := secretutil.Lookup(boxName, name)
-	utils.Check(err, "")
-	if result == nil || len(result.Value) == 0 {
-		output.Warning("", fmt.Sprintf("no secret %q found in %s", name, boxName))
-		return
+func get(ctx context.Context, boxName, name string) error {
+	result, v, err := secretutil.Lookup(ctx, boxName, name)
+	if err != nil {
+		return err
+	}
+	defer utils.CloseBox(ctx, v)
+	if result.Secret == nil {
+		return fmt.Errorf("%w: %q", vault.ErrSecretNotFound, name)
+	}
+	if _, ok := result.Secret.Others[result.Item]; len(result.Item) > 0 && !ok {
+		return fmt.Errorf("%w: no item %q in the secret %s", vault.ErrSecretNotFound, result.Item, result.Secret.Name)
+	}
+	if len(result.Value) == 0 {
+		output.Warning("", fmt.Sprintf("the secret %q is empty", name))
+		return nil
 	}
 	// copy the secret into the clipboard
-	err = clipboard.WriteAll(result.Value)
-	if err != nil {
-		output.Error("", err.Error())
+	if err = clipboard.WriteAll(result.Value); err != nil {
+		return err
 	}
 	fmt.Println()
 	utils.Success(output.BoldS("the secret is in your clipboard"))
+	return nil
 }
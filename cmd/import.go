@@ -0,0 +1,144 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mas2020-golang/cryptex/packages/importer"
+	"github.com/mas2020-golang/cryptex/packages/utils"
+	"github.com/mas2020-golang/cryptex/pkg/vault"
+	"github.com/mas2020-golang/goutils/output"
+	"github.com/spf13/cobra"
+)
+
+type importOptions struct {
+	format, boxName, onDuplicate string
+	dryRun, yes                  bool
+}
+
+func newImportCmd() *cobra.Command {
+	opts := importOptions{}
+	c := &cobra.Command{
+		Use:   "import <FILE>",
+		Args:  cobra.ExactArgs(1),
+		Short: "Import secrets from other password managers",
+		Long: fmt.Sprintf(`Import the secrets exported from another password manager into a box.
+Supported formats: %s.
+For the pass format <FILE> is the password store folder (e.g. ~/.password-store)
+and gpg is used to decrypt every entry.
+
+Folders become tags and the fields without a raptor counterpart are saved as items.
+The '.' char is reserved for the items so it is replaced with '_' in the names.
+When a secret with the same name already exists you can skip it, overwrite it or
+rename it: use --on-duplicate to choose the policy, otherwise raptor asks for each one.
+A summary is printed before saving the box.`, strings.Join(importer.Formats(), ", ")),
+		Example: `$ raptor import --format bitwarden-json export.json --box test
+$ raptor import --format keepass-xml db.xml --box test --on-duplicate rename --dry-run`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runImport(cmd.Context(), args[0], opts)
+		},
+	}
+	c.Flags().StringVarP(&opts.format, "format", "f", "", fmt.Sprintf("The format of the file (%s)", strings.Join(importer.Formats(), "|")))
+	c.Flags().StringVarP(&opts.boxName, "box", "b", "", "The name of the box where to import the secrets")
+	c.Flags().StringVar(&opts.onDuplicate, "on-duplicate", "", "What to do with duplicated names: skip, overwrite or rename (default ask)")
+	c.Flags().BoolVar(&opts.dryRun, "dry-run", false, "Print the summary without saving the box")
+	c.Flags().BoolVarP(&opts.yes, "yes", "y", false, "Save the box without asking for confirmation")
+	c.MarkFlagRequired("format")
+
+	return c
+}
+
+func runImport(ctx context.Context, path string, opts importOptions) error {
+	switch opts.onDuplicate {
+	case "", importer.OnDuplicateSkip, importer.OnDuplicateOverwrite, importer.OnDuplicateRename:
+	default:
+		return fmt.Errorf("invalid --on-duplicate value %q, use skip, overwrite or rename", opts.onDuplicate)
+	}
+
+	secrets, err := importer.Parse(opts.format, path)
+	if err != nil {
+		return err
+	}
+	utils.Verbosity(fmt.Sprintf("%d secrets read from %s", len(secrets), path), verbose)
+
+	v, err := utils.OpenBox(ctx, opts.boxName, "")
+	if err != nil {
+		return err
+	}
+	defer utils.CloseBox(ctx, v)
+	box := v.Box()
+
+	r := bufio.NewReader(os.Stdin)
+	summary, err := importer.Plan(box, secrets, func(s *vault.Secret) string {
+		if len(opts.onDuplicate) > 0 {
+			return opts.onDuplicate
+		}
+		return askDuplicate(r, s.Name)
+	})
+	if err != nil {
+		return err
+	}
+
+	printImportSummary(summary)
+	if opts.dryRun {
+		utils.Note("dry-run: the box has not been modified")
+		return nil
+	}
+	if summary.Changes() == 0 {
+		utils.Note("nothing to import")
+		return nil
+	}
+	if !opts.yes {
+		fmt.Printf("Save %d changes into %s? [Y/n] ", summary.Changes(), v.Source())
+		switch strings.ToLower(strings.TrimSpace(utils.GetText(r))) {
+		case "", "y", "yes":
+		default:
+			utils.Note("import aborted, the box has not been modified")
+			return nil
+		}
+	}
+
+	importer.Apply(box, summary)
+	if err := v.Save(ctx); err != nil {
+		return err
+	}
+	utils.Success(output.BoldS("secrets imported and box saved!"))
+	return nil
+}
+
+// askDuplicate asks the user what to do with a secret name already in the box
+func askDuplicate(r *bufio.Reader, name string) string {
+	for {
+		fmt.Printf("secret %s already exists: [s]kip, [o]verwrite or [r]ename? ", output.BoldS(name))
+		switch strings.ToLower(utils.GetText(r)) {
+		case "s", "skip", "":
+			return importer.OnDuplicateSkip
+		case "o", "overwrite":
+			return importer.OnDuplicateOverwrite
+		case "r", "rename":
+			return importer.OnDuplicateRename
+		}
+	}
+}
+
+func printImportSummary(summary *importer.Summary) {
+	fmt.Println()
+	fmt.Printf("%-10s%-35s%s\n", "ACTION", "NAME", "TAGS")
+	for _, a := range summary.Actions {
+		name := a.Secret.Name
+		if a.Op == importer.OnDuplicateRename {
+			name = fmt.Sprintf("%s (was %s)", a.Secret.Name, a.OriginalName)
+		}
+		fmt.Printf("%-10s%-35s%s\n", a.Op, name, strings.Join(a.Secret.Tags, ","))
+	}
+	fmt.Println()
+	output.InfoBox(fmt.Sprintf("%d to add, %d to overwrite, %d to rename, %d to skip",
+		summary.Count("add"), summary.Count(importer.OnDuplicateOverwrite),
+		summary.Count(importer.OnDuplicateRename), summary.Count(importer.OnDuplicateSkip)))
+}
@@ -4,6 +4,7 @@ Copyright © 2022 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strconv"
@@ -11,7 +12,9 @@ import (
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/mas2020-golang/cryptex/cmd/list"
+	"github.com/mas2020-golang/cryptex/packages/security"
 	"github.com/mas2020-golang/cryptex/packages/utils"
+	"github.com/mas2020-golang/cryptex/pkg/vault"
 	"github.com/spf13/cobra"
 )
 
@@ -103,6 +106,9 @@ func DisplayEnvironmentInfo() {
 		{"CRYPTEX_BOX", "Cryptex box configuration", false},
 		{"RAPTOR_LOGLEVEL", "Logging level for Raptor", false},
 		{"RAPTOR_TIMEOUT_SEC", "Timeout in seconds for Raptor", false},
+		{"RAPTOR_KEYFILE", "Key file required with the password", false},
+		{"RAPTOR_WIPE", "Wipe policy for the removed files", false},
+		{"RAPTOR_STORE", "Store of the boxes (path, s3:// or davs:// URL)", false},
 	}
 
 	var content strings.Builder
@@ -180,14 +186,16 @@ func DisplayEnvironmentInfo() {
 	renderedBoxes := ""
 	if err != nil {
 		renderedBoxes = err.Error()
+	} else if store, err := utils.Store(); err != nil {
+		renderedBoxes = err.Error()
 	} else {
 		for _, b := range boxes {
-			renderedBoxes += fmt.Sprintf("- %s\n", b.Name)
+			renderedBoxes += fmt.Sprintf("- %s%s\n", b.Name, boxProtection(store, b.Name))
 		}
 	}
 	boxesLine := lipgloss.JoinHorizontal(
 		lipgloss.Top,
-		keyStyle.Render(renderedBoxes),
+		keyStyle.UnsetWidth().Render(renderedBoxes),
 	)
 	// content.WriteString(statusLine + "\n")
 	content.WriteString(boxesLine)
@@ -197,6 +205,33 @@ func DisplayEnvironmentInfo() {
 	fmt.Println(final)
 }
 
+// boxProtection describes how the box is opened, it can be read without the
+// password
+func boxProtection(store vault.BoxStore, name string) string {
+	data, _, err := store.Read(context.Background(), name)
+	if err != nil {
+		return ""
+	}
+	var marks []string
+	data, proto := security.UnmarkProto(data)
+	if !proto {
+		marks = append(marks, "yaml")
+	}
+	if security.IsSharedBox(data) || security.IsSharedIndexedBox(data) {
+		marks = append(marks, "shared")
+	}
+	if security.IsIndexedBox(data) {
+		marks = append(marks, "indexed")
+	}
+	if security.RequiresKeyFile(data) {
+		marks = append(marks, "requires key file")
+	}
+	if len(marks) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(marks, ", ") + ")"
+}
+
 // Helper function to get emoji for log levels
 func getLogLevelEmoji(level string) string {
 	switch strings.ToUpper(level) {
@@ -216,7 +251,7 @@ func getLogLevelEmoji(level string) string {
 }
 
 // Helper function to determine configuration status
-func getBoxes() (string, []utils.Box, error) {
+func getBoxes() (string, []vault.Box, error) {
 	return list.ListBoxes("")
 }
 
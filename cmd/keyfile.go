@@ -0,0 +1,40 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/mas2020-golang/cryptex/packages/security"
+	"github.com/mas2020-golang/cryptex/packages/utils"
+	"github.com/spf13/cobra"
+)
+
+func newKeyfileCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "keyfile",
+		Short: "Manage the key files",
+		Long: `A key file is a second factor: the boxes and the files protected by a key file
+can be opened only with both the password and the key file. Give the key file with
+--keyfile or the RAPTOR_KEYFILE env variable, keep it apart from the boxes (e.g. on a
+USB stick): losing it means losing the data.`,
+	}
+
+	generate := &cobra.Command{
+		Use:     "generate <PATH>",
+		Args:    cobra.ExactArgs(1),
+		Short:   "Generate a new random key file",
+		Example: `$ raptor keyfile generate /media/usb/raptor.key`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := security.GenerateKeyFile(args[0]); err != nil {
+				return err
+			}
+			utils.Success(fmt.Sprintf("key file written into %s", args[0]))
+			return nil
+		},
+	}
+	c.AddCommand(generate)
+
+	return c
+}
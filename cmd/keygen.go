@@ -0,0 +1,100 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mas2020-golang/cryptex/packages/security"
+	"github.com/mas2020-golang/cryptex/packages/utils"
+	"github.com/mas2020-golang/cryptex/pkg/vault"
+	"github.com/mas2020-golang/goutils/output"
+	"github.com/spf13/cobra"
+)
+
+// recipientItem is the item of the identity secret holding the public key
+const recipientItem = "recipient"
+
+func newKeygenCmd() *cobra.Command {
+	var boxName, name string
+	c := &cobra.Command{
+		Use:   "keygen",
+		Args:  cobra.NoArgs,
+		Short: "Generate a key pair to receive encrypted files",
+		Long: `Generate an X25519 key pair (age format) and store it as a secret in the box.
+The secret key is the password of the secret, the public key is the 'recipient' item.
+Share the printed public key: the files encrypted with 'raptor encrypt --recipient <KEY>'
+can be decrypted by 'raptor decrypt --box <BOX>' or by any age implementation.`,
+		Example: `$ raptor keygen --box test
+$ raptor keygen --box test --name work-key`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			recipient, err := keygen(cmd.Context(), boxName, name)
+			if err != nil {
+				return err
+			}
+			utils.Success(output.BoldS("box saved!"))
+			fmt.Printf("Public key: %s\n", recipient)
+			return nil
+		},
+	}
+	c.Flags().StringVarP(&boxName, "box", "b", "", "The name of the box where to store the key pair")
+	c.Flags().StringVarP(&name, "name", "n", "age-identity", "The name of the secret holding the key pair")
+
+	return c
+}
+
+// keygen stores a new identity in the box and returns its public key
+func keygen(ctx context.Context, boxName, name string) (string, error) {
+	v, err := utils.OpenBox(ctx, boxName, "")
+	if err != nil {
+		return "", err
+	}
+	defer utils.CloseBox(ctx, v)
+	box := v.Box()
+	if box.Secret(name) != nil {
+		return "", fmt.Errorf("a secret with the name %s already exists", name)
+	}
+
+	identity, recipient, err := security.GenerateIdentity()
+	if err != nil {
+		return "", err
+	}
+	box.Put(&vault.Secret{
+		Name:        name,
+		Pwd:         identity,
+		Version:     "1.0.0",
+		Others:      map[string]string{recipientItem: recipient},
+		Tags:        []string{"age"},
+		LastUpdated: time.Now().Format(time.RFC3339),
+	})
+	return recipient, v.Save(ctx)
+}
+
+// boxIdentities returns the age secret keys stored in the box. Nothing is
+// returned when no box is given and CRYPTEX_BOX is not set.
+func boxIdentities(ctx context.Context, boxName string) ([]string, error) {
+	if len(boxName) == 0 && len(os.Getenv("CRYPTEX_BOX")) == 0 {
+		return nil, nil
+	}
+	v, err := utils.OpenBox(ctx, boxName, "")
+	if err != nil {
+		return nil, err
+	}
+	defer utils.CloseBox(ctx, v)
+	box := v.Box()
+	var identities []string
+	for _, s := range box.Secrets {
+		if err := s.Load(); err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(s.Pwd, security.AgeIdentityPrefix) {
+			identities = append(identities, s.Pwd)
+		}
+	}
+	return identities, nil
+}
@@ -21,14 +21,24 @@ var (
 
 func newListCmd() *cobra.Command {
 	c := &cobra.Command{
-		Use:     "ls",
+		Use:     "ls [ARCHIVE]",
 		Aliases: []string{"list"},
 		Short:   "Show the specified raptor objects",
-		Long:    `Show the specified raptor objects: boxes, secrets, items`,
+		Long: `Show the specified raptor objects: boxes, secrets, items.
+Given a .raptor archive, its content is listed without writing anything on the disk.`,
+		Example: `$ raptor ls boxes
+$ raptor ls docs.raptor`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				return cmd.Help()
+			}
+			return list.RunListArchive(args[0])
+		},
 	}
 	// Here you will define your flags and configuration settings.
 	c.AddCommand(list.NewListBoxCmd())
-	c.AddCommand(list.ListSecretCmd)
+	c.AddCommand(list.NewListSecretCmd())
 	c.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "to get more information use the verbose mode")
 
 	return c
@@ -0,0 +1,35 @@
+package list
+
+import (
+	"archive/tar"
+	"fmt"
+	"os"
+
+	"github.com/mas2020-golang/cryptex/packages/security"
+	"github.com/mas2020-golang/cryptex/packages/utils"
+)
+
+// RunListArchive prints the content of an encrypted archive without
+// extracting it
+func RunListArchive(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("error accessing the path %s: %v", path, err)
+	}
+	pwd, err := utils.AskForPassword("Password: ", false)
+	if err != nil {
+		return err
+	}
+
+	var count int
+	var size int64
+	err = security.ListArchive(path, pwd, func(hdr *tar.Header) {
+		fmt.Println(security.ArchiveEntry(hdr))
+		count++
+		size += hdr.Size
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d entries, %d bytes\n", count, size)
+	return nil
+}
@@ -4,13 +4,13 @@ Copyright © 2022 NAME HERE <EMAIL ADDRESS>
 package list
 
 import (
+	"context"
 	"fmt"
-	"io/fs"
-	"os"
 	"regexp"
 	"strconv"
 
 	"github.com/mas2020-golang/cryptex/packages/utils"
+	"github.com/mas2020-golang/cryptex/pkg/vault"
 	"github.com/spf13/cobra"
 )
 
@@ -22,8 +22,8 @@ func NewListBoxCmd() *cobra.Command {
 		Use:     "boxes",
 		Aliases: []string{"bo", "box"},
 		Short:   "List the boxes",
-		Long: `List the boxes present in the box folder. You can filter
-using a regular expression.`,
+		Long: `List the boxes present in the box folder, or in the store given with --store or
+RAPTOR_STORE. You can filter using a regular expression.`,
 		Example: `$ cryptex ls box --filter 'test.*'`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return runListBoxes(filter)
@@ -45,61 +45,41 @@ func runListBoxes(filter string) error {
 	return nil
 }
 
-func printBoxes(boxes []utils.Box) {
+func printBoxes(boxes []vault.Box) {
 	fmt.Printf("%-25s%s\n", "NAME", "SIZE")
 	for _, b := range boxes {
 		fmt.Printf("%-25s%s\n", b.Name, strconv.FormatInt(b.Size, 10))
 	}
 }
 
-func ListBoxes(filter string) (string, []utils.Box, error) {
-	folderBox, err := utils.InitFolderBox()
+// ListBoxes returns the location of the store (see utils.Store) and its
+// boxes whose names match the filter
+func ListBoxes(filter string) (string, []vault.Box, error) {
+	store, err := utils.Store()
 	if err != nil {
-		return folderBox, nil, err
+		return "", nil, err
 	}
+	location := fmt.Sprint(store)
 
-	files, err := os.ReadDir(folderBox)
+	infos, err := store.List(context.Background())
 	if err != nil {
-		return folderBox, nil, fmt.Errorf("failed to read directory %s: %w", folderBox, err)
+		return location, nil, err
 	}
 
 	var filterRegex *regexp.Regexp
 	if filter != "" {
 		filterRegex, err = regexp.Compile(filter)
 		if err != nil {
-			return folderBox, nil, fmt.Errorf("invalid regex pattern '%s': %w", filter, err)
+			return location, nil, fmt.Errorf("invalid regex pattern '%s': %w", filter, err)
 		}
 	}
 
-	var boxes []utils.Box
-	for _, file := range files {
-		if shouldIncludeFile(file, filterRegex) {
-			box, err := createBoxFromFile(file)
-			if err != nil {
-				return folderBox, nil, fmt.Errorf("failed to process file %s: %w", file.Name(), err)
-			}
-			boxes = append(boxes, box)
+	var boxes []vault.Box
+	for _, info := range infos {
+		if filterRegex == nil || filterRegex.MatchString(info.Name) {
+			boxes = append(boxes, vault.Box{Name: info.Name, Size: info.Size})
 		}
 	}
 
-	return folderBox, boxes, nil
-}
-
-func shouldIncludeFile(file fs.DirEntry, filterRegex *regexp.Regexp) bool {
-	if filterRegex == nil {
-		return true
-	}
-	return filterRegex.MatchString(file.Name())
-}
-
-func createBoxFromFile(file fs.DirEntry) (utils.Box, error) {
-	fileInfo, err := file.Info()
-	if err != nil {
-		return utils.Box{}, err
-	}
-
-	return utils.Box{
-		Name: file.Name(),
-		Size: fileInfo.Size(),
-	}, nil
+	return location, boxes, nil
 }
@@ -11,15 +11,11 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/lipgloss/table"
 	"github.com/mas2020-golang/cryptex/packages/utils"
+	"github.com/mas2020-golang/cryptex/pkg/vault"
 	"github.com/mas2020-golang/goutils/output"
 	"github.com/spf13/cobra"
 )
 
-var (
-	items           bool
-	boxName, filter string
-)
-
 var (
 	purple    = lipgloss.Color("99")
 	gray      = lipgloss.Color("245")
@@ -32,41 +28,54 @@ var (
 	messageStyle = lipgloss.NewStyle().Bold(true).Foreground(lightGray)
 )
 
-// boxCmd represents the box command
-var ListSecretCmd = &cobra.Command{
-	Use:     "secrets",
-	Aliases: []string{"secret", "sr"},
-	Short:   "List secret",
-	Long: `List all the secret in the --box given flag. Use the flag --name
+// NewListSecretCmd creates and returns the list secrets command
+func NewListSecretCmd() *cobra.Command {
+	var (
+		items           bool
+		boxName, filter string
+	)
+	c := &cobra.Command{
+		Use:     "secrets",
+		Aliases: []string{"secret", "sr"},
+		Short:   "List secret",
+		Long: `List all the secret in the --box given flag. Use the flag --name
 to filter using a regular expression.`,
-	Example: `$ raptor secret ls --box test
+		Example: `$ raptor secret ls --box test
 $ raptor secret ls --box test --name '^secret.*test$`,
-	Run: func(cmd *cobra.Command, args []string) {
-		listSecrets(cmd)
-	},
-}
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return listSecrets(cmd, boxName, filter, items)
+		},
+	}
+	c.Flags().StringVarP(&filter, "filter", "f", "", "The secret name as a regexp (e.g. 'test.*')")
+	c.Flags().BoolVarP(&items, "items", "i", false, "Show the items' keys for the items saved into the secret")
+	c.PersistentFlags().StringVarP(&boxName, "box", "b", "", "The name of the box where to add the secret")
 
-func init() {
-	ListSecretCmd.Flags().StringVarP(&filter, "filter", "f", "", "The secret name as a regexp (e.g. 'test.*')")
-	ListSecretCmd.Flags().BoolVarP(&items, "items", "i", false, "Show the items' keys for the items saved into the secret")
-	ListSecretCmd.PersistentFlags().StringVarP(&boxName, "box", "b", "", "The name of the box where to add the secret")
+	return c
 }
 
-func listSecrets(cmd *cobra.Command) {
-	// load the local timezone
-	// loc, err := time.LoadLocation("Local")
-	// utils.Check(err, "")
+func listSecrets(cmd *cobra.Command, boxName, filter string, items bool) error {
 	// output variables
 	name, version, url, login := "", "", "", ""
-	boxPath, _, box, err := utils.OpenBox(boxName, "")
-	utils.Check(err, "")
+	ctx := cmd.Context()
+	v, err := utils.OpenBox(ctx, boxName, "")
+	if err != nil {
+		return err
+	}
+	defer utils.CloseBox(ctx, v)
+	box := v.Box()
 	// get the max length for the NAME, LOGIN attribute
 	maxName := getMaxNameLenght(box)
 	maxLogin := getMaxLoginLenght(box)
 
 	if len(box.Secrets) == 0 {
 		fmt.Println(messageStyle.Render("No secrets yet..."))
-		return
+		return nil
+	}
+	var r *regexp.Regexp
+	if len(filter) > 0 {
+		if r, err = regexp.Compile("(?i)" + filter); err != nil { // case insensitive regexp
+			return fmt.Errorf("invalid filter: %v", err)
+		}
 	}
 	// table format
 	t := table.New().
@@ -94,43 +103,32 @@ func listSecrets(cmd *cobra.Command) {
 		name = output.RedS(output.BoldS(fmt.Sprintf(nameFormatS, s.Name)))
 		lastUpdated := s.LastUpdated
 		// check the name flag
-		if len(filter) > 0 {
-			r, _ := regexp.Compile("(?i)" + filter) // case insensitive regexp
-			if err != nil {
-				output.Error("", fmt.Sprintf("Invalid filter: %v\n", err))
-				return
-			}
-			if r.MatchString(name) {
-				t.Row(name, version, login, url, strconv.Itoa(len(s.Others)), lastUpdated)
-				showItems(s, t)
-			}
-		} else {
-			t.Row(name, version, login, url, strconv.Itoa(len(s.Others)), lastUpdated)
-			showItems(s, t)
+		if r == nil || r.MatchString(name) {
+			t.Row(name, version, login, url, strconv.Itoa(len(s.Items())), lastUpdated)
+			showItems(s, t, items)
 		}
 	}
-	v, _ := (*cmd).Parent().Flags().GetBool("verbose")
-	if v {
+	verbose, _ := (*cmd).Parent().Flags().GetBool("verbose")
+	if verbose {
 		fmt.Println()
-		output.InfoBox(fmt.Sprintf("secret read from the %s box\n", output.BlueS(boxPath)))
+		output.InfoBox(fmt.Sprintf("secret read from the %s box\n", output.BlueS(v.Source())))
 	}
 
 	fmt.Println(t.Render())
+	return nil
 }
 
-func showItems(s *utils.Secret, t *table.Table) {
+func showItems(s *vault.Secret, t *table.Table, items bool) {
 	if !items {
 		return
 	}
-	if len(s.Others) > 0 {
-		for k := range s.Others {
-			t.Row("", "", fmt.Sprintf(" .%s", output.BoldS(k)), "", "", "")
-		}
+	for _, k := range s.Items() {
+		t.Row("", "", fmt.Sprintf(" .%s", output.BoldS(k)), "", "", "")
 	}
 }
 
 // getMaxNameLenght return the max lenght for the NAME attribute
-func getMaxNameLenght(box *utils.Box) int {
+func getMaxNameLenght(box *vault.Box) int {
 	max := 10
 	for _, s := range box.Secrets {
 		if len(s.Name) > max {
@@ -141,7 +139,7 @@ func getMaxNameLenght(box *utils.Box) int {
 }
 
 // getMaxLoginLenght return the max lenght for the LOGIN attribute
-func getMaxLoginLenght(box *utils.Box) int {
+func getMaxLoginLenght(box *vault.Box) int {
 	max := 10
 	for _, s := range box.Secrets {
 		if len(s.Login) > max {
@@ -1,6 +1,7 @@
 package nav
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os/exec"
@@ -9,6 +10,7 @@ import (
 	"github.com/atotto/clipboard"
 	"github.com/mas2020-golang/cryptex/internal/secretutil"
 	"github.com/mas2020-golang/cryptex/packages/utils"
+	"github.com/mas2020-golang/cryptex/pkg/vault"
 	"github.com/mas2020-golang/goutils/output"
 	"github.com/spf13/cobra"
 )
@@ -26,8 +28,9 @@ func NewCmd() *cobra.Command {
 copy the secret password to the clipboard.`,
 		Example: `$ raptor nav foo --box test // open foo secret URL and copy the password
 $ raptor nav foo.bar --box test // open the foo secret URL and copy the password`,
-		Run: func(cmd *cobra.Command, args []string) {
-			runNav(boxName, args[0])
+		ValidArgsFunction: secretutil.CompleteNames,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runNav(cmd.Context(), boxName, args[0])
 		},
 	}
 
@@ -36,27 +39,27 @@ $ raptor nav foo.bar --box test // open the foo secret URL and copy the password
 	return cmd
 }
 
-func runNav(boxName, name string) {
-	result, _, err := secretutil.Lookup(boxName, name)
-	utils.Check(err, "")
+func runNav(ctx context.Context, boxName, name string) error {
+	result, v, err := secretutil.Lookup(ctx, boxName, name)
+	if err != nil {
+		return err
+	}
+	defer utils.CloseBox(ctx, v)
 
-	if result == nil || result.Secret == nil {
-		output.Warning("", fmt.Sprintf("no secret %q found in %s", name, boxName))
-		return
+	if result.Secret == nil {
+		return fmt.Errorf("%w: %q", vault.ErrSecretNotFound, name)
 	}
 
 	// When the user refers to an item, ensure it exists.
 	if result.Item != "" && len(result.Value) == 0 {
-		output.Warning("", fmt.Sprintf("no secret %q found in %s", name, boxName))
-		return
+		return fmt.Errorf("%w: no item %q in the secret %s", vault.ErrSecretNotFound, result.Item, result.Secret.Name)
 	}
 
 	secretPwd := result.Secret.Pwd
 
 	if result.Secret.Url != "" {
-		if err := openBrowser(result.Secret.Url); err != nil {
-			output.Error("", fmt.Sprintf("failed to open the browser: %v", err))
-			return
+		if err := OpenBrowser(result.Secret.Url); err != nil {
+			return fmt.Errorf("failed to open the browser: %v", err)
 		}
 	} else {
 		output.Warning("", fmt.Sprintf("secret %q does not define a URL to open", result.Secret.Name))
@@ -64,19 +67,20 @@ func runNav(boxName, name string) {
 
 	if len(secretPwd) == 0 {
 		output.Warning("", fmt.Sprintf("secret %q does not have a password to copy", result.Secret.Name))
-		return
+		return nil
 	}
 
 	if err := clipboard.WriteAll(secretPwd); err != nil {
-		output.Error("", err.Error())
-		return
+		return err
 	}
 
 	fmt.Println()
 	utils.Success(output.BoldS("the secret password is in your clipboard"))
+	return nil
 }
 
-func openBrowser(url string) error {
+// OpenBrowser opens the URL with the default browser of the system
+func OpenBrowser(url string) error {
 	if len(url) == 0 {
 		return errors.New("empty URL")
 	}
@@ -4,15 +4,12 @@ Copyright © 2022 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
-	"bufio"
+	"context"
 	"fmt"
-	"log/slog"
 	"os"
 	"strconv"
-	"strings"
 	"time"
 
-	"github.com/mas2020-golang/cryptex/packages/ui"
 	"github.com/mas2020-golang/cryptex/packages/utils"
 	"github.com/mas2020-golang/goutils/output"
 	"github.com/spf13/cobra"
@@ -36,16 +33,12 @@ You can open a box giving:
 If you omit the name raptor will try to fetch the CRYPTEX_BOX env variable value.
 If any of the previous checks doesn't give the right path raptor will throw an error.`,
 		Example: `$ raptor open 'test'`,
-		Run: func(cmd *cobra.Command, args []string) {
+		RunE: func(cmd *cobra.Command, args []string) error {
 			boxName := ""
 			if len(args) != 0 {
 				boxName = args[0]
 			}
-
-			if err := interactiveOpen(boxName); err != nil {
-				output.Error("", err.Error())
-				os.Exit(1)
-			}
+			return interactiveOpen(cmd.Context(), boxName)
 		},
 	}
 	// Here you will define your flags and configuration settings.
@@ -54,90 +47,30 @@ If any of the previous checks doesn't give the right path raptor will throw an e
 	return c
 }
 
-func interactiveOpen(boxName string) error {
-	inputChan := make(chan string)
-	doneChan := make(chan bool)
+func interactiveOpen(ctx context.Context, boxName string) error {
+	if v := utils.Session(ctx); v != nil {
+		return fmt.Errorf("the box %s is already open", v.Source())
+	}
 	secStringTimeout := os.Getenv("RAPTOR_TIMEOUT_SEC")
 	timeout := 600 * time.Second
 	if len(secStringTimeout) > 0 {
 		// Convert the string to an integer
 		secsTimeout, err := strconv.Atoi(secStringTimeout)
 		if err != nil {
-			output.Warning("", fmt.Sprintf("Error converting RAPTOR_LOGLEVEL to int: %v", err))
-			return nil
+			return fmt.Errorf("error converting RAPTOR_TIMEOUT_SEC to int: %v", err)
 		}
 		timeout = time.Duration(secsTimeout) * time.Second
 	}
 	output.InfoBox(fmt.Sprintf("RAPTOR_TIMEOUT_SEC is set to %v", timeout))
 
 	// open the box
-	exit := false
-	_, _, box, err := utils.OpenBox(boxName, pwd)
-	utils.BufferBox = box
-	utils.Check(err, "")
-	output.Success("Box is ready for you!")
-
-	scanner := bufio.NewScanner(os.Stdin)
-
-	go func() {
-		for {
-			slog.Debug("waiting input...")
-			fmt.Print("raptor> ")
-			if !scanner.Scan() {
-				// Handle error or end of input
-				break
-			}
-			input := strings.TrimSpace(scanner.Text())
-			if err != nil {
-				output.Error("", fmt.Sprintf("error reading input: %v", err))
-				close(inputChan)
-				return
-			}
-
-			if err := scanner.Err(); err != nil {
-				return
-			}
-			slog.Debug("writing into the channel", "input", input)
-			inputChan <- input
-			slog.Debug("waiting for done channel")
-			<-doneChan // Wait for processing to complete
-		}
-	}()
-
-	for {
-		select {
-		case input, ok := <-inputChan:
-			slog.Debug("input read from the channel", "input", input)
-			if !ok {
-				output.Warning("", "input channel closed, exiting")
-				return nil
-			}
-			switch input {
-			case "quit", "q", "exit", "bye":
-				fmt.Println("see you for the next secret to whisper...")
-				exit = true
-			}
-			if exit {
-				return nil
-			}
-
-			switch input {
-			case "clear", "cl", "wipe", "clean":
-				ui.ClearScreen()
-				doneChan <- true // Signal that processing is complete
-				continue
-			}
-
-			// fetch the command
-			ui.ClearScreen()
-			os.Args = []string{"raptor"}
-			os.Args = append(os.Args, strings.Split(input, " ")...)
-			slog.Debug("executing the command", "args", os.Args)
-			rootCmd.Execute()
-			doneChan <- true // Signal that processing is complete
-		case <-time.After(timeout):
-			fmt.Printf("no input received for %v, exiting\n", timeout)
-			return nil
-		}
+	v, err := utils.OpenBox(ctx, boxName, pwd)
+	if err != nil {
+		return err
 	}
+	output.Success("Box is ready for you! (TAB completes, 'history' lists the commands, 'lock' locks the box, 'quit' exits)")
+
+	s := newShell(os.Stdin, os.Stdout, v)
+	defer s.close()
+	return s.run(timeout)
 }
@@ -7,68 +7,73 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/mas2020-golang/cryptex/internal/secretutil"
 	"github.com/mas2020-golang/cryptex/packages/utils"
+	"github.com/mas2020-golang/cryptex/pkg/vault"
 	"github.com/mas2020-golang/goutils/output"
 	"github.com/spf13/cobra"
 )
 
-var (
-	unsecure bool
-	boxName  string
-)
-
-// boxCmd represents the box command
-var PrintSecretCmd = &cobra.Command{
-	Use:     "secret <NAME>",
-	Aliases: []string{"sr", "s"},
-	Args:    cobra.MinimumNArgs(1),
-	Short:   "Print the info of a secret",
-	Long: `Print all the info related to the secret. If you specify --unsecure flag you will get also the sensitive
+// NewSecretCmd creates and returns the print secret command
+func NewSecretCmd() *cobra.Command {
+	var (
+		unsecure bool
+		boxName  string
+	)
+	c := &cobra.Command{
+		Use:     "secret <NAME>",
+		Aliases: []string{"sr", "s"},
+		Args:    cobra.MinimumNArgs(1),
+		Short:   "Print the info of a secret",
+		Long: `Print all the info related to the secret. If you specify --unsecure flag you will get also the sensitive
 information in clear on the screen (use it carefully)`,
-	Example: `$ cryptex secret print foo --box test // to print the info of the foo secret`,
-	Run: func(cmd *cobra.Command, args []string) {
-		print(args[0], cmd)
-	},
-}
+		Example:           `$ cryptex secret print foo --box test // to print the info of the foo secret`,
+		ValidArgsFunction: secretutil.CompleteNames,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return print(boxName, args[0], unsecure, cmd)
+		},
+	}
+	c.Flags().BoolVarP(&unsecure, "unsecure", "u", false, "If passed sensitive info are shown")
+	c.PersistentFlags().StringVarP(&boxName, "box", "b", "", "The name of the box where to add the secret")
 
-func init() {
-	PrintSecretCmd.Flags().BoolVarP(&unsecure, "unsecure", "u", false, "If passed sensitive info are shown")
-	PrintSecretCmd.PersistentFlags().StringVarP(&boxName, "box", "b", "", "The name of the box where to add the secret")
+	return c
 }
 
-func print(name string, cmd *cobra.Command) {
+func print(boxName, name string, unsecure bool, cmd *cobra.Command) error {
 	// open the box
-	boxPath, _, box, err := utils.OpenBox(boxName, "")
-	utils.Check(err, "")
+	ctx := cmd.Context()
+	v, err := utils.OpenBox(ctx, boxName, "")
+	if err != nil {
+		return err
+	}
+	defer utils.CloseBox(ctx, v)
 
 	// get the secret
-	s, err := getSecret(name, box)
+	s, err := getSecret(name, v.Box())
 	if err != nil {
-		output.Error("", err.Error())
-		return
+		return err
 	}
-	showToStdOut(s, &unsecure, cmd, boxPath)
+	showToStdOut(s, unsecure, cmd, v.Source())
+	return nil
 }
 
 // getSecret searches the secret into the box.
-func getSecret(name string, box *utils.Box) (*utils.Secret, error) {
+func getSecret(name string, box *vault.Box) (*vault.Secret, error) {
 	if len(box.Secrets) == 0 {
-		return nil, fmt.Errorf("no secret '%s' found in the box", name)
+		return nil, fmt.Errorf("%w: %q", vault.ErrSecretNotFound, name)
 	}
-	for _, secret := range box.Secrets {
-		if secret.Name == name {
-			return secret, nil
-		}
+	if secret := box.Secret(name); secret != nil {
+		return secret, secret.Load()
 	}
-	return nil, fmt.Errorf("no secret '%s' found in the box", name)
+	return nil, fmt.Errorf("%w: %q", vault.ErrSecretNotFound, name)
 }
 
-func showToStdOut(s *utils.Secret, unsecure *bool, cmd *cobra.Command, boxPath string) {
+func showToStdOut(s *vault.Secret, unsecure bool, cmd *cobra.Command, boxPath string) {
 	lastUpdated := s.LastUpdated
 	fmt.Println(output.GreenS(strings.Repeat("-", 35)))
 	fmt.Printf("%s %s\n", output.BlueS("Version:"), s.Version)
 	fmt.Printf("%s %s\n", output.BlueS("Login:"), s.Login)
-	if *unsecure {
+	if unsecure {
 		fmt.Printf("%s %s\n", output.BlueS("Pwd:"), s.Pwd)
 	} else {
 		fmt.Printf("%s %s\n", output.BlueS("Pwd:"), "---------")
@@ -79,7 +84,7 @@ func showToStdOut(s *utils.Secret, unsecure *bool, cmd *cobra.Command, boxPath s
 	if len(s.Others) > 0 {
 		output.Bold("Items:\n")
 		for k, v := range s.Others {
-			if *unsecure {
+			if unsecure {
 				fmt.Printf("%-2s.%s -> %s\n", "", output.BlueS(k), v)
 			} else {
 				fmt.Printf("%-2s.%s\n", "", output.BlueS(k))
@@ -92,7 +97,4 @@ func showToStdOut(s *utils.Secret, unsecure *bool, cmd *cobra.Command, boxPath s
 		fmt.Printf("\n%s\n", output.YellowS("additional info:"))
 		output.InfoBox(fmt.Sprintf("secret read from the %s box\n", output.BlueS(boxPath)))
 	}
-
-	// for interactive mode only
-	*unsecure = false
 }
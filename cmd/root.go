@@ -4,31 +4,27 @@ Copyright © 2022 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"errors"
+	"fmt"
 	"os"
 
+	"github.com/mas2020-golang/cryptex/packages/utils"
+	"github.com/mas2020-golang/goutils/output"
 	"github.com/spf13/cobra"
 )
 
-var (
-	verbose    bool
-	createCmd  *cobra.Command
-	listCmd    *cobra.Command
-	getCmd     *cobra.Command
-	editCmd    *cobra.Command
-	deleteCmd  *cobra.Command
-	printCmd   *cobra.Command
-	openCmd    *cobra.Command
-	encryptCmd *cobra.Command
-	decryptCmd *cobra.Command
-	infoCmd    *cobra.Command
-	navCmd     *cobra.Command
-)
+var verbose bool
 
 // rootCmd represents the base command when called without any subcommands
-var rootCmd = &cobra.Command{
-	Use:   "raptor <command> [flags]",
-	Short: "Use raptor to keep safe your personal information",
-	Long: `Raptor is a Go-based application designed to securely store your personal information within an encrypted "box."
+var rootCmd = newRootCmd()
+
+// newRootCmd creates the command tree. The interactive mode creates a new one
+// for every command line, so that the flags start from their default values.
+func newRootCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "raptor <command> [flags]",
+		Short: "Use raptor to keep safe your personal information",
+		Long: `Raptor is a Go-based application designed to securely store your personal information within an encrypted "box."
 By leveraging robust encryption techniques, SecureBox ensures that your sensitive data remains confidential and accessible
 only to you.
 
@@ -37,7 +33,56 @@ Features:
 
     - Password Protection: Access your encrypted box using a password, providing an additional layer of security.
 
-    - Data Integrity: Ensures that your personal information remains intact and unaltered during storage and retrieval.`,
+    - Data Integrity: Ensures that your personal information remains intact and unaltered during storage and retrieval.
+
+Exit codes:
+    0 success, 1 error, 2 wrong password or missing key file, 3 box not found, 4 secret not found,
+    5 corrupt box, 6 session ended with the box locked, 7 box changed by someone else since it was opened`,
+		// the errors are printed by the caller, the usage only for wrong flags or args
+		SilenceErrors: true,
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			cmd.SilenceUsage = true
+		},
+	}
+	c.AddGroup(&cobra.Group{ID: "boxes", Title: "Box Management"})
+	c.AddGroup(&cobra.Group{ID: "encryption", Title: "Secret Operations"})
+
+	for _, sub := range []*cobra.Command{
+		newCreateCmd(),
+		newListCmd(),
+		newGetCmd(),
+		newEditCmd(),
+		newDeleteCmd(),
+		newPrintCmd(),
+		newOpenCmd(),
+		newUICmd(),
+		newNavCmd(),
+		newImportCmd(),
+		newExportCmd(),
+		newBoxCmd(),
+		newServeCmd(),
+	} {
+		sub.GroupID = "boxes"
+		c.AddCommand(sub)
+	}
+	for _, sub := range []*cobra.Command{
+		newEncryptCmd(),
+		newDecryptCmd(),
+		newKeygenCmd(),
+		newKeyfileCmd(),
+		newShredCmd(),
+		newVerifyCmd(),
+	} {
+		sub.GroupID = "encryption"
+		c.AddCommand(sub)
+	}
+	c.AddCommand(newInfoCmd())
+	c.AddCommand(NewVersionCmd())
+
+	c.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Give more information about the command execution")
+	c.PersistentFlags().StringVar(&utils.KeyFilePath, "keyfile", "", "The key file required with the password (overrides RAPTOR_KEYFILE)")
+	c.PersistentFlags().StringVar(&utils.StoreURL, "store", "", "The store of the boxes: a folder, s3://bucket/prefix or davs://host/path (overrides RAPTOR_STORE)")
+	return c
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -45,48 +90,17 @@ Features:
 func Execute() {
 	err := rootCmd.Execute()
 	if err != nil {
-		os.Exit(1)
+		printError(err)
+		os.Exit(exitCode(err))
 	}
 }
 
-func init() {
-	rootCmd.AddGroup(&cobra.Group{ID: "boxes", Title: "Box Management"})
-	rootCmd.AddGroup(&cobra.Group{ID: "encryption", Title: "Secret Operations"})
-
-	createCmd = newCreateCmd()
-	listCmd = newListCmd()
-	getCmd = newGetCmd()
-	editCmd = newEditCmd()
-	deleteCmd = newDeleteCmd()
-	printCmd = newPrintCmd()
-	openCmd = newOpenCmd()
-	encryptCmd = newEncryptCmd()
-	decryptCmd = newDecryptCmd()
-	infoCmd = newInfoCmd()
-	navCmd = newNavCmd()
-
-	listCmd.GroupID = "boxes"
-	createCmd.GroupID = "boxes"
-	getCmd.GroupID = "boxes"
-	editCmd.GroupID = "boxes"
-	deleteCmd.GroupID = "boxes"
-	openCmd.GroupID = "boxes"
-	printCmd.GroupID = "boxes"
-	navCmd.GroupID = "boxes"
-	encryptCmd.GroupID = "encryption"
-	decryptCmd.GroupID = "encryption"
-
-	rootCmd.AddCommand(createCmd)
-	rootCmd.AddCommand(listCmd)
-	rootCmd.AddCommand(getCmd)
-	rootCmd.AddCommand(editCmd)
-	rootCmd.AddCommand(deleteCmd)
-	rootCmd.AddCommand(printCmd)
-	rootCmd.AddCommand(openCmd)
-	rootCmd.AddCommand(encryptCmd)
-	rootCmd.AddCommand(decryptCmd)
-	rootCmd.AddCommand(infoCmd)
-	rootCmd.AddCommand(navCmd)
-
-	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Give more information about the command execution")
+// printError prints the error returned by a command
+func printError(err error) {
+	var se streamError
+	if errors.As(err, &se) {
+		fmt.Fprintf(os.Stderr, "%s %s\n", output.RedBoldS("│ Error:"), err.Error())
+		return
+	}
+	output.Error("", err.Error())
 }
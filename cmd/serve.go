@@ -0,0 +1,105 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/mas2020-golang/cryptex/packages/server"
+	"github.com/mas2020-golang/cryptex/packages/utils"
+	"github.com/mas2020-golang/goutils/output"
+	"github.com/spf13/cobra"
+)
+
+type serveOptions struct {
+	boxName, socket, tokenFile string
+	write                      bool
+}
+
+func newServeCmd() *cobra.Command {
+	opts := serveOptions{}
+	c := &cobra.Command{
+		Use:   "serve",
+		Args:  cobra.NoArgs,
+		Short: "Serve the secrets of a box on a Unix socket",
+		Long: `Open the box and serve its secrets to the local tools on a Unix socket readable by
+the user only, until CTRL+C. The List, Get, Put, Delete and Search RPCs of the Secrets
+service (protos/api.proto) are served with the gRPC, gRPC-Web and Connect protocols:
+the Connect protocol is plain HTTP with JSON bodies.
+
+Every request needs the token of the session, printed on start (or written into
+--token-file), in the header "Authorization: Bearer <token>". The session is read-only
+unless --allow-write is given. List and Search never return the values of the
+secrets, Get refuses the secrets not exportable by the policy of the box (see
+'raptor box policy').`,
+		Example: `$ raptor serve --box test --socket /run/user/1000/raptor.sock
+$ raptor serve --box test --socket ~/.raptor.sock --token-file ~/.raptor.token --allow-write
+$ curl --unix-socket ~/.raptor.sock -H "Authorization: Bearer $(cat ~/.raptor.token)" \
+    -H 'Content-Type: application/json' -d '{"name": "github"}' http://raptor/raptor.Secrets/Get`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return serve(cmd.Context(), opts)
+		},
+	}
+	c.Flags().StringVarP(&opts.boxName, "box", "b", "", "The name of the box to serve")
+	c.Flags().StringVarP(&opts.socket, "socket", "s", "", "The path of the Unix socket")
+	c.Flags().StringVarP(&opts.tokenFile, "token-file", "t", "", "Write the token into this file (readable by the user only) instead of printing it")
+	c.Flags().BoolVarP(&opts.write, "allow-write", "w", false, "Allow the Put and Delete RPCs")
+	c.MarkFlagRequired("socket")
+
+	return c
+}
+
+func serve(ctx context.Context, opts serveOptions) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	v, err := utils.OpenBox(ctx, opts.boxName, "")
+	if err != nil {
+		return err
+	}
+	defer utils.CloseBox(ctx, v)
+	s, err := server.New(v, opts.write)
+	if err != nil {
+		return err
+	}
+	if len(opts.tokenFile) > 0 {
+		if err := writeToken(opts.tokenFile, s.Token()); err != nil {
+			return err
+		}
+		defer os.Remove(opts.tokenFile)
+	} else {
+		fmt.Printf("token: %s\n", s.Token())
+	}
+
+	mode := "read-only"
+	if opts.write {
+		mode = "read-write"
+	}
+	utils.Success(fmt.Sprintf("serving the box %s on %s (%s), press CTRL+C to stop", output.BoldS(v.Box().Name), opts.socket, mode))
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return s.Serve(ctx, opts.socket)
+}
+
+// writeToken writes the token into a new file readable by the user only, an
+// existing file is not reused as it may be readable by others
+func writeToken(path, token string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("the token file %s already exists, remove it if no server is using it", path)
+		}
+		return fmt.Errorf("failed to write the token: %v", err)
+	}
+	if _, err := f.WriteString(token + "\n"); err != nil {
+		f.Close()
+		os.Remove(path)
+		return fmt.Errorf("failed to write the token: %v", err)
+	}
+	return f.Close()
+}
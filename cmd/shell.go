@@ -0,0 +1,552 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mas2020-golang/cryptex/packages/ui"
+	"github.com/mas2020-golang/cryptex/packages/utils"
+	"github.com/mas2020-golang/cryptex/pkg/vault"
+	"github.com/mas2020-golang/goutils/output"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"golang.org/x/term"
+)
+
+const (
+	shellPrompt    = "raptor> "
+	passwordPrompt = "Password: "
+	// lockWarning is how long before the lock the shell warns, at most half of
+	// the timeout
+	lockWarning = 30 * time.Second
+)
+
+// errUnterminated is returned splitting a line with an open quote
+var errUnterminated = errors.New("unterminated quote")
+
+// word is a word of a command line
+type word struct {
+	text string
+	// start is the offset of the word in the line
+	start int
+}
+
+// scanWords splits the line in words like a shell does: the words are
+// separated by blanks, the single quotes keep the text as it is, the double
+// quotes keep it but \" and \\, outside the quotes the backslash escapes any
+// char. open is true when the line ends inside a word.
+func scanWords(line string) (words []word, open bool, err error) {
+	var (
+		text   strings.Builder
+		quote  rune
+		escape bool
+	)
+	for i, r := range line {
+		switch {
+		case escape:
+			if quote == '"' && r != '"' && r != '\\' {
+				text.WriteRune('\\')
+			}
+			text.WriteRune(r)
+			escape = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				text.WriteRune(r)
+			}
+		case r == '\\':
+			escape = true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				text.WriteRune(r)
+			}
+		case r == ' ' || r == '\t':
+			if open {
+				words[len(words)-1].text = text.String()
+				text.Reset()
+				open = false
+			}
+			continue
+		case r == '\'' || r == '"':
+			quote = r
+		default:
+			text.WriteRune(r)
+		}
+		if !open {
+			words = append(words, word{start: i})
+			open = true
+		}
+	}
+	if escape {
+		text.WriteRune('\\')
+	}
+	if open {
+		words[len(words)-1].text = text.String()
+	}
+	if quote != 0 {
+		err = errUnterminated
+	}
+	return words, open, err
+}
+
+// splitWords splits the command line in its arguments
+func splitWords(line string) ([]string, error) {
+	words, _, err := scanWords(line)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, len(words))
+	for i, w := range words {
+		args[i] = w.text
+	}
+	return args, nil
+}
+
+// quoteWord quotes the word when it contains blanks, quotes or backslashes,
+// leaving the quote open when the word is not complete
+func quoteWord(w string, complete bool) string {
+	if !strings.ContainsAny(w, " \t'\"\\") {
+		return w
+	}
+	q := "'" + strings.ReplaceAll(w, "'", `'\''`)
+	if complete {
+		q += "'"
+	}
+	return q
+}
+
+// shell is the interactive mode: it reads the command lines and runs them on a
+// new command tree, with the history and the completion on a terminal
+type shell struct {
+	in      *os.File
+	term    *term.Terminal
+	reader  *bufio.Reader
+	state   *term.State
+	keyFile string
+	// vault is the open box, given to the commands as the box of the session
+	vault *vault.Vault
+	// locked is true when the box has been wiped from memory, boxPath is the
+	// box to open again
+	locked  bool
+	boxPath string
+}
+
+func newShell(in *os.File, out io.Writer, v *vault.Vault) *shell {
+	s := &shell{in: in, keyFile: utils.KeyFilePath, vault: v}
+	fd := int(in.Fd())
+	if !term.IsTerminal(fd) {
+		s.reader = bufio.NewReader(in)
+		return s
+	}
+	s.state, _ = term.GetState(fd)
+	s.term = term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{in, out}, shellPrompt)
+	s.term.AutoCompleteCallback = s.complete
+	return s
+}
+
+// readLine reads the next command line, the terminal is in raw mode only while
+// the line is edited
+func (s *shell) readLine() (string, error) {
+	if s.term == nil {
+		return s.readInput(shellPrompt)
+	}
+	fd := int(s.in.Fd())
+	if _, err := term.MakeRaw(fd); err != nil {
+		return "", err
+	}
+	defer s.restore()
+	line, err := s.term.ReadLine()
+	if errors.Is(err, term.ErrPasteIndicator) {
+		err = nil
+	}
+	return strings.TrimSpace(line), err
+}
+
+// readPassword reads the password to unlock the box, it is not echoed on a
+// terminal
+func (s *shell) readPassword() (string, error) {
+	if s.term == nil {
+		return s.readInput(passwordPrompt)
+	}
+	fd := int(s.in.Fd())
+	if _, err := term.MakeRaw(fd); err != nil {
+		return "", err
+	}
+	defer s.restore()
+	return s.term.ReadPassword(passwordPrompt)
+}
+
+// readInput reads a line when the input is not a terminal
+func (s *shell) readInput(prompt string) (string, error) {
+	fmt.Print(prompt)
+	line, err := s.reader.ReadString('\n')
+	if err != nil && len(line) == 0 {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// restore gives the terminal back its state
+func (s *shell) restore() {
+	if s.state != nil {
+		term.Restore(int(s.in.Fd()), s.state)
+	}
+}
+
+// printf prints a message, on a terminal the line being edited is drawn again
+// after it
+func (s *shell) printf(format string, a ...any) {
+	if s.term == nil {
+		fmt.Printf(format, a...)
+		return
+	}
+	fmt.Fprintf(s.term, format, a...)
+}
+
+// run reads and executes the command lines until the user exits or the input
+// ends. The box is locked when no line is given within the timeout, a warning
+// is printed before.
+func (s *shell) run(timeout time.Duration) error {
+	type input struct {
+		line string
+		err  error
+	}
+	// the reads run one at a time in the goroutine, the loop asks for the next
+	// one (a command line or a password) when the previous input is handled
+	reads := make(chan func() (string, error))
+	inputChan := make(chan input)
+	go func() {
+		for read := range reads {
+			line, err := read()
+			inputChan <- input{line, err}
+		}
+	}()
+	defer close(reads)
+
+	warning := min(lockWarning, timeout/2)
+	deadline, warned := time.Now().Add(timeout), false
+	// password is true when the next input is the password to unlock the box
+	password := false
+	reads <- s.readLine
+	for {
+		var alarm <-chan time.Time
+		if !s.locked {
+			next := deadline
+			if !warned {
+				next = deadline.Add(-warning)
+			}
+			alarm = time.After(time.Until(next))
+		}
+
+		select {
+		case in := <-inputChan:
+			slog.Debug("input read from the channel", "locked", s.locked)
+			if in.err != nil {
+				if in.err != io.EOF {
+					return fmt.Errorf("error reading input: %v", in.err)
+				}
+				fmt.Println("see you for the next secret to whisper...")
+				return s.lockedError()
+			}
+			switch {
+			case password:
+				if err := s.unlock(in.line); err != nil {
+					printError(err)
+				}
+			case s.locked:
+				// ENTER pressed on the locked screen
+				if isQuit(in.line) {
+					fmt.Println("see you for the next secret to whisper...")
+					return s.lockedError()
+				}
+			default:
+				if exit := s.execute(in.line); exit {
+					fmt.Println("see you for the next secret to whisper...")
+					return nil
+				}
+			}
+			password = s.locked
+			deadline, warned = time.Now().Add(timeout), false
+			if password {
+				reads <- s.readPassword
+			} else {
+				reads <- s.readLine
+			}
+		case <-alarm:
+			if !warned {
+				warned = true
+				s.printf("\nno input for a while, the box locks in %v (press ENTER to keep it open)\n", warning)
+				continue
+			}
+			s.lock(fmt.Sprintf("no input received for %v", timeout))
+			// the line being read is discarded: on a terminal it ends with
+			// ENTER, then the password is asked without echo
+			if s.term == nil {
+				password = true
+				s.printf("type the password to unlock it\n")
+			} else {
+				s.term.SetPrompt("")
+				s.printf("press ENTER to unlock it ('quit' exits)\n")
+			}
+		}
+	}
+}
+
+// lock wipes the box and its password from memory and clears the screen
+func (s *shell) lock(reason string) {
+	s.boxPath, s.locked = s.vault.Source(), true
+	s.close()
+	ui.ClearScreen()
+	s.printf("%s, the box %s is locked\n", reason, s.boxPath)
+}
+
+// close wipes the box of the session and its password from memory
+func (s *shell) close() {
+	if s.vault != nil {
+		s.vault.Close()
+		s.vault = nil
+	}
+}
+
+// context returns the context of the commands, carrying the box of the
+// session
+func (s *shell) context() context.Context {
+	return utils.WithSession(context.Background(), s.vault)
+}
+
+// lockedError returns ErrLocked if the session ends with the box locked
+func (s *shell) lockedError() error {
+	if s.locked {
+		return fmt.Errorf("%w: %s", vault.ErrLocked, s.boxPath)
+	}
+	return nil
+}
+
+// unlock opens the locked box again with the password
+func (s *shell) unlock(pwd string) error {
+	if len(pwd) == 0 {
+		return errors.New("the password is empty")
+	}
+	utils.KeyFilePath = s.keyFile
+	v, err := utils.OpenBox(context.Background(), s.boxPath, pwd)
+	if err != nil {
+		return err
+	}
+	s.vault, s.locked = v, false
+	if s.term != nil {
+		s.term.SetPrompt(shellPrompt)
+	}
+	output.Success("Box is unlocked")
+	return nil
+}
+
+func isQuit(cmd string) bool {
+	switch cmd {
+	case "quit", "q", "exit", "bye":
+		return true
+	}
+	return false
+}
+
+// execute runs the command line, it returns true when the user asks to exit
+func (s *shell) execute(line string) bool {
+	args, err := splitWords(line)
+	if err != nil {
+		output.Error("", err.Error())
+		return false
+	}
+	if len(args) == 0 {
+		return false
+	}
+	if isQuit(args[0]) {
+		return true
+	}
+	switch args[0] {
+	case "lock":
+		s.lock("locked by the user")
+		return false
+	case "clear", "cl", "wipe", "clean":
+		ui.ClearScreen()
+		return false
+	case "history":
+		s.printHistory()
+		return false
+	}
+
+	ui.ClearScreen()
+	slog.Debug("executing the command", "args", args)
+	if err := s.runCommand(args); err != nil {
+		printError(err)
+	}
+	// the secrets decoded by the command go back into locked memory
+	if s.vault != nil {
+		if err := s.vault.Box().Hide(); err != nil {
+			printError(err)
+		}
+	}
+	return false
+}
+
+// runCommand executes the command on a new tree: the flags of the previous
+// commands don't leak into the next ones
+func (s *shell) runCommand(args []string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("the command failed: %v", r)
+		}
+	}()
+	c := s.newRoot()
+	c.SetArgs(args)
+	return c.ExecuteContext(s.context())
+}
+
+// newRoot creates the command tree keeping the key file given opening the box
+func (s *shell) newRoot() *cobra.Command {
+	c := newRootCmd()
+	utils.KeyFilePath = s.keyFile
+	return c
+}
+
+func (s *shell) printHistory() {
+	if s.term == nil {
+		return
+	}
+	// At(0) is the most recent line, the history command itself
+	n := s.term.History.Len()
+	for i := n - 1; i > 0; i-- {
+		fmt.Printf("%4d  %s\n", n-i, s.term.History.At(i))
+	}
+}
+
+// complete is the terminal callback completing the word under the cursor on
+// TAB: a unique candidate is completed, otherwise the common prefix is and the
+// candidates are listed
+func (s *shell) complete(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' {
+		return "", 0, false
+	}
+	head := line[:pos]
+	words, open, _ := scanWords(head)
+	current := word{start: len(head)}
+	if open {
+		current = words[len(words)-1]
+		words = words[:len(words)-1]
+	}
+	args := make([]string, len(words))
+	for i, w := range words {
+		args[i] = w.text
+	}
+
+	candidates := s.candidates(args, current.text)
+	if len(candidates) == 0 {
+		return "", 0, false
+	}
+	prefix := candidates[0]
+	for _, c := range candidates[1:] {
+		for !strings.HasPrefix(c, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	if len(candidates) > 1 && prefix == current.text && s.term != nil {
+		fmt.Fprintln(s.term, strings.Join(candidates, "  "))
+	}
+	text := quoteWord(prefix, len(candidates) == 1)
+	if len(candidates) == 1 && !strings.HasSuffix(prefix, ".") {
+		text += " "
+	}
+	newHead := head[:current.start] + text
+	return newHead + line[pos:], len(newHead), true
+}
+
+// candidates returns the sorted completions of the word: the commands, the
+// flags or the arguments given by the ValidArgsFunction of the command (e.g.
+// the secret names)
+func (s *shell) candidates(args []string, current string) []string {
+	root := s.newRoot()
+	root.InitDefaultHelpCmd()
+	var names []string
+	add := func(name string) {
+		if strings.HasPrefix(name, current) {
+			names = append(names, name)
+		}
+	}
+
+	c, rest, err := root.Find(args)
+	if err != nil {
+		return nil
+	}
+	switch {
+	case len(args) == 0:
+		for _, b := range []string{"quit", "exit", "clear", "history", "lock"} {
+			add(b)
+		}
+		fallthrough
+	case len(rest) == 0 && c.HasAvailableSubCommands() && !strings.HasPrefix(current, "-"):
+		for _, sub := range c.Commands() {
+			if sub.IsAvailableCommand() || sub.Name() == "help" {
+				add(sub.Name())
+			}
+		}
+	case strings.HasPrefix(current, "-"):
+		visit := func(f *pflag.Flag) {
+			if !f.Hidden {
+				add("--" + f.Name)
+			}
+		}
+		c.LocalFlags().VisitAll(visit)
+		c.InheritedFlags().VisitAll(visit)
+	case c.ValidArgsFunction != nil:
+		if len(rest) > 0 {
+			// a flag waiting for its value
+			last := rest[len(rest)-1]
+			if f := flagOf(c, last); f != nil && f.NoOptDefVal == "" && !strings.Contains(last, "=") {
+				return nil
+			}
+		}
+		if err := c.ParseFlags(rest); err != nil {
+			return nil
+		}
+		c.SetContext(s.context())
+		valid, _ := c.ValidArgsFunction(c, c.Flags().Args(), current)
+		for _, v := range valid {
+			add(v)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// flagOf returns the flag of the command given as arg (e.g. --box or -b)
+func flagOf(c *cobra.Command, arg string) *pflag.Flag {
+	name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+	for _, flags := range []*pflag.FlagSet{c.LocalFlags(), c.InheritedFlags()} {
+		switch {
+		case strings.HasPrefix(arg, "--"):
+			if f := flags.Lookup(name); f != nil {
+				return f
+			}
+		case strings.HasPrefix(arg, "-") && len(name) == 1:
+			if f := flags.ShorthandLookup(name); f != nil {
+				return f
+			}
+		}
+	}
+	return nil
+}
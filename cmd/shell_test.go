@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mas2020-golang/cryptex/pkg/vault"
+)
+
+func TestSplitWords(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"get secret foo", []string{"get", "secret", "foo"}},
+		{"  print   secret\tfoo  ", []string{"print", "secret", "foo"}},
+		{`get secret 'my secret'`, []string{"get", "secret", "my secret"}},
+		{`get secret "my \"big\" secret"`, []string{"get", "secret", `my "big" secret`}},
+		{`get secret my\ secret`, []string{"get", "secret", "my secret"}},
+		{`edit secret 'it'\''s'`, []string{"edit", "secret", "it's"}},
+		{`ls secrets -f "a\d+"`, []string{"ls", "secrets", "-f", `a\d+`}},
+		{`create box ''`, []string{"create", "box", ""}},
+	}
+	for _, tt := range tests {
+		got, err := splitWords(tt.line)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.line, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %q, expected %q", tt.line, got, tt.want)
+		}
+	}
+	if _, err := splitWords(`get secret 'foo`); err != errUnterminated {
+		t.Errorf("Expected errUnterminated, got: %v", err)
+	}
+}
+
+// TestShell_Complete tests the completion of the commands, the flags, the
+// secret names and the item keys
+func TestShell_Complete(t *testing.T) {
+	v := createBox(t, filepath.Join(t.TempDir(), "box"), &vault.Box{Secrets: []*vault.Secret{
+		{Name: "github", Others: map[string]string{"token": "x", "totp": "y"}},
+		{Name: "gitlab"},
+		{Name: "my bank"},
+	}})
+	s := &shell{vault: v}
+
+	tests := []struct {
+		line, want string
+	}{
+		{"pri", "print "},
+		{"get se", "get secret "},
+		{"get secret git", "get secret git"},
+		{"get secret gith", "get secret github "},
+		{"get secret github.", "get secret github.to"},
+		{"get secret github.tok", "get secret github.token "},
+		{"print secret my", "print secret 'my bank' "},
+		{"print secret --unse", "print secret --unsecure "},
+		{"print secret --box ", ""},
+		{"nope ", ""},
+	}
+	for _, tt := range tests {
+		got, _, ok := s.complete(tt.line, len(tt.line), '\t')
+		if !ok {
+			got = ""
+		}
+		if got != tt.want {
+			t.Errorf("%q: got %q, expected %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+// createBox writes the box in path with the password "secret" and returns it
+// open, it's closed at the end of the test
+func createBox(t *testing.T, path string, box *vault.Box) *vault.Vault {
+	t.Helper()
+	v, err := vault.Create(context.Background(), path, box, vault.Key{Passphrase: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { v.Close() })
+	return v
+}
+
+// TestShell_Lock tests the lock on timeout and with the lock command, the box
+// is read again from the file unlocking it
+func TestShell_Lock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "box")
+	v := createBox(t, path, &vault.Box{Name: "box", Secrets: []*vault.Secret{{Name: "github"}}})
+
+	tests := []struct {
+		name  string
+		delay time.Duration
+		input string
+	}{
+		{"timeout", 300 * time.Millisecond, "wrong\nsecret\nquit\n"},
+		{"command", 0, "lock\n\nsecret\nquit\n"},
+	}
+	for _, tt := range tests {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		go func() {
+			time.Sleep(tt.delay)
+			w.WriteString(tt.input)
+			w.Close()
+		}()
+		s := newShell(r, io.Discard, v)
+		if err := s.run(200 * time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+		r.Close()
+		if s.locked || s.vault == nil || s.vault == v || s.vault.Box().Secret("github") == nil {
+			t.Errorf("%s: expected the box to be unlocked reading it again", tt.name)
+		}
+		if _, err := v.List(context.Background()); !errors.Is(err, vault.ErrClosed) {
+			t.Errorf("%s: expected the box closed on lock, got %v", tt.name, err)
+		}
+		v = s.vault
+		t.Cleanup(s.close)
+	}
+}
+
+// TestShell_LockedExit tests that a session ending with the box locked returns
+// ErrLocked
+func TestShell_LockedExit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "box")
+	v := createBox(t, path, &vault.Box{Name: "box", Secrets: []*vault.Secret{{Name: "github"}}})
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	w.WriteString("lock\n")
+	w.Close()
+	s := newShell(r, io.Discard, v)
+	if err := s.run(time.Minute); !errors.Is(err, vault.ErrLocked) {
+		t.Errorf("Expected ErrLocked, got %v", err)
+	}
+}
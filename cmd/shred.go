@@ -0,0 +1,73 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mas2020-golang/cryptex/packages/security"
+	"github.com/mas2020-golang/cryptex/packages/utils"
+	"github.com/mas2020-golang/goutils/output"
+	"github.com/spf13/cobra"
+)
+
+func newShredCmd() *cobra.Command {
+	var (
+		policy string
+		yes    bool
+	)
+	c := &cobra.Command{
+		Use:   "shred <FILE|FOLDER>...",
+		Args:  cobra.MinimumNArgs(1),
+		Short: "Wipe and remove files and folders",
+		Long: `Overwrite the files following the --wipe policy and remove them, a folder is removed
+with all its content. The symlinks are removed without touching their targets.
+
+The policies are 'dod' (default, zeros, ones and random data, 3 passes), 'random' or
+'zero' (1 pass) and 'none' (remove only). Set RAPTOR_WIPE to change the default.
+
+On SSDs and on copy-on-write, log-structured or network filesystems (btrfs, zfs, apfs,
+nfs, ...) the new data is not written where the old one was: overwriting can't be
+trusted and a warning is given. Encrypt the data from the start instead.`,
+		Example: `$ raptor shred secret.txt
+$ raptor shred --wipe zero --yes ~/tmp/export`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := security.ValidWipePolicy(policy); err != nil {
+				return err
+			}
+			if !yes && !confirm(fmt.Sprintf("Wipe and remove %s?", strings.Join(args, ", "))) {
+				return nil
+			}
+			failed := 0
+			for _, p := range args {
+				warnWipe(p, policy)
+				if err := security.Shred(p, policy); err != nil {
+					output.Error("", err.Error())
+					failed++
+					continue
+				}
+				utils.Verbosity(fmt.Sprintf("%s shredded", p), verbose)
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d of %d paths not shredded", failed, len(args))
+			}
+			utils.Success("shredded")
+			return nil
+		},
+	}
+	c.Flags().StringVar(&policy, "wipe", defaultWipe(), "The wipe policy: none, zero, random or dod (default from RAPTOR_WIPE)")
+	c.Flags().BoolVarP(&yes, "yes", "y", false, "Don't ask for confirmation")
+
+	return c
+}
+
+// confirm asks a yes/no question, the default is no
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer := strings.ToLower(strings.TrimSpace(utils.GetText(bufio.NewReader(os.Stdin))))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,493 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mas2020-golang/cryptex/cmd/edit"
+	"github.com/mas2020-golang/cryptex/packages/security"
+	"github.com/mas2020-golang/cryptex/pkg/vault"
+)
+
+type state int
+
+const (
+	// statePicker lists the boxes of the box folder
+	statePicker state = iota
+	// statePassword asks the password of the box, also to unlock it
+	statePassword
+	// stateBrowse lists the secrets of the open box
+	stateBrowse
+	// stateConfirm asks a yes/no question before an action
+	stateConfirm
+)
+
+// otpItems are the item keys holding the OTP secret of a secret
+var otpItems = []string{"totp", "otp"}
+
+type tickMsg time.Time
+
+// editedMsg is sent when the edit prompts are done
+type editedMsg struct{ err error }
+
+// confirmation is a question with the action run on yes and on no
+type confirmation struct {
+	question  string
+	yes, no   func(m *model) tea.Cmd
+	nextState state
+}
+
+// model is the state of the box browser
+type model struct {
+	state   state
+	boxes   []string
+	boxIdx  int
+	boxName string
+	// vault is the open box, box its content
+	vault *vault.Vault
+	box   *vault.Box
+
+	// input is the password being typed
+	input  []rune
+	locked bool
+
+	filter    string
+	filtering bool
+	visible   []*vault.Secret
+	cursor    int
+	reveal    bool
+
+	confirm *confirmation
+	editing bool
+	// edited is the secret being edited, backup is its copy restored when the
+	// changes are discarded
+	edited, backup *vault.Secret
+	// shown is the selected secret, the only one decoded
+	shown *vault.Secret
+
+	status    string
+	statusErr bool
+
+	timeout  time.Duration
+	lastUsed time.Time
+
+	width, height int
+
+	// the side effects, replaced by the tests
+	now     func() time.Time
+	copy    func(string) error
+	openURL func(string) error
+	openBox func(name, pwd string) (*vault.Vault, error)
+	saveBox func(v *vault.Vault) error
+}
+
+func tick() tea.Cmd {
+	return tea.Tick(time.Second, func(t time.Time) tea.Msg { return tickMsg(t) })
+}
+
+func (m *model) Init() tea.Cmd {
+	return tick()
+}
+
+func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		return m, nil
+	case tickMsg:
+		if m.box != nil && !m.editing && m.now().Sub(m.lastUsed) >= m.timeout {
+			m.lock(fmt.Sprintf("locked after %v of inactivity", m.timeout))
+		}
+		return m, tick()
+	case editedMsg:
+		return m, m.editDone(msg.err)
+	case tea.KeyMsg:
+		m.lastUsed = m.now()
+		if msg.Type == tea.KeyCtrlC {
+			m.wipe()
+			return m, tea.Quit
+		}
+		switch m.state {
+		case statePicker:
+			return m, m.updatePicker(msg)
+		case statePassword:
+			return m, m.updatePassword(msg)
+		case stateBrowse:
+			if m.filtering {
+				m.updateFilter(msg)
+				return m, nil
+			}
+			return m, m.updateBrowse(msg)
+		case stateConfirm:
+			return m, m.updateConfirm(msg)
+		}
+	}
+	return m, nil
+}
+
+func (m *model) setStatus(err error, format string, args ...any) {
+	if err != nil {
+		m.status, m.statusErr = err.Error(), true
+		return
+	}
+	m.status, m.statusErr = fmt.Sprintf(format, args...), false
+}
+
+func (m *model) updatePicker(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "q", "esc":
+		return tea.Quit
+	case "up", "k":
+		if m.boxIdx > 0 {
+			m.boxIdx--
+		}
+	case "down", "j":
+		if m.boxIdx < len(m.boxes)-1 {
+			m.boxIdx++
+		}
+	case "enter":
+		if len(m.boxes) > 0 {
+			m.boxName = m.boxes[m.boxIdx]
+			m.state, m.locked = statePassword, false
+			m.status = ""
+		}
+	}
+	return nil
+}
+
+func (m *model) updatePassword(msg tea.KeyMsg) tea.Cmd {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.clearInput()
+		if len(m.boxes) == 0 {
+			return tea.Quit
+		}
+		m.state = statePicker
+	case tea.KeyBackspace:
+		if len(m.input) > 0 {
+			m.input[len(m.input)-1] = 0
+			m.input = m.input[:len(m.input)-1]
+		}
+	case tea.KeyEnter:
+		// OpenBox would ask for the password on the terminal used by the UI
+		if len(m.input) == 0 {
+			m.setStatus(fmt.Errorf("type the password of the box"), "")
+			return nil
+		}
+		pwd := string(m.input)
+		m.clearInput()
+		m.open(pwd)
+	case tea.KeyRunes, tea.KeySpace:
+		m.input = append(m.input, msg.Runes...)
+	}
+	return nil
+}
+
+// open opens the box with the password
+func (m *model) open(pwd string) {
+	v, err := m.openBox(m.boxName, pwd)
+	if err != nil {
+		m.setStatus(err, "")
+		return
+	}
+	m.vault, m.box = v, v.Box()
+	m.state, m.locked = stateBrowse, false
+	m.filter, m.cursor, m.reveal = "", 0, false
+	m.refresh()
+	m.setStatus(nil, "box %s open, %d secrets", m.boxName, len(m.box.Secrets))
+}
+
+func (m *model) clearInput() {
+	for i := range m.input {
+		m.input[i] = 0
+	}
+	m.input = m.input[:0]
+}
+
+// wipe removes the open box from memory
+func (m *model) wipe() {
+	if m.vault != nil {
+		m.vault.Close()
+	}
+	if m.backup != nil {
+		m.backup.Wipe()
+	}
+	m.vault, m.box, m.visible, m.shown, m.edited, m.backup = nil, nil, nil, nil, nil, nil
+	m.reveal = false
+	m.clearInput()
+}
+
+// lock wipes the box and asks the password again
+func (m *model) lock(reason string) {
+	m.wipe()
+	m.confirm, m.filtering = nil, false
+	m.state, m.locked = statePassword, true
+	m.setStatus(nil, "%s", reason)
+}
+
+func (m *model) updateFilter(msg tea.KeyMsg) {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.filter, m.filtering = "", false
+	case tea.KeyEnter:
+		m.filtering = false
+	case tea.KeyBackspace:
+		if r := []rune(m.filter); len(r) > 0 {
+			m.filter = string(r[:len(r)-1])
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		m.filter += string(msg.Runes)
+	}
+	m.refresh()
+}
+
+// refresh selects the secrets matching the filter on the name, the login, the
+// URL or the tags
+func (m *model) refresh() {
+	m.visible = m.visible[:0]
+	if m.box == nil {
+		return
+	}
+	f := strings.ToLower(m.filter)
+	for _, s := range m.box.Secrets {
+		fields := append([]string{s.Name, s.Login, s.Url}, s.Tags...)
+		for _, field := range fields {
+			if strings.Contains(strings.ToLower(field), f) {
+				m.visible = append(m.visible, s)
+				break
+			}
+		}
+	}
+	if m.cursor >= len(m.visible) {
+		m.cursor = max(len(m.visible)-1, 0)
+	}
+}
+
+// selected returns the secret under the cursor, its password, notes and items
+// are decoded only while it is selected
+func (m *model) selected() *vault.Secret {
+	var s *vault.Secret
+	if m.cursor < len(m.visible) {
+		s = m.visible[m.cursor]
+	}
+	if s != m.shown && !m.editing {
+		if err := m.shown.Hide(); err != nil {
+			m.setStatus(err, "")
+		}
+		m.shown = s
+	}
+	if err := s.Load(); err != nil {
+		m.setStatus(err, "")
+	}
+	return s
+}
+
+// hide moves the sensitive fields of all the secrets back into locked memory,
+// saving the box decodes them
+func (m *model) hide() {
+	if err := m.box.Hide(); err != nil {
+		m.setStatus(err, "")
+	}
+	m.shown = nil
+}
+
+func (m *model) updateBrowse(msg tea.KeyMsg) tea.Cmd {
+	s := m.selected()
+	switch msg.String() {
+	case "q":
+		m.wipe()
+		return tea.Quit
+	case "b":
+		m.wipe()
+		if len(m.boxes) == 0 {
+			return tea.Quit
+		}
+		m.state = statePicker
+		m.status = ""
+	case "/":
+		m.filtering = true
+	case "esc":
+		m.filter = ""
+		m.refresh()
+	case "up", "k":
+		if m.cursor > 0 {
+			m.cursor--
+			m.reveal = false
+		}
+	case "down", "j":
+		if m.cursor < len(m.visible)-1 {
+			m.cursor++
+			m.reveal = false
+		}
+	case "home", "g":
+		m.cursor, m.reveal = 0, false
+	case "end", "G":
+		m.cursor, m.reveal = max(len(m.visible)-1, 0), false
+	case "r":
+		m.reveal = !m.reveal
+	}
+	if s == nil {
+		return nil
+	}
+
+	switch msg.String() {
+	case "c":
+		m.copyField("password", s.Pwd)
+	case "l":
+		m.copyField("login", s.Login)
+	case "u":
+		m.copyField("URL", s.Url)
+	case "t":
+		otp := otpSecret(s)
+		if len(otp) == 0 {
+			m.setStatus(fmt.Errorf("secret %q has no OTP item", s.Name), "")
+			return nil
+		}
+		code, _, err := security.TOTP(otp, m.now())
+		if err != nil {
+			m.setStatus(err, "")
+			return nil
+		}
+		m.copyField("OTP code", code)
+	case "o":
+		if len(s.Url) == 0 {
+			m.setStatus(fmt.Errorf("secret %q does not define a URL to open", s.Name), "")
+			return nil
+		}
+		if err := m.openURL(s.Url); err != nil {
+			m.setStatus(fmt.Errorf("failed to open the browser: %v", err), "")
+			return nil
+		}
+		m.setStatus(nil, "%s opened in the browser", s.Url)
+	case "e":
+		backup, err := s.Clone()
+		if err != nil {
+			m.setStatus(err, "")
+			return nil
+		}
+		m.edited, m.backup, m.editing = s, backup, true
+		return tea.Exec(&editCommand{secret: s, box: m.box, boxPath: m.vault.Source()}, func(err error) tea.Msg {
+			return editedMsg{err}
+		})
+	case "d":
+		m.ask(fmt.Sprintf("Delete the secret %q and save the box?", s.Name), func(m *model) tea.Cmd {
+			i := slices.Index(m.box.Secrets, s)
+			m.box.Remove(s.Name)
+			if err := m.saveBox(m.vault); err != nil {
+				// the secret goes back to its place
+				m.box.Secrets = slices.Insert(m.box.Secrets, i, s)
+				m.setStatus(err, "")
+			} else {
+				s.Wipe()
+				m.setStatus(nil, "secret %s deleted and box saved", s.Name)
+			}
+			m.hide()
+			m.refresh()
+			return nil
+		}, nil)
+	}
+	return nil
+}
+
+func (m *model) copyField(name, value string) {
+	if len(value) == 0 {
+		m.setStatus(fmt.Errorf("the %s is empty", name), "")
+		return
+	}
+	if err := m.copy(value); err != nil {
+		m.setStatus(err, "")
+		return
+	}
+	m.setStatus(nil, "%s copied to the clipboard", name)
+}
+
+// otpSecret returns the OTP secret saved in the items of the secret
+func otpSecret(s *vault.Secret) string {
+	for _, k := range otpItems {
+		if v, ok := s.Others[k]; ok {
+			return v
+		}
+	}
+	return ""
+}
+
+func (m *model) ask(question string, yes, no func(m *model) tea.Cmd) {
+	m.confirm = &confirmation{question: question, yes: yes, no: no, nextState: m.state}
+	m.state = stateConfirm
+}
+
+func (m *model) updateConfirm(msg tea.KeyMsg) tea.Cmd {
+	c := m.confirm
+	var action func(m *model) tea.Cmd
+	switch msg.String() {
+	case "y", "Y":
+		action = c.yes
+	case "n", "N", "esc":
+		action = c.no
+		m.setStatus(nil, "cancelled")
+	default:
+		return nil
+	}
+	m.confirm, m.state = nil, c.nextState
+	if action != nil {
+		return action(m)
+	}
+	return nil
+}
+
+// editDone asks to save the changes made by the edit prompts, the secret is
+// restored when they are discarded
+func (m *model) editDone(err error) tea.Cmd {
+	m.editing, m.lastUsed = false, m.now()
+	restore := func(m *model) tea.Cmd {
+		if m.edited != nil {
+			m.edited.Wipe()
+			*m.edited = *m.backup
+		}
+		m.edited, m.backup = nil, nil
+		m.refresh()
+		return nil
+	}
+	if m.box == nil {
+		return nil
+	}
+	if err != nil {
+		restore(m)
+		m.setStatus(err, "")
+		return nil
+	}
+	m.ask("Save the changes into the box?", func(m *model) tea.Cmd {
+		err := m.saveBox(m.vault)
+		m.hide()
+		if err != nil {
+			m.setStatus(err, "")
+			return nil
+		}
+		m.backup.Wipe()
+		m.edited, m.backup = nil, nil
+		m.refresh()
+		m.setStatus(nil, "box saved")
+		return nil
+	}, restore)
+	return nil
+}
+
+// editCommand runs the edit prompts on the terminal released by the program
+type editCommand struct {
+	secret  *vault.Secret
+	box     *vault.Box
+	boxPath string
+}
+
+func (c *editCommand) Run() error {
+	fmt.Print("\033[2J\033[H")
+	return edit.EditSecret(c.secret.Name, c.box, c.boxPath)
+}
+
+func (c *editCommand) SetStdin(io.Reader)  {}
+func (c *editCommand) SetStdout(io.Writer) {}
+func (c *editCommand) SetStderr(io.Writer) {}
@@ -0,0 +1,97 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/atotto/clipboard"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mas2020-golang/cryptex/cmd/list"
+	"github.com/mas2020-golang/cryptex/cmd/nav"
+	"github.com/mas2020-golang/cryptex/packages/utils"
+	"github.com/mas2020-golang/cryptex/pkg/vault"
+	"github.com/spf13/cobra"
+)
+
+// defaultTimeout locks the browser when RAPTOR_TIMEOUT_SEC is not set
+const defaultTimeout = 600 * time.Second
+
+// NewCmd creates the "ui" command, a full-screen browser of the boxes
+func NewCmd() *cobra.Command {
+	var boxName string
+
+	cmd := &cobra.Command{
+		Use:   "ui",
+		Args:  cobra.NoArgs,
+		Short: "Browse the boxes in a full-screen interface",
+		Long: `Browse the boxes in a full-screen interface: choose a box, filter its secrets
+by name, login, URL or tag and look at their details. The password, the items and the OTP
+code are masked until you reveal them with 'r'.
+
+The keys copy the password (c), the login (l), the URL (u) or the current OTP code (t) of the
+secret, taken from its 'totp' item, into the clipboard; 'o' opens the URL in the browser, 'e'
+edits the secret and 'd' deletes it, both asking for a confirmation before saving the box.
+
+After RAPTOR_TIMEOUT_SEC seconds (default 600) without a key pressed the box is removed from
+memory and its password is asked again.`,
+		Example: `$ raptor ui
+$ raptor ui --box test`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			m, err := newModel(boxName)
+			if err != nil {
+				return err
+			}
+			_, err = tea.NewProgram(m, tea.WithAltScreen()).Run()
+			m.wipe()
+			if err == nil && m.locked {
+				err = fmt.Errorf("%w: %s", vault.ErrLocked, m.boxName)
+			}
+			return err
+		},
+	}
+	cmd.Flags().StringVarP(&boxName, "box", "b", "", "The box to open, skipping the box picker (default CRYPTEX_BOX)")
+
+	return cmd
+}
+
+func newModel(boxName string) (*model, error) {
+	timeout := defaultTimeout
+	if v := os.Getenv("RAPTOR_TIMEOUT_SEC"); len(v) > 0 {
+		secs, err := strconv.Atoi(v)
+		if err != nil || secs <= 0 {
+			return nil, fmt.Errorf("invalid RAPTOR_TIMEOUT_SEC %q", v)
+		}
+		timeout = time.Duration(secs) * time.Second
+	}
+	m := &model{
+		timeout: timeout,
+		now:     time.Now,
+		copy:    clipboard.WriteAll,
+		openURL: nav.OpenBrowser,
+		// the box is chosen in the UI, not the one of a session
+		openBox: func(name, pwd string) (*vault.Vault, error) {
+			return utils.OpenBox(context.Background(), name, pwd)
+		},
+		saveBox: func(v *vault.Vault) error { return v.Save(context.Background()) },
+	}
+	m.lastUsed = m.now()
+
+	if len(boxName) == 0 {
+		boxName = os.Getenv("CRYPTEX_BOX")
+	}
+	if len(boxName) > 0 {
+		m.boxName, m.state = boxName, statePassword
+		return m, nil
+	}
+	_, boxes, err := list.ListBoxes("")
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range boxes {
+		m.boxes = append(m.boxes, b.Name)
+	}
+	return m, nil
+}
@@ -0,0 +1,190 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mas2020-golang/cryptex/pkg/vault"
+)
+
+// newTestModel returns a model on a box in memory, with a clock moved by the
+// tests and the clipboard and the saves recorded
+func newTestModel(t *testing.T) (*model, *time.Time, *string, *int) {
+	now := time.Unix(59, 0)
+	clip, saves := "", 0
+	path := filepath.Join(t.TempDir(), "test")
+	v, err := vault.Create(context.Background(), path, &vault.Box{Name: "test", Secrets: []*vault.Secret{
+		{Name: "github", Login: "me", Pwd: "gh-pwd", Url: "https://github.com",
+			// the RFC 6238 key
+			Others: map[string]string{"totp": "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"}},
+		{Name: "bank", Login: "me2", Pwd: "bank-pwd", Tags: []string{"finance"}},
+	}}, vault.Key{Passphrase: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.Close()
+	m := &model{
+		timeout: time.Minute,
+		boxes:   []string{"test"},
+		now:     func() time.Time { return now },
+		copy:    func(v string) error { clip = v; return nil },
+		openURL: func(string) error { return nil },
+		openBox: func(name, pwd string) (*vault.Vault, error) {
+			if pwd == "" {
+				t.Fatal("Expected the box not to be opened with an empty password")
+			}
+			return vault.Open(context.Background(), path, vault.Passphrase(pwd))
+		},
+		saveBox: func(*vault.Vault) error { saves++; return nil },
+	}
+	m.lastUsed = now
+	t.Cleanup(m.wipe)
+	return m, &now, &clip, &saves
+}
+
+func keys(m *model, input ...string) {
+	for _, k := range input {
+		var msg tea.KeyMsg
+		switch k {
+		case "enter":
+			msg = tea.KeyMsg{Type: tea.KeyEnter}
+		case "esc":
+			msg = tea.KeyMsg{Type: tea.KeyEsc}
+		case "down":
+			msg = tea.KeyMsg{Type: tea.KeyDown}
+		default:
+			msg = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+		}
+		m.Update(msg)
+	}
+}
+
+// TestModel_Browse tests the password, the filter, the reveal and the copies
+func TestModel_Browse(t *testing.T) {
+	m, _, clip, _ := newTestModel(t)
+	keys(m, "enter", "enter")
+	if m.state != statePassword || !m.statusErr {
+		t.Fatalf("Expected an empty password to be refused, got state %v: %s", m.state, m.status)
+	}
+	keys(m, "wrong", "enter")
+	if m.state != statePassword || !m.statusErr {
+		t.Fatalf("Expected the password to be asked again, got state %v: %s", m.state, m.status)
+	}
+	keys(m, "secret", "enter")
+	if m.state != stateBrowse || len(m.visible) != 2 {
+		t.Fatalf("Expected the 2 secrets, got state %v: %s", m.state, m.status)
+	}
+
+	view := m.View()
+	if strings.Contains(view, "gh-pwd") || !strings.Contains(view, mask) {
+		t.Error("Expected the password to be masked")
+	}
+	keys(m, "r")
+	if view = m.View(); !strings.Contains(view, "gh-pwd") || !strings.Contains(view, "287082") {
+		t.Errorf("Expected the password and the OTP code revealed, got:\n%s", view)
+	}
+	keys(m, "down")
+	if m.reveal {
+		t.Error("Expected the reveal to be reset moving to another secret")
+	}
+
+	keys(m, "/", "fin", "enter")
+	if len(m.visible) != 1 || m.visible[0].Name != "bank" {
+		t.Fatalf("Expected the bank secret filtered by tag, got %d secrets", len(m.visible))
+	}
+	keys(m, "c")
+	if *clip != "bank-pwd" {
+		t.Errorf("Expected the password copied, got %q", *clip)
+	}
+	keys(m, "esc", "l")
+	if *clip != "me" {
+		t.Errorf("Expected the login of github copied, got %q", *clip)
+	}
+	keys(m, "t")
+	if *clip != "287082" {
+		t.Errorf("Expected the OTP code copied, got %q", *clip)
+	}
+}
+
+// TestModel_Lock tests the lock after the timeout
+func TestModel_Lock(t *testing.T) {
+	m, now, _, _ := newTestModel(t)
+	keys(m, "enter", "secret", "enter")
+	v := m.vault
+
+	*now = now.Add(30 * time.Second)
+	m.Update(tickMsg(*now))
+	if m.state != stateBrowse {
+		t.Fatal("Expected the box to be open before the timeout")
+	}
+	*now = now.Add(time.Minute)
+	m.Update(tickMsg(*now))
+	if m.state != statePassword || !m.locked || m.box != nil || m.vault != nil {
+		t.Fatal("Expected the box to be locked and wiped")
+	}
+	keys(m, "secret", "enter")
+	if m.state != stateBrowse || m.box == nil {
+		t.Errorf("Expected the box unlocked, got: %s", m.status)
+	}
+	if _, err := v.List(context.Background()); !errors.Is(err, vault.ErrClosed) {
+		t.Errorf("Expected the box closed on lock, got %v", err)
+	}
+}
+
+// TestModel_Delete tests the confirmation of the deletion and of the edit
+func TestModel_Delete(t *testing.T) {
+	m, _, _, saves := newTestModel(t)
+	keys(m, "enter", "secret", "enter")
+
+	keys(m, "d", "n")
+	if len(m.box.Secrets) != 2 || *saves != 0 {
+		t.Fatal("Expected the secret to be kept")
+	}
+	keys(m, "d", "y")
+	if len(m.box.Secrets) != 1 || m.box.Secrets[0].Name != "bank" || *saves != 1 {
+		t.Fatal("Expected github to be deleted and the box saved")
+	}
+
+	// the changes of the edit prompts are discarded
+	keys(m, "e")
+	m.box.Secrets[0].Pwd = "changed"
+	m.box.Secrets[0].Tags[0] = "changed"
+	m.Update(editedMsg{})
+	keys(m, "n")
+	if s := m.box.Secrets[0]; s.Pwd != "bank-pwd" || s.Tags[0] != "finance" || *saves != 1 {
+		t.Errorf("Expected the secret to be restored, got %+v", s)
+	}
+}
+
+// TestModel_DeleteFailed tests that a secret not deleted by a failed save
+// keeps its place
+func TestModel_DeleteFailed(t *testing.T) {
+	m, _, _, _ := newTestModel(t)
+	m.saveBox = func(*vault.Vault) error { return errors.New("read-only") }
+	keys(m, "enter", "secret", "enter")
+
+	keys(m, "d", "y")
+	if len(m.box.Secrets) != 2 || m.box.Secrets[0].Name != "github" || m.box.Secret("github") == nil || !m.statusErr {
+		t.Fatalf("Expected github to be kept in its place, got %v: %s", m.box.Secrets, m.status)
+	}
+
+	// the secret of the failed edit is restored from its own copy
+	keys(m, "e")
+	s := m.box.Secrets[0]
+	if err := s.Load(); err != nil {
+		t.Fatal(err)
+	}
+	s.Pwd, s.Others["totp"] = "changed", "changed"
+	m.Update(editedMsg{errors.New("the pwd mismatched")})
+	if err := s.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Pwd != "gh-pwd" || s.Others["totp"] != "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" || m.box.Secrets[0] != s {
+		t.Errorf("Expected the secret to be restored, got %+v", s)
+	}
+}
@@ -0,0 +1,182 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mas2020-golang/cryptex/packages/security"
+	"github.com/mas2020-golang/cryptex/pkg/vault"
+)
+
+const mask = "••••••••"
+
+var (
+	purple    = lipgloss.Color("99")
+	gray      = lipgloss.Color("245")
+	lightGray = lipgloss.Color("241")
+	red       = lipgloss.Color("196")
+	green     = lipgloss.Color("42")
+
+	titleStyle    = lipgloss.NewStyle().Bold(true).Foreground(purple)
+	labelStyle    = lipgloss.NewStyle().Foreground(purple).Width(10)
+	selectedStyle = lipgloss.NewStyle().Bold(true).Foreground(purple)
+	dimStyle      = lipgloss.NewStyle().Foreground(gray)
+	helpStyle     = lipgloss.NewStyle().Foreground(lightGray)
+	errorStyle    = lipgloss.NewStyle().Foreground(red)
+	okStyle       = lipgloss.NewStyle().Foreground(green)
+	paneStyle     = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lightGray).Padding(0, 1)
+)
+
+func (m *model) View() string {
+	var b strings.Builder
+	title := "raptor"
+	if m.state != statePicker && len(m.boxName) > 0 {
+		title += " · " + m.boxName
+	}
+	b.WriteString(titleStyle.Render(title) + "\n\n")
+
+	switch m.state {
+	case statePicker:
+		b.WriteString(m.viewPicker())
+	case statePassword:
+		b.WriteString(m.viewPassword())
+	case stateBrowse:
+		b.WriteString(m.viewBrowse())
+	case stateConfirm:
+		b.WriteString(m.viewBrowse())
+		b.WriteString("\n" + selectedStyle.Render(m.confirm.question) + " [y/n]")
+	}
+
+	if len(m.status) > 0 {
+		style := okStyle
+		if m.statusErr {
+			style = errorStyle
+		}
+		b.WriteString("\n" + style.Render(m.status))
+	}
+	b.WriteString("\n" + helpStyle.Render(m.help()))
+	return b.String()
+}
+
+func (m *model) help() string {
+	switch m.state {
+	case statePicker:
+		return "↑/↓ move • enter open • q quit"
+	case statePassword:
+		return "enter unlock • esc boxes • ctrl+c quit"
+	case stateBrowse:
+		if m.filtering {
+			return "type to filter • enter done • esc clear"
+		}
+		return "↑/↓ move • / filter • r reveal • c password • l login • u url • t otp • o open url • e edit • d delete • b boxes • q quit"
+	}
+	return ""
+}
+
+func (m *model) viewPicker() string {
+	if len(m.boxes) == 0 {
+		return dimStyle.Render("No boxes yet, create one with 'raptor create box'") + "\n"
+	}
+	var b strings.Builder
+	b.WriteString("Choose a box:\n\n")
+	for i, name := range m.boxes {
+		if i == m.boxIdx {
+			b.WriteString(selectedStyle.Render("> "+name) + "\n")
+		} else {
+			b.WriteString("  " + name + "\n")
+		}
+	}
+	return b.String()
+}
+
+func (m *model) viewPassword() string {
+	prompt := fmt.Sprintf("Password of %s: ", m.boxName)
+	if m.locked {
+		prompt = fmt.Sprintf("The box %s is locked, password: ", m.boxName)
+	}
+	return prompt + strings.Repeat("*", len(m.input)) + "\n"
+}
+
+func (m *model) viewBrowse() string {
+	// the list shows a window of the secrets around the cursor
+	rows := max(m.height-8, 5)
+	first := max(m.cursor-rows+1, 0)
+	var list strings.Builder
+	filter := "/" + m.filter
+	if !m.filtering && len(m.filter) == 0 {
+		filter = dimStyle.Render("/ to filter")
+	}
+	list.WriteString(filter + "\n\n")
+	if len(m.visible) == 0 {
+		list.WriteString(dimStyle.Render("No secrets") + "\n")
+	}
+	for i := first; i < len(m.visible) && i < first+rows; i++ {
+		name := m.visible[i].Name
+		if i == m.cursor {
+			list.WriteString(selectedStyle.Render("> "+name) + "\n")
+		} else {
+			list.WriteString("  " + name + "\n")
+		}
+	}
+	listWidth := 28
+	detailWidth := max(m.width-listWidth-8, 40)
+	left := paneStyle.Width(listWidth).Render(strings.TrimRight(list.String(), "\n"))
+	right := paneStyle.Width(detailWidth).Render(m.viewSecret(m.selected()))
+	return lipgloss.JoinHorizontal(lipgloss.Top, left, right) + "\n"
+}
+
+// viewSecret shows the secret, the sensitive fields are masked unless revealed
+func (m *model) viewSecret(s *vault.Secret) string {
+	if s == nil {
+		return dimStyle.Render("No secret selected")
+	}
+	secret := func(v string) string {
+		if len(v) == 0 || m.reveal {
+			return v
+		}
+		return mask
+	}
+	var b strings.Builder
+	field := func(label, value string) {
+		b.WriteString(labelStyle.Render(label) + value + "\n")
+	}
+	b.WriteString(selectedStyle.Render(s.Name) + "\n\n")
+	field("Login", s.Login)
+	field("Password", secret(s.Pwd))
+	field("URL", s.Url)
+	if otp := otpSecret(s); len(otp) > 0 {
+		value := mask
+		if m.reveal {
+			code, left, err := security.TOTP(otp, m.now())
+			if err != nil {
+				value = errorStyle.Render(err.Error())
+			} else {
+				value = fmt.Sprintf("%s (%ds)", code, left)
+			}
+		}
+		field("OTP", value)
+	}
+	if len(s.Tags) > 0 {
+		field("Tags", strings.Join(s.Tags, ", "))
+	}
+	field("Version", s.Version)
+	field("Updated", s.LastUpdated)
+
+	var keys []string
+	for k := range s.Others {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if len(keys) > 0 {
+		b.WriteString("\n" + titleStyle.Render("Items") + "\n")
+		for _, k := range keys {
+			b.WriteString(fmt.Sprintf("  %s %s\n", dimStyle.Render(k+":"), secret(s.Others[k])))
+		}
+	}
+	if len(s.Notes) > 0 {
+		b.WriteString("\n" + titleStyle.Render("Notes") + "\n" + s.Notes)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,10 @@
+package cmd
+
+import (
+	"github.com/mas2020-golang/cryptex/cmd/tui"
+	"github.com/spf13/cobra"
+)
+
+func newUICmd() *cobra.Command {
+	return tui.NewCmd()
+}
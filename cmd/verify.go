@@ -0,0 +1,77 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mas2020-golang/cryptex/packages/security"
+	"github.com/mas2020-golang/cryptex/packages/utils"
+	"github.com/mas2020-golang/goutils/output"
+	"github.com/spf13/cobra"
+)
+
+func newVerifyCmd() *cobra.Command {
+	opts := cryptOptions{}
+	c := &cobra.Command{
+		Use:   "verify <FOLDER>",
+		Args:  cobra.ExactArgs(1),
+		Short: "Verify an encrypted folder against its manifest",
+		Long: `The folder encryption writes a manifest (.raptor-manifest) sealed with the password,
+listing the encrypted files with their size and the MAC of their content. The command
+reports the files of the manifest that are missing, the encrypted files not in the
+manifest (extra), the files holding the content of another one (swapped) and the files
+with an unknown content, e.g. an older version (stale).
+
+The decrypt command runs the same verification and refuses to decrypt a folder that
+doesn't match its manifest, unless --ignore-manifest is given.`,
+		Example: `$ raptor verify ~/docs
+$ raptor verify --pwd-env DOCS_PWD /backup/docs`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			report, err := verify(args[0], &opts)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Manifest of %s written on %s\n", args[0], report.Created.Format(time.DateTime))
+			if report.OK() {
+				utils.Success(fmt.Sprintf("%d files verified", report.Files))
+				return nil
+			}
+			for _, l := range []struct {
+				name  string
+				paths []string
+			}{{"missing", report.Missing}, {"extra", report.Extra}, {"swapped", report.Swapped}, {"stale", report.Stale}} {
+				for _, p := range l.paths {
+					fmt.Printf("%s %s\n", output.RedBoldS(fmt.Sprintf("%-8s", l.name)), p)
+				}
+			}
+			return security.ErrManifest
+		},
+	}
+	c.Flags().IntVar(&opts.pwdFd, "pwd-fd", -1, "Read the password from the first line of this file descriptor")
+	c.Flags().StringVar(&opts.pwdEnv, "pwd-env", "", "Read the password from this environment variable")
+
+	return c
+}
+
+// verify checks the folder against its manifest
+func verify(path string, opts *cryptOptions) (*security.VerifyReport, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("error accessing the path %s: %v", path, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a folder", path)
+	}
+	if !security.HasManifest(path) {
+		return nil, fmt.Errorf("no manifest found in %s", path)
+	}
+	passphrase, err := opts.passphrase(path, false)
+	if err != nil {
+		return nil, err
+	}
+	return security.VerifyDirectory(path, passphrase)
+}
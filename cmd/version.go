@@ -8,10 +8,6 @@ import (
 	"github.com/spf13/cobra"
 )
 
-func init() {
-	rootCmd.AddCommand(NewVersionCmd())
-}
-
 func NewVersionCmd() *cobra.Command {
 	newCmd := &cobra.Command{
 		Use:   "version",
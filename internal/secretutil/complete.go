@@ -0,0 +1,55 @@
+package secretutil
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/mas2020-golang/cryptex/packages/utils"
+	"github.com/mas2020-golang/cryptex/pkg/vault"
+	"github.com/spf13/cobra"
+)
+
+// CompleteNames is the ValidArgsFunction of the commands taking a secret
+// reference: it completes the names of the secrets of the open box and, after
+// the dot, the keys of their items. Nothing is completed out of a session.
+func CompleteNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return Complete(sessionBox(cmd), toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// sessionBox returns the box of the session the command runs in, nil if none
+func sessionBox(cmd *cobra.Command) *vault.Box {
+	if cmd.Context() == nil {
+		return nil
+	}
+	if v := utils.Session(cmd.Context()); v != nil {
+		return v.Box()
+	}
+	return nil
+}
+
+// Complete returns the sorted secret references of the box (e.g. "foo" or
+// "foo.item") starting with the prefix
+func Complete(box *vault.Box, prefix string) []string {
+	if box == nil {
+		return nil
+	}
+	var refs []string
+	name, item, isItem := strings.Cut(prefix, ".")
+	for _, s := range box.Secrets {
+		switch {
+		case !isItem && strings.HasPrefix(s.Name, prefix):
+			refs = append(refs, s.Name)
+		case isItem && s.Name == name:
+			for _, k := range s.Items() {
+				if strings.HasPrefix(k, item) {
+					refs = append(refs, name+"."+k)
+				}
+			}
+		}
+	}
+	sort.Strings(refs)
+	return refs
+}
@@ -1,37 +1,44 @@
 package secretutil
 
 import (
+	"context"
 	"log/slog"
 	"strings"
 
 	"github.com/mas2020-golang/cryptex/packages/utils"
+	"github.com/mas2020-golang/cryptex/pkg/vault"
 )
 
 // LookupResult describes the outcome of a secret lookup.
 type LookupResult struct {
-	Secret *utils.Secret
+	Secret *vault.Secret
 	Value  string
 	Item   string
 }
 
 // Lookup resolves the given secret reference (e.g. "foo" or "foo.item") within the
 // provided box name. It returns both the secret pointer and the resolved value
-// (password or item content) if present.
-func Lookup(boxName, name string) (*LookupResult, *utils.Box, error) {
-	_, _, box, err := utils.OpenBox(boxName, "")
+// (password or item content) if present, with the open box to close with
+// utils.CloseBox once the result is used.
+func Lookup(ctx context.Context, boxName, name string) (*LookupResult, *vault.Vault, error) {
+	v, err := utils.OpenBox(ctx, boxName, "")
 	if err != nil {
 		return nil, nil, err
 	}
 
-	secret, value, item := findSecretValue(name, box)
+	secret, value, item, err := findSecretValue(name, v.Box())
+	if err != nil {
+		utils.CloseBox(ctx, v)
+		return nil, nil, err
+	}
 	return &LookupResult{
 		Secret: secret,
 		Value:  value,
 		Item:   item,
-	}, box, nil
+	}, v, nil
 }
 
-func findSecretValue(name string, box *utils.Box) (secret *utils.Secret, value, item string) {
+func findSecretValue(name string, box *vault.Box) (secret *vault.Secret, value, item string, err error) {
 	var (
 		secretName string
 	)
@@ -45,28 +52,22 @@ func findSecretValue(name string, box *utils.Box) (secret *utils.Secret, value,
 	slog.Debug("secretutil.findSecretValue()", "secretName", secretName, "secretItem", item)
 
 	if box == nil || box.Secrets == nil {
-		return nil, "", item
+		return nil, "", item, nil
 	}
 
-	for _, s := range box.Secrets {
-		if secretName != s.Name {
-			continue
-		}
-		// Keep pointer to the matching secret regardless of item status.
-		secret = s
-
-		if len(item) > 0 {
-			if len(s.Others) == 0 {
-				continue
-			}
-			if v, ok := s.Others[item]; ok {
-				value = v
-			}
-			continue
-		}
-
-		value = s.Pwd
+	secret = box.Secret(secretName)
+	if secret == nil {
+		return nil, "", item, nil
+	}
+	// the password and the items are decoded only for the matching secret
+	if err := secret.Load(); err != nil {
+		return nil, "", item, err
+	}
+	if len(item) > 0 {
+		value = secret.Others[item]
+	} else {
+		value = secret.Pwd
 	}
 
-	return secret, value, item
+	return secret, value, item, nil
 }
@@ -0,0 +1,226 @@
+package exporter
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mas2020-golang/cryptex/packages/importer"
+	"github.com/mas2020-golang/cryptex/pkg/vault"
+	"gopkg.in/yaml.v2"
+)
+
+// Supported export formats
+const (
+	FormatJSON          = "json"
+	FormatYAML          = "yaml"
+	FormatCSV           = "csv"
+	FormatBitwardenJSON = importer.FormatBitwardenJSON
+)
+
+// Formats returns the list of the supported formats
+func Formats() []string {
+	return []string{FormatJSON, FormatYAML, FormatCSV, FormatBitwardenJSON}
+}
+
+// Portable is the box representation used for the json and yaml formats
+type Portable struct {
+	Box      string           `json:"box" yaml:"box"`
+	Exported string           `json:"exported" yaml:"exported"`
+	Secrets  []PortableSecret `json:"secrets" yaml:"secrets"`
+}
+
+// PortableSecret is the secret representation used for the json and yaml formats
+type PortableSecret struct {
+	Name        string            `json:"name" yaml:"name"`
+	Version     string            `json:"version,omitempty" yaml:"version,omitempty"`
+	Login       string            `json:"login,omitempty" yaml:"login,omitempty"`
+	Pwd         string            `json:"pwd,omitempty" yaml:"pwd,omitempty"`
+	Url         string            `json:"url,omitempty" yaml:"url,omitempty"`
+	Notes       string            `json:"notes,omitempty" yaml:"notes,omitempty"`
+	Items       map[string]string `json:"items,omitempty" yaml:"items,omitempty"`
+	Tags        []string          `json:"tags,omitempty" yaml:"tags,omitempty"`
+	LastUpdated string            `json:"lastUpdated,omitempty" yaml:"lastUpdated,omitempty"`
+}
+
+// Filter returns the secrets of the box having at least one of the given tags
+// (if any) and a name matching the regexp (if not empty). The secrets not
+// exportable by the policy of the box are left out.
+func Filter(box *vault.Box, tags []string, filter string) ([]*vault.Secret, error) {
+	var r *regexp.Regexp
+	if len(filter) > 0 {
+		var err error
+		if r, err = regexp.Compile(filter); err != nil {
+			return nil, fmt.Errorf("invalid regex pattern '%s': %w", filter, err)
+		}
+	}
+
+	secrets := make([]*vault.Secret, 0, len(box.Secrets))
+	for _, s := range box.Secrets {
+		if !box.Exportable(s.Name) {
+			continue
+		}
+		if r != nil && !r.MatchString(s.Name) {
+			continue
+		}
+		if len(tags) > 0 && !hasAnyTag(s, tags) {
+			continue
+		}
+		secrets = append(secrets, s)
+	}
+	return secrets, nil
+}
+
+func hasAnyTag(s *vault.Secret, tags []string) bool {
+	for _, want := range tags {
+		for _, t := range s.Tags {
+			if t == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Export writes the secrets into w using the given format
+func Export(w io.Writer, format, boxName string, secrets []*vault.Secret) error {
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(toPortable(boxName, secrets))
+	case FormatYAML:
+		out, err := yaml.Marshal(toPortable(boxName, secrets))
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(out)
+		return err
+	case FormatCSV:
+		return exportCSV(w, secrets)
+	case FormatBitwardenJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(toBitwarden(secrets))
+	default:
+		return fmt.Errorf("unknown format %q, use one of: %s", format, strings.Join(Formats(), ", "))
+	}
+}
+
+func toPortable(boxName string, secrets []*vault.Secret) Portable {
+	p := Portable{
+		Box:      boxName,
+		Exported: time.Now().Format(time.RFC3339),
+		Secrets:  make([]PortableSecret, 0, len(secrets)),
+	}
+	for _, s := range secrets {
+		p.Secrets = append(p.Secrets, PortableSecret{
+			Name:        s.Name,
+			Version:     s.Version,
+			Login:       s.Login,
+			Pwd:         s.Pwd,
+			Url:         s.Url,
+			Notes:       s.Notes,
+			Items:       s.Others,
+			Tags:        s.Tags,
+			LastUpdated: s.LastUpdated,
+		})
+	}
+	return p
+}
+
+// exportCSV writes a row for each secret: the items are written in the
+// item:<KEY> columns
+func exportCSV(w io.Writer, secrets []*vault.Secret) error {
+	keys := make(map[string]bool)
+	for _, s := range secrets {
+		for k := range s.Others {
+			keys[k] = true
+		}
+	}
+	itemKeys := make([]string, 0, len(keys))
+	for k := range keys {
+		itemKeys = append(itemKeys, k)
+	}
+	sort.Strings(itemKeys)
+
+	cw := csv.NewWriter(w)
+	header := []string{"name", "version", "login", "password", "url", "notes", "tags", "lastUpdated"}
+	for _, k := range itemKeys {
+		header = append(header, "item:"+k)
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, s := range secrets {
+		record := []string{s.Name, s.Version, s.Login, s.Pwd, s.Url, s.Notes, strings.Join(s.Tags, ";"), s.LastUpdated}
+		for _, k := range itemKeys {
+			record = append(record, s.Others[k])
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// toBitwarden maps the secrets onto a Bitwarden unencrypted export: the first
+// tag of a secret becomes its folder and the items become custom fields
+func toBitwarden(secrets []*vault.Secret) importer.BitwardenExport {
+	export := importer.BitwardenExport{
+		Folders: make([]importer.BitwardenFolder, 0),
+		Items:   make([]importer.BitwardenItem, 0, len(secrets)),
+	}
+	folders := make(map[string]string)
+	for _, s := range secrets {
+		item := importer.BitwardenItem{
+			Type:  1,
+			Name:  s.Name,
+			Notes: optional(s.Notes),
+			Login: &importer.BitwardenLogin{
+				Username: optional(s.Login),
+				Password: optional(s.Pwd),
+			},
+		}
+		if len(s.Url) > 0 {
+			item.Login.Uris = []importer.BitwardenUri{{Uri: s.Url}}
+		}
+		if len(s.Tags) > 0 {
+			id, ok := folders[s.Tags[0]]
+			if !ok {
+				id = fmt.Sprintf("raptor-folder-%d", len(folders)+1)
+				folders[s.Tags[0]] = id
+				export.Folders = append(export.Folders, importer.BitwardenFolder{Id: id, Name: s.Tags[0]})
+			}
+			item.FolderId = &id
+		}
+		keys := make([]string, 0, len(s.Others))
+		for k := range s.Others {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if k == "totp" {
+				item.Login.Totp = optional(s.Others[k])
+				continue
+			}
+			// hidden field
+			item.Fields = append(item.Fields, importer.BitwardenField{Name: k, Value: s.Others[k], Type: 1})
+		}
+		export.Items = append(export.Items, item)
+	}
+	return export
+}
+
+func optional(s string) *string {
+	if len(s) == 0 {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,95 @@
+package exporter
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+
+	"github.com/mas2020-golang/cryptex/pkg/vault"
+)
+
+func testBox() *vault.Box {
+	return &vault.Box{
+		Name: "test-box",
+		Secrets: []*vault.Secret{
+			{Name: "aws-prod", Pwd: "p1", Tags: []string{"work"}, Others: map[string]string{"key": "k1"}},
+			{Name: "aws-dev", Pwd: "p2", Tags: []string{"work", "dev"}},
+			{Name: "bank", Pwd: "p3", Tags: []string{"personal"}, Others: map[string]string{"pin": "0000"}},
+		},
+	}
+}
+
+// TestFilter_TagsAndRegexp tests that both the tags and the regexp are applied
+func TestFilter_TagsAndRegexp(t *testing.T) {
+	secrets, err := Filter(testBox(), []string{"work"}, "prod$")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(secrets) != 1 || secrets[0].Name != "aws-prod" {
+		t.Errorf("Expected only aws-prod, got %d secrets", len(secrets))
+	}
+
+	secrets, err = Filter(testBox(), []string{"dev", "personal"}, "")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(secrets) != 2 {
+		t.Errorf("Expected 2 secrets, got %d", len(secrets))
+	}
+}
+
+// TestFilter_Policy tests that the secrets not exportable are left out
+func TestFilter_Policy(t *testing.T) {
+	box := testBox()
+	box.Policy = &vault.Policy{NotExportable: []string{"aws-*"}}
+	secrets, err := Filter(box, nil, "")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	for _, s := range secrets {
+		if s.Name == "aws-prod" {
+			t.Errorf("Expected aws-prod to be left out")
+		}
+	}
+	if len(secrets) == len(box.Secrets) || len(secrets) == 0 {
+		t.Errorf("Expected only the exportable secrets, got %d", len(secrets))
+	}
+}
+
+// TestFilter_InvalidRegexp tests that an invalid regexp returns an error
+func TestFilter_InvalidRegexp(t *testing.T) {
+	if _, err := Filter(testBox(), nil, "("); err == nil {
+		t.Error("Expected an error, got nil")
+	}
+}
+
+// TestExport_CSV tests that every item key gets its own column
+func TestExport_CSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Export(&buf, FormatCSV, "test-box", testBox().Secrets); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Invalid CSV: %v", err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("Expected 4 records, got %d", len(records))
+	}
+	header := records[0]
+	if header[len(header)-2] != "item:key" || header[len(header)-1] != "item:pin" {
+		t.Errorf("Unexpected header: %v", header)
+	}
+	if records[1][3] != "p1" || records[1][len(header)-2] != "k1" || records[3][len(header)-1] != "0000" {
+		t.Errorf("Unexpected records: %v", records)
+	}
+}
+
+// TestExport_UnknownFormat tests that an unknown format returns an error
+func TestExport_UnknownFormat(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Export(&buf, "xml", "test-box", nil); err == nil {
+		t.Error("Expected an error, got nil")
+	}
+}
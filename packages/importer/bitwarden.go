@@ -0,0 +1,135 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/mas2020-golang/cryptex/pkg/vault"
+)
+
+// Bitwarden item types
+const (
+	bitwardenLogin      = 1
+	bitwardenSecureNote = 2
+	bitwardenCard       = 3
+	bitwardenIdentity   = 4
+)
+
+// BitwardenExport is the unencrypted JSON export of a Bitwarden vault. It is
+// exported to be reused for the export in the same format.
+type BitwardenExport struct {
+	Encrypted bool              `json:"encrypted"`
+	Folders   []BitwardenFolder `json:"folders"`
+	Items     []BitwardenItem   `json:"items"`
+}
+
+type BitwardenFolder struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type BitwardenItem struct {
+	Id         string           `json:"id,omitempty"`
+	FolderId   *string          `json:"folderId"`
+	Type       int              `json:"type"`
+	Name       string           `json:"name"`
+	Notes      *string          `json:"notes"`
+	Favorite   bool             `json:"favorite"`
+	Fields     []BitwardenField `json:"fields,omitempty"`
+	Login      *BitwardenLogin  `json:"login,omitempty"`
+	SecureNote *BitwardenNote   `json:"secureNote,omitempty"`
+	Card       map[string]any   `json:"card,omitempty"`
+	Identity   map[string]any   `json:"identity,omitempty"`
+}
+
+type BitwardenNote struct {
+	Type int `json:"type"`
+}
+
+type BitwardenField struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	Type  int    `json:"type"`
+}
+
+type BitwardenLogin struct {
+	Username *string        `json:"username"`
+	Password *string        `json:"password"`
+	Totp     *string        `json:"totp"`
+	Uris     []BitwardenUri `json:"uris,omitempty"`
+}
+
+type BitwardenUri struct {
+	Match *int   `json:"match"`
+	Uri   string `json:"uri"`
+}
+
+func parseBitwardenJSON(path string) ([]*vault.Secret, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	export := BitwardenExport{}
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, err
+	}
+	if export.Encrypted {
+		return nil, fmt.Errorf("encrypted Bitwarden exports are not supported, export the vault as plain JSON")
+	}
+
+	folders := make(map[string]string, len(export.Folders))
+	for _, f := range export.Folders {
+		folders[f.Id] = f.Name
+	}
+
+	secrets := make([]*vault.Secret, 0, len(export.Items))
+	for _, item := range export.Items {
+		s := &vault.Secret{Name: item.Name, Notes: deref(item.Notes)}
+		if item.FolderId != nil {
+			addTag(s, folders[*item.FolderId])
+		}
+		switch item.Type {
+		case bitwardenLogin:
+			if item.Login != nil {
+				s.Login = deref(item.Login.Username)
+				s.Pwd = deref(item.Login.Password)
+				addOther(s, "totp", deref(item.Login.Totp))
+				for i, u := range item.Login.Uris {
+					if i == 0 {
+						s.Url = u.Uri
+						continue
+					}
+					addOther(s, "url", u.Uri)
+				}
+			}
+		case bitwardenCard:
+			addAnyFields(s, "card", item.Card)
+		case bitwardenIdentity:
+			addAnyFields(s, "identity", item.Identity)
+		}
+		for _, f := range item.Fields {
+			addOther(s, f.Name, f.Value)
+		}
+		secrets = append(secrets, s)
+	}
+	return secrets, nil
+}
+
+// addAnyFields stores every non empty field of a card or identity item into
+// the secret items using the prefix as a namespace
+func addAnyFields(s *vault.Secret, prefix string, fields map[string]any) {
+	for k, v := range fields {
+		if v == nil {
+			continue
+		}
+		addOther(s, prefix+"-"+k, fmt.Sprintf("%v", v))
+	}
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
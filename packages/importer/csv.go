@@ -0,0 +1,111 @@
+package importer
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mas2020-golang/cryptex/pkg/vault"
+)
+
+// csvColumns maps the lowercase header of a CSV export onto a secret field.
+// Columns not listed here are stored as items of the secret.
+type csvColumns map[string]string
+
+var (
+	// 1Password 7 and 8 CSV exports
+	onePasswordColumns = csvColumns{
+		"title":    "name",
+		"url":      "url",
+		"website":  "url",
+		"username": "login",
+		"password": "pwd",
+		"notes":    "notes",
+		"tags":     "tags",
+		"otpauth":  "totp",
+		"favorite": "-",
+		"archived": "-",
+	}
+	// Chrome / Chromium / Edge passwords export
+	chromeColumns = csvColumns{
+		"name":     "name",
+		"url":      "url",
+		"username": "login",
+		"password": "pwd",
+		"note":     "notes",
+	}
+)
+
+func parse1PasswordCSV(path string) ([]*vault.Secret, error) {
+	return parseCSV(path, onePasswordColumns)
+}
+
+func parseChromeCSV(path string) ([]*vault.Secret, error) {
+	return parseCSV(path, chromeColumns)
+}
+
+// parseCSV reads a CSV file with a header row mapping every record onto a secret
+func parseCSV(path string, columns csvColumns) ([]*vault.Secret, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the CSV header: %w", err)
+	}
+	for i, h := range header {
+		// remove the UTF-8 BOM some exports start with
+		header[i] = strings.TrimPrefix(strings.TrimSpace(h), "\ufeff")
+	}
+
+	secrets := make([]*vault.Secret, 0)
+	for line := 2; ; line++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		s := &vault.Secret{}
+		for i, value := range record {
+			if i >= len(header) {
+				break
+			}
+			switch columns[strings.ToLower(header[i])] {
+			case "name":
+				s.Name = value
+			case "url":
+				s.Url = value
+			case "login":
+				s.Login = value
+			case "pwd":
+				s.Pwd = value
+			case "notes":
+				s.Notes = value
+			case "tags":
+				for _, t := range strings.FieldsFunc(value, func(r rune) bool { return r == ';' || r == ',' }) {
+					addTag(s, t)
+				}
+			case "totp":
+				addOther(s, "totp", value)
+			case "-":
+			default:
+				addOther(s, header[i], value)
+			}
+		}
+		// Chrome exports have an empty name for some entries
+		if len(s.Name) == 0 {
+			s.Name = s.Url
+		}
+		secrets = append(secrets, s)
+	}
+	return secrets, nil
+}
@@ -0,0 +1,203 @@
+package importer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mas2020-golang/cryptex/pkg/vault"
+)
+
+// Supported import formats
+const (
+	FormatKeePassXML    = "keepass-xml"
+	FormatBitwardenJSON = "bitwarden-json"
+	Format1PasswordCSV  = "1password-csv"
+	FormatChromeCSV     = "chrome-csv"
+	FormatPass          = "pass"
+)
+
+// Duplicate policies applied when an imported secret has the same name of an
+// existing one
+const (
+	OnDuplicateSkip      = "skip"
+	OnDuplicateOverwrite = "overwrite"
+	OnDuplicateRename    = "rename"
+)
+
+// parsers maps every supported format to the function that reads it
+var parsers = map[string]func(path string) ([]*vault.Secret, error){
+	FormatKeePassXML:    parseKeePassXML,
+	FormatBitwardenJSON: parseBitwardenJSON,
+	Format1PasswordCSV:  parse1PasswordCSV,
+	FormatChromeCSV:     parseChromeCSV,
+	FormatPass:          parsePass,
+}
+
+// Formats returns the sorted list of the supported formats
+func Formats() []string {
+	formats := make([]string, 0, len(parsers))
+	for f := range parsers {
+		formats = append(formats, f)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// Parse reads the file (or the folder for the pass format) at the given path
+// and maps every entry onto a vault.Secret
+func Parse(format, path string) ([]*vault.Secret, error) {
+	parse, ok := parsers[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown format %q, use one of: %s", format, strings.Join(Formats(), ", "))
+	}
+	secrets, err := parse(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to import %s from %s: %w", format, path, err)
+	}
+	now := time.Now().Format(time.RFC3339)
+	for _, s := range secrets {
+		s.Name = SanitizeName(s.Name)
+		if len(s.Version) == 0 {
+			s.Version = "1.0.0"
+		}
+		if len(s.LastUpdated) == 0 {
+			s.LastUpdated = now
+		}
+	}
+	return secrets, nil
+}
+
+// SanitizeName returns a name usable as a secret name or item key: the '.' char
+// is reserved to refer to the items of a secret (e.g. foo.bar)
+func SanitizeName(name string) string {
+	name = strings.TrimSpace(name)
+	if len(name) == 0 {
+		name = "unnamed"
+	}
+	return strings.ReplaceAll(name, ".", "_")
+}
+
+// Action is what the import is going to do with a single secret
+type Action struct {
+	Secret *vault.Secret
+	// Op is one of add, skip, overwrite, rename
+	Op string
+	// OriginalName is the name before a rename
+	OriginalName string
+}
+
+// Summary collects the actions planned by Merge
+type Summary struct {
+	Actions []Action
+}
+
+// Count returns how many actions of the given op are in the summary
+func (s *Summary) Count(op string) int {
+	n := 0
+	for _, a := range s.Actions {
+		if a.Op == op {
+			n++
+		}
+	}
+	return n
+}
+
+// Changes returns the number of actions that modify the box
+func (s *Summary) Changes() int {
+	return len(s.Actions) - s.Count(OnDuplicateSkip)
+}
+
+// DuplicateFunc decides what to do with a secret whose name is already taken.
+// It returns one of the OnDuplicate* policies.
+type DuplicateFunc func(s *vault.Secret) string
+
+// Plan computes the actions needed to merge the imported secrets into the box
+// without modifying it. The decide func is called for each duplicate.
+func Plan(box *vault.Box, secrets []*vault.Secret, decide DuplicateFunc) (*Summary, error) {
+	names := make(map[string]bool)
+	for _, s := range box.Secrets {
+		names[s.Name] = true
+	}
+
+	summary := &Summary{}
+	for _, s := range secrets {
+		if !names[s.Name] {
+			names[s.Name] = true
+			summary.Actions = append(summary.Actions, Action{Secret: s, Op: "add"})
+			continue
+		}
+		policy := decide(s)
+		switch policy {
+		case OnDuplicateSkip, OnDuplicateOverwrite:
+			summary.Actions = append(summary.Actions, Action{Secret: s, Op: policy})
+		case OnDuplicateRename:
+			original := s.Name
+			s.Name = uniqueName(original, names)
+			names[s.Name] = true
+			summary.Actions = append(summary.Actions, Action{Secret: s, Op: policy, OriginalName: original})
+		default:
+			return nil, fmt.Errorf("unknown duplicate policy %q", policy)
+		}
+	}
+	return summary, nil
+}
+
+// Apply executes the planned actions on the box, the overwritten secrets are
+// wiped from memory
+func Apply(box *vault.Box, summary *Summary) {
+	for _, a := range summary.Actions {
+		switch a.Op {
+		case "add", OnDuplicateRename, OnDuplicateOverwrite:
+			if old := box.Secret(a.Secret.Name); old != nil && old != a.Secret {
+				old.Wipe()
+			}
+			box.Put(a.Secret)
+		}
+	}
+}
+
+// uniqueName appends a numeric suffix to name until it is not taken
+func uniqueName(name string, taken map[string]bool) string {
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s-%d", name, i)
+		if !taken[candidate] {
+			return candidate
+		}
+	}
+}
+
+// addOther adds the key/value pair into the Others map of the secret, skipping
+// empty values and renaming clashing keys
+func addOther(s *vault.Secret, key, value string) {
+	if len(value) == 0 {
+		return
+	}
+	if s.Others == nil {
+		s.Others = make(map[string]string)
+	}
+	key = SanitizeName(key)
+	if _, ok := s.Others[key]; ok {
+		taken := make(map[string]bool, len(s.Others))
+		for k := range s.Others {
+			taken[k] = true
+		}
+		key = uniqueName(key, taken)
+	}
+	s.Others[key] = value
+}
+
+// addTag adds a tag to the secret if not present yet
+func addTag(s *vault.Secret, tag string) {
+	tag = strings.TrimSpace(tag)
+	if len(tag) == 0 {
+		return
+	}
+	for _, t := range s.Tags {
+		if t == tag {
+			return
+		}
+	}
+	s.Tags = append(s.Tags, tag)
+}
@@ -0,0 +1,255 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mas2020-golang/cryptex/pkg/vault"
+)
+
+// writeFile writes the content into a temporary file and returns its path
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+	return path
+}
+
+// TestParse_KeePassXML tests that groups become tags and custom strings become items
+func TestParse_KeePassXML(t *testing.T) {
+	path := writeFile(t, "db.xml", `<?xml version="1.0" encoding="utf-8"?>
+<KeePassFile><Root><Group><Name>Database</Name>
+  <Entry>
+    <String><Key>Title</Key><Value>github.com</Value></String>
+    <String><Key>UserName</Key><Value>octocat</Value></String>
+    <String><Key>Password</Key><Value ProtectInMemory="True">s3cr3t</Value></String>
+    <String><Key>URL</Key><Value>https://github.com</Value></String>
+    <String><Key>recovery.code</Key><Value>1234</Value></String>
+  </Entry>
+  <Group><Name>Work</Name>
+    <Group><Name>Cloud</Name>
+      <Entry><String><Key>Title</Key><Value>aws</Value></String><Tags>prod;infra</Tags></Entry>
+    </Group>
+  </Group>
+</Group></Root></KeePassFile>`)
+
+	secrets, err := Parse(FormatKeePassXML, path)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(secrets) != 2 {
+		t.Fatalf("Expected 2 secrets, got %d", len(secrets))
+	}
+
+	s := secrets[0]
+	if s.Name != "github_com" || s.Login != "octocat" || s.Pwd != "s3cr3t" || s.Url != "https://github.com" {
+		t.Errorf("Unexpected secret: %+v", s)
+	}
+	if s.Others["recovery_code"] != "1234" {
+		t.Errorf("Expected the recovery_code item, got %v", s.Others)
+	}
+	if len(s.Tags) != 0 {
+		t.Errorf("Expected no tags for the root group, got %v", s.Tags)
+	}
+
+	want := []string{"Work/Cloud", "prod", "infra"}
+	if len(secrets[1].Tags) != len(want) {
+		t.Fatalf("Expected tags %v, got %v", want, secrets[1].Tags)
+	}
+	for i := range want {
+		if secrets[1].Tags[i] != want[i] {
+			t.Errorf("Expected tags %v, got %v", want, secrets[1].Tags)
+		}
+	}
+}
+
+// TestParse_BitwardenJSON tests the login fields, the folders and the custom fields
+func TestParse_BitwardenJSON(t *testing.T) {
+	path := writeFile(t, "export.json", `{"encrypted": false,
+  "folders": [{"id": "f1", "name": "Banking"}],
+  "items": [
+    {"type": 1, "name": "bank", "folderId": "f1", "notes": "pin in the drawer",
+     "login": {"username": "me", "password": "pwd", "totp": "JBSWY3DPEHPK3PXP",
+               "uris": [{"uri": "https://bank.example"}, {"uri": "https://m.bank.example"}]},
+     "fields": [{"name": "customer", "value": "42", "type": 0}]},
+    {"type": 2, "name": "note", "folderId": null, "notes": "just a note", "secureNote": {"type": 0}}
+  ]}`)
+
+	secrets, err := Parse(FormatBitwardenJSON, path)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(secrets) != 2 {
+		t.Fatalf("Expected 2 secrets, got %d", len(secrets))
+	}
+
+	s := secrets[0]
+	if s.Login != "me" || s.Pwd != "pwd" || s.Url != "https://bank.example" || s.Notes != "pin in the drawer" {
+		t.Errorf("Unexpected secret: %+v", s)
+	}
+	if s.Others["totp"] != "JBSWY3DPEHPK3PXP" || s.Others["url"] != "https://m.bank.example" || s.Others["customer"] != "42" {
+		t.Errorf("Unexpected items: %v", s.Others)
+	}
+	if len(s.Tags) != 1 || s.Tags[0] != "Banking" {
+		t.Errorf("Expected the Banking tag, got %v", s.Tags)
+	}
+	if secrets[1].Notes != "just a note" || len(secrets[1].Tags) != 0 {
+		t.Errorf("Unexpected secure note: %+v", secrets[1])
+	}
+}
+
+// TestParse_BitwardenEncrypted tests that an encrypted export is refused
+func TestParse_BitwardenEncrypted(t *testing.T) {
+	path := writeFile(t, "export.json", `{"encrypted": true, "items": []}`)
+
+	if _, err := Parse(FormatBitwardenJSON, path); err == nil {
+		t.Error("Expected an error for an encrypted export, got nil")
+	}
+}
+
+// TestParse_1PasswordCSV tests the header mapping and the unknown columns
+func TestParse_1PasswordCSV(t *testing.T) {
+	path := writeFile(t, "export.csv", "\ufeffTitle,Url,Username,Password,OTPAuth,Favorite,Archived,Tags,Notes,Pin\n"+
+		"mail,https://mail.example,me@example.com,pwd,otpauth://totp/x,false,false,personal;mail,\"multi\nline\",0000\n")
+
+	secrets, err := Parse(Format1PasswordCSV, path)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(secrets) != 1 {
+		t.Fatalf("Expected 1 secret, got %d", len(secrets))
+	}
+	s := secrets[0]
+	if s.Name != "mail" || s.Url != "https://mail.example" || s.Login != "me@example.com" || s.Pwd != "pwd" || s.Notes != "multi\nline" {
+		t.Errorf("Unexpected secret: %+v", s)
+	}
+	if s.Others["totp"] != "otpauth://totp/x" || s.Others["Pin"] != "0000" || len(s.Others) != 2 {
+		t.Errorf("Unexpected items: %v", s.Others)
+	}
+	if len(s.Tags) != 2 {
+		t.Errorf("Expected 2 tags, got %v", s.Tags)
+	}
+}
+
+// TestParse_ChromeCSV tests that an empty name falls back to the url
+func TestParse_ChromeCSV(t *testing.T) {
+	path := writeFile(t, "chrome.csv", "name,url,username,password,note\n,https://site.example/login,me,pwd,\n")
+
+	secrets, err := Parse(FormatChromeCSV, path)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(secrets) != 1 || secrets[0].Name != "https://site_example/login" {
+		t.Errorf("Unexpected secrets: %+v", secrets[0])
+	}
+}
+
+// TestParse_Pass tests the pass store walk replacing the gpg decryption
+func TestParse_Pass(t *testing.T) {
+	store := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(store, "work", "db"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"work/db/postgres.gpg", "email.gpg", ".gpg-id"} {
+		if err := os.WriteFile(filepath.Join(store, p), []byte("x"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	original := gpgDecrypt
+	defer func() { gpgDecrypt = original }()
+	gpgDecrypt = func(path string) ([]byte, error) {
+		return []byte("pwd\nlogin: admin\nurl: https://db.example\nport: 5432\nhttps://docs.example\n"), nil
+	}
+
+	secrets, err := Parse(FormatPass, store)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(secrets) != 2 {
+		t.Fatalf("Expected 2 secrets, got %d", len(secrets))
+	}
+	// WalkDir visits the entries in lexical order
+	if secrets[0].Name != "email" || len(secrets[0].Tags) != 0 {
+		t.Errorf("Unexpected secret: %+v", secrets[0])
+	}
+	s := secrets[1]
+	if s.Name != "postgres" || s.Pwd != "pwd" || s.Login != "admin" || s.Url != "https://db.example" {
+		t.Errorf("Unexpected secret: %+v", s)
+	}
+	if s.Others["port"] != "5432" || s.Notes != "https://docs.example" {
+		t.Errorf("Unexpected items or notes: %v %q", s.Others, s.Notes)
+	}
+	if len(s.Tags) != 1 || s.Tags[0] != "work/db" {
+		t.Errorf("Expected the work/db tag, got %v", s.Tags)
+	}
+}
+
+// TestParse_UnknownFormat tests that an unknown format returns an error
+func TestParse_UnknownFormat(t *testing.T) {
+	if _, err := Parse("lastpass", "any"); err == nil {
+		t.Error("Expected an error, got nil")
+	}
+}
+
+// TestPlan_Duplicates tests the skip, overwrite and rename policies
+func TestPlan_Duplicates(t *testing.T) {
+	box := &vault.Box{
+		Secrets: []*vault.Secret{
+			{Name: "a", Pwd: "old-a"},
+			{Name: "b", Pwd: "old-b"},
+			{Name: "c", Pwd: "old-c"},
+			{Name: "c-1", Pwd: "old-c-1"},
+		},
+	}
+	imported := []*vault.Secret{
+		{Name: "a", Pwd: "new-a"},
+		{Name: "b", Pwd: "new-b"},
+		{Name: "c", Pwd: "new-c"},
+		{Name: "d", Pwd: "new-d"},
+	}
+	policies := map[string]string{"a": OnDuplicateSkip, "b": OnDuplicateOverwrite, "c": OnDuplicateRename}
+
+	summary, err := Plan(box, imported, func(s *vault.Secret) string { return policies[s.Name] })
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if summary.Changes() != 3 || summary.Count(OnDuplicateSkip) != 1 {
+		t.Errorf("Unexpected summary: %+v", summary.Actions)
+	}
+	// the box must not be modified by the plan
+	if len(box.Secrets) != 4 || box.Secrets[1].Pwd != "old-b" {
+		t.Fatal("The box has been modified by Plan")
+	}
+
+	oldB := box.Secrets[1]
+	Apply(box, summary)
+	if oldB.Pwd != "" {
+		t.Error("The overwritten secret has not been wiped")
+	}
+	got := make(map[string]string)
+	for _, s := range box.Secrets {
+		got[s.Name] = s.Pwd
+	}
+	want := map[string]string{"a": "old-a", "b": "new-b", "c": "old-c", "c-1": "old-c-1", "c-2": "new-c", "d": "new-d"}
+	if len(got) != len(want) || len(box.Secrets) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("Expected %s=%s, got %s", k, v, got[k])
+		}
+	}
+}
+
+// TestPlan_InvalidPolicy tests that an unknown policy returns an error
+func TestPlan_InvalidPolicy(t *testing.T) {
+	box := &vault.Box{Secrets: []*vault.Secret{{Name: "a"}}}
+
+	_, err := Plan(box, []*vault.Secret{{Name: "a"}}, func(s *vault.Secret) string { return "merge" })
+	if err == nil {
+		t.Error("Expected an error, got nil")
+	}
+}
@@ -0,0 +1,87 @@
+package importer
+
+import (
+	"encoding/xml"
+	"os"
+	"strings"
+
+	"github.com/mas2020-golang/cryptex/pkg/vault"
+)
+
+// KeePass 2.x XML export (File > Export > KeePass XML (2.x))
+type keePassFile struct {
+	Root struct {
+		Groups []keePassGroup `xml:"Group"`
+	} `xml:"Root"`
+}
+
+type keePassGroup struct {
+	Name    string         `xml:"Name"`
+	Entries []keePassEntry `xml:"Entry"`
+	Groups  []keePassGroup `xml:"Group"`
+}
+
+type keePassEntry struct {
+	Strings []struct {
+		Key   string `xml:"Key"`
+		Value string `xml:"Value"`
+	} `xml:"String"`
+	Tags string `xml:"Tags"`
+}
+
+func parseKeePassXML(path string) ([]*vault.Secret, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	kp := keePassFile{}
+	if err := xml.Unmarshal(data, &kp); err != nil {
+		return nil, err
+	}
+
+	secrets := make([]*vault.Secret, 0)
+	for _, g := range kp.Root.Groups {
+		// the first level group is the database itself, it is not a folder
+		secrets = append(secrets, keePassEntries(g, "")...)
+	}
+	return secrets, nil
+}
+
+// keePassEntries returns the secrets of the group and of its sub groups. The
+// folder is the path of the group and it is added as a tag.
+func keePassEntries(g keePassGroup, folder string) []*vault.Secret {
+	secrets := make([]*vault.Secret, 0, len(g.Entries))
+	for _, e := range g.Entries {
+		s := &vault.Secret{}
+		for _, str := range e.Strings {
+			switch str.Key {
+			case "Title":
+				s.Name = str.Value
+			case "UserName":
+				s.Login = str.Value
+			case "Password":
+				s.Pwd = str.Value
+			case "URL":
+				s.Url = str.Value
+			case "Notes":
+				s.Notes = str.Value
+			default:
+				addOther(s, str.Key, str.Value)
+			}
+		}
+		addTag(s, folder)
+		for _, t := range strings.FieldsFunc(e.Tags, func(r rune) bool { return r == ';' || r == ',' }) {
+			addTag(s, t)
+		}
+		secrets = append(secrets, s)
+	}
+
+	for _, sub := range g.Groups {
+		subFolder := sub.Name
+		if len(folder) > 0 {
+			subFolder = folder + "/" + sub.Name
+		}
+		secrets = append(secrets, keePassEntries(sub, subFolder)...)
+	}
+	return secrets
+}
@@ -0,0 +1,107 @@
+package importer
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/mas2020-golang/cryptex/pkg/vault"
+)
+
+// gpgDecrypt decrypts a pass entry, it is a variable to be replaced in tests
+var gpgDecrypt = func(path string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("gpg", "--quiet", "--batch", "--decrypt", path)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("gpg failed on %s: %v %s", path, err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// parsePass reads a pass (https://www.passwordstore.org) store: path is the
+// store folder (e.g. ~/.password-store). Every .gpg file is decrypted using gpg
+// and the sub folders become tags.
+func parsePass(path string) ([]*vault.Secret, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a pass store folder", path)
+	}
+
+	secrets := make([]*vault.Secret, 0)
+	err = filepath.WalkDir(path, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			// skip .git and the other hidden folders
+			if p != path && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(d.Name(), ".gpg") {
+			return nil
+		}
+		data, err := gpgDecrypt(p)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(path, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(strings.TrimSuffix(rel, ".gpg"))
+		s := parsePassEntry(string(data))
+		s.Name = rel
+		if folder := filepath.ToSlash(filepath.Dir(rel)); folder != "." {
+			s.Name = rel[len(folder)+1:]
+			addTag(s, folder)
+		}
+		secrets = append(secrets, s)
+		return nil
+	})
+	return secrets, err
+}
+
+// parsePassEntry maps the pass conventions onto a secret: the first line is the
+// password, the following "key: value" lines are the login, the url or items and
+// everything else goes into the notes
+func parsePassEntry(content string) *vault.Secret {
+	s := &vault.Secret{}
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	s.Pwd = lines[0]
+
+	var notes []string
+	for _, line := range lines[1:] {
+		if strings.HasPrefix(line, "otpauth://") {
+			addOther(s, "totp", line)
+			continue
+		}
+		key, value, found := strings.Cut(line, ":")
+		// a url like https://... is not a key: value pair
+		if !found || strings.Contains(key, " ") || len(key) == 0 ||
+			(len(value) > 0 && value[0] != ' ') {
+			notes = append(notes, line)
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.ToLower(key) {
+		case "login", "user", "username":
+			s.Login = value
+		case "url", "website":
+			s.Url = value
+		default:
+			addOther(s, key, value)
+		}
+	}
+	s.Notes = strings.TrimSpace(strings.Join(notes, "\n"))
+	return s
+}
@@ -0,0 +1,463 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: box.proto
+
+package protos
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Box is the content of a box encoded with protobuf. In the index of a box of
+// the indexed layout the secrets have only their metadata and the keys of the
+// items, the rest is in their Record.
+type Box struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Name        string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Version     string                 `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"`
+	LastUpdated *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=last_updated,json=lastUpdated,proto3" json:"last_updated,omitempty"`
+	Owner       string                 `protobuf:"bytes,5,opt,name=owner,proto3" json:"owner,omitempty"`
+	Secrets     []*Secret              `protobuf:"bytes,4,rep,name=secrets,proto3" json:"secrets,omitempty"`
+	Policy      *Policy                `protobuf:"bytes,6,opt,name=policy,proto3" json:"policy,omitempty"`
+	// last_updated_text is the last update as written when last_updated can't
+	// give it back: not a RFC 3339 time or not in UTC
+	LastUpdatedText string `protobuf:"bytes,7,opt,name=last_updated_text,json=lastUpdatedText,proto3" json:"last_updated_text,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Box) Reset() {
+	*x = Box{}
+	mi := &file_box_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Box) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Box) ProtoMessage() {}
+
+func (x *Box) ProtoReflect() protoreflect.Message {
+	mi := &file_box_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Box.ProtoReflect.Descriptor instead.
+func (*Box) Descriptor() ([]byte, []int) {
+	return file_box_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Box) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Box) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *Box) GetLastUpdated() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastUpdated
+	}
+	return nil
+}
+
+func (x *Box) GetOwner() string {
+	if x != nil {
+		return x.Owner
+	}
+	return ""
+}
+
+func (x *Box) GetSecrets() []*Secret {
+	if x != nil {
+		return x.Secrets
+	}
+	return nil
+}
+
+func (x *Box) GetPolicy() *Policy {
+	if x != nil {
+		return x.Policy
+	}
+	return nil
+}
+
+func (x *Box) GetLastUpdatedText() string {
+	if x != nil {
+		return x.LastUpdatedText
+	}
+	return ""
+}
+
+// Policy restricts what leaves the box
+type Policy struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// not_exportable are the patterns (path.Match) of the names of the secrets
+	// whose values are never given by 'raptor serve' or written by 'raptor export'
+	NotExportable []string `protobuf:"bytes,1,rep,name=not_exportable,json=notExportable,proto3" json:"not_exportable,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Policy) Reset() {
+	*x = Policy{}
+	mi := &file_box_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Policy) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Policy) ProtoMessage() {}
+
+func (x *Policy) ProtoReflect() protoreflect.Message {
+	mi := &file_box_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Policy.ProtoReflect.Descriptor instead.
+func (*Policy) Descriptor() ([]byte, []int) {
+	return file_box_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Policy) GetNotExportable() []string {
+	if x != nil {
+		return x.NotExportable
+	}
+	return nil
+}
+
+type Secret struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Name        string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Id          int32                  `protobuf:"varint,2,opt,name=id,proto3" json:"id,omitempty"` // Unique ID number for this secret
+	Pwd         string                 `protobuf:"bytes,3,opt,name=pwd,proto3" json:"pwd,omitempty"`
+	Url         string                 `protobuf:"bytes,4,opt,name=url,proto3" json:"url,omitempty"`
+	Notes       string                 `protobuf:"bytes,5,opt,name=notes,proto3" json:"notes,omitempty"`
+	Others      map[string]string      `protobuf:"bytes,6,rep,name=others,proto3" json:"others,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Version     string                 `protobuf:"bytes,8,opt,name=version,proto3" json:"version,omitempty"`
+	Login       string                 `protobuf:"bytes,9,opt,name=login,proto3" json:"login,omitempty"`
+	LastUpdated *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=last_updated,json=lastUpdated,proto3" json:"last_updated,omitempty"`
+	Tags        []string               `protobuf:"bytes,10,rep,name=tags,proto3" json:"tags,omitempty"`
+	// items are the keys of others in the index of the indexed layout
+	Items []string `protobuf:"bytes,11,rep,name=items,proto3" json:"items,omitempty"`
+	// last_updated_text is the last update as written, see Box
+	LastUpdatedText string `protobuf:"bytes,12,opt,name=last_updated_text,json=lastUpdatedText,proto3" json:"last_updated_text,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Secret) Reset() {
+	*x = Secret{}
+	mi := &file_box_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Secret) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Secret) ProtoMessage() {}
+
+func (x *Secret) ProtoReflect() protoreflect.Message {
+	mi := &file_box_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Secret.ProtoReflect.Descriptor instead.
+func (*Secret) Descriptor() ([]byte, []int) {
+	return file_box_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Secret) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Secret) GetId() int32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Secret) GetPwd() string {
+	if x != nil {
+		return x.Pwd
+	}
+	return ""
+}
+
+func (x *Secret) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *Secret) GetNotes() string {
+	if x != nil {
+		return x.Notes
+	}
+	return ""
+}
+
+func (x *Secret) GetOthers() map[string]string {
+	if x != nil {
+		return x.Others
+	}
+	return nil
+}
+
+func (x *Secret) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *Secret) GetLogin() string {
+	if x != nil {
+		return x.Login
+	}
+	return ""
+}
+
+func (x *Secret) GetLastUpdated() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastUpdated
+	}
+	return nil
+}
+
+func (x *Secret) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *Secret) GetItems() []string {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+func (x *Secret) GetLastUpdatedText() string {
+	if x != nil {
+		return x.LastUpdatedText
+	}
+	return ""
+}
+
+// Record is the sensitive part of a secret, sealed separately in a box of the
+// indexed layout
+type Record struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Pwd           string                 `protobuf:"bytes,1,opt,name=pwd,proto3" json:"pwd,omitempty"`
+	Notes         string                 `protobuf:"bytes,2,opt,name=notes,proto3" json:"notes,omitempty"`
+	Others        map[string]string      `protobuf:"bytes,3,rep,name=others,proto3" json:"others,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Record) Reset() {
+	*x = Record{}
+	mi := &file_box_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Record) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Record) ProtoMessage() {}
+
+func (x *Record) ProtoReflect() protoreflect.Message {
+	mi := &file_box_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Record.ProtoReflect.Descriptor instead.
+func (*Record) Descriptor() ([]byte, []int) {
+	return file_box_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Record) GetPwd() string {
+	if x != nil {
+		return x.Pwd
+	}
+	return ""
+}
+
+func (x *Record) GetNotes() string {
+	if x != nil {
+		return x.Notes
+	}
+	return ""
+}
+
+func (x *Record) GetOthers() map[string]string {
+	if x != nil {
+		return x.Others
+	}
+	return nil
+}
+
+var File_box_proto protoreflect.FileDescriptor
+
+const file_box_proto_rawDesc = "" +
+	"\n" +
+	"\tbox.proto\x12\x06raptor\x1a\x1fgoogle/protobuf/timestamp.proto\"\x86\x02\n" +
+	"\x03Box\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x18\n" +
+	"\aversion\x18\x02 \x01(\tR\aversion\x12=\n" +
+	"\flast_updated\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\vlastUpdated\x12\x14\n" +
+	"\x05owner\x18\x05 \x01(\tR\x05owner\x12(\n" +
+	"\asecrets\x18\x04 \x03(\v2\x0e.raptor.SecretR\asecrets\x12&\n" +
+	"\x06policy\x18\x06 \x01(\v2\x0e.raptor.PolicyR\x06policy\x12*\n" +
+	"\x11last_updated_text\x18\a \x01(\tR\x0flastUpdatedText\"/\n" +
+	"\x06Policy\x12%\n" +
+	"\x0enot_exportable\x18\x01 \x03(\tR\rnotExportable\"\x9a\x03\n" +
+	"\x06Secret\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\x05R\x02id\x12\x10\n" +
+	"\x03pwd\x18\x03 \x01(\tR\x03pwd\x12\x10\n" +
+	"\x03url\x18\x04 \x01(\tR\x03url\x12\x14\n" +
+	"\x05notes\x18\x05 \x01(\tR\x05notes\x122\n" +
+	"\x06others\x18\x06 \x03(\v2\x1a.raptor.Secret.OthersEntryR\x06others\x12\x18\n" +
+	"\aversion\x18\b \x01(\tR\aversion\x12\x14\n" +
+	"\x05login\x18\t \x01(\tR\x05login\x12=\n" +
+	"\flast_updated\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\vlastUpdated\x12\x12\n" +
+	"\x04tags\x18\n" +
+	" \x03(\tR\x04tags\x12\x14\n" +
+	"\x05items\x18\v \x03(\tR\x05items\x12*\n" +
+	"\x11last_updated_text\x18\f \x01(\tR\x0flastUpdatedText\x1a9\n" +
+	"\vOthersEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x9f\x01\n" +
+	"\x06Record\x12\x10\n" +
+	"\x03pwd\x18\x01 \x01(\tR\x03pwd\x12\x14\n" +
+	"\x05notes\x18\x02 \x01(\tR\x05notes\x122\n" +
+	"\x06others\x18\x03 \x03(\v2\x1a.raptor.Record.OthersEntryR\x06others\x1a9\n" +
+	"\vOthersEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01B3Z1github.com/mas2020-golang/cryptex/packages/protosb\x06proto3"
+
+var (
+	file_box_proto_rawDescOnce sync.Once
+	file_box_proto_rawDescData []byte
+)
+
+func file_box_proto_rawDescGZIP() []byte {
+	file_box_proto_rawDescOnce.Do(func() {
+		file_box_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_box_proto_rawDesc), len(file_box_proto_rawDesc)))
+	})
+	return file_box_proto_rawDescData
+}
+
+var file_box_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_box_proto_goTypes = []any{
+	(*Box)(nil),                   // 0: raptor.Box
+	(*Policy)(nil),                // 1: raptor.Policy
+	(*Secret)(nil),                // 2: raptor.Secret
+	(*Record)(nil),                // 3: raptor.Record
+	nil,                           // 4: raptor.Secret.OthersEntry
+	nil,                           // 5: raptor.Record.OthersEntry
+	(*timestamppb.Timestamp)(nil), // 6: google.protobuf.Timestamp
+}
+var file_box_proto_depIdxs = []int32{
+	6, // 0: raptor.Box.last_updated:type_name -> google.protobuf.Timestamp
+	2, // 1: raptor.Box.secrets:type_name -> raptor.Secret
+	1, // 2: raptor.Box.policy:type_name -> raptor.Policy
+	4, // 3: raptor.Secret.others:type_name -> raptor.Secret.OthersEntry
+	6, // 4: raptor.Secret.last_updated:type_name -> google.protobuf.Timestamp
+	5, // 5: raptor.Record.others:type_name -> raptor.Record.OthersEntry
+	6, // [6:6] is the sub-list for method output_type
+	6, // [6:6] is the sub-list for method input_type
+	6, // [6:6] is the sub-list for extension type_name
+	6, // [6:6] is the sub-list for extension extendee
+	0, // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_box_proto_init() }
+func file_box_proto_init() {
+	if File_box_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_box_proto_rawDesc), len(file_box_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_box_proto_goTypes,
+		DependencyIndexes: file_box_proto_depIdxs,
+		MessageInfos:      file_box_proto_msgTypes,
+	}.Build()
+	File_box_proto = out.File
+	file_box_proto_goTypes = nil
+	file_box_proto_depIdxs = nil
+}
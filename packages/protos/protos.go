@@ -0,0 +1,6 @@
+// Package protos holds the Go types generated from protos/box.proto, the
+// protobuf encoding of the boxes, and protos/api.proto, the API served by
+// 'raptor serve' (the handlers and clients are in protosconnect)
+package protos
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --connect-go_out=. --connect-go_opt=paths=source_relative -I ../../protos box.proto api.proto
@@ -0,0 +1,230 @@
+// Code generated by protoc-gen-connect-go. DO NOT EDIT.
+//
+// Source: api.proto
+
+package protosconnect
+
+import (
+	connect "connectrpc.com/connect"
+	context "context"
+	errors "errors"
+	protos "github.com/mas2020-golang/cryptex/packages/protos"
+	http "net/http"
+	strings "strings"
+)
+
+// This is a compile-time assertion to ensure that this generated file and the connect package are
+// compatible. If you get a compiler error that this constant is not defined, this code was
+// generated with a version of connect newer than the one compiled into your binary. You can fix the
+// problem by either regenerating this code with an older version of connect or updating the connect
+// version compiled into your binary.
+const _ = connect.IsAtLeastVersion1_13_0
+
+const (
+	// SecretsName is the fully-qualified name of the Secrets service.
+	SecretsName = "raptor.Secrets"
+)
+
+// These constants are the fully-qualified names of the RPCs defined in this package. They're
+// exposed at runtime as Spec.Procedure and as the final two segments of the HTTP route.
+//
+// Note that these are different from the fully-qualified method names used by
+// google.golang.org/protobuf/reflect/protoreflect. To convert from these constants to
+// reflection-formatted method names, remove the leading slash and convert the remaining slash to a
+// period.
+const (
+	// SecretsListProcedure is the fully-qualified name of the Secrets's List RPC.
+	SecretsListProcedure = "/raptor.Secrets/List"
+	// SecretsGetProcedure is the fully-qualified name of the Secrets's Get RPC.
+	SecretsGetProcedure = "/raptor.Secrets/Get"
+	// SecretsPutProcedure is the fully-qualified name of the Secrets's Put RPC.
+	SecretsPutProcedure = "/raptor.Secrets/Put"
+	// SecretsDeleteProcedure is the fully-qualified name of the Secrets's Delete RPC.
+	SecretsDeleteProcedure = "/raptor.Secrets/Delete"
+	// SecretsSearchProcedure is the fully-qualified name of the Secrets's Search RPC.
+	SecretsSearchProcedure = "/raptor.Secrets/Search"
+)
+
+// SecretsClient is a client for the raptor.Secrets service.
+type SecretsClient interface {
+	// List returns the secrets of the box without their values
+	List(context.Context, *connect.Request[protos.ListRequest]) (*connect.Response[protos.ListResponse], error)
+	// Get returns the password or the value of an item of a secret
+	Get(context.Context, *connect.Request[protos.GetRequest]) (*connect.Response[protos.GetResponse], error)
+	// Put creates or replaces a secret and saves the box
+	Put(context.Context, *connect.Request[protos.PutRequest]) (*connect.Response[protos.PutResponse], error)
+	// Delete removes a secret and saves the box
+	Delete(context.Context, *connect.Request[protos.DeleteRequest]) (*connect.Response[protos.DeleteResponse], error)
+	// Search returns the secrets matching the query, without their values
+	Search(context.Context, *connect.Request[protos.SearchRequest]) (*connect.Response[protos.SearchResponse], error)
+}
+
+// NewSecretsClient constructs a client for the raptor.Secrets service. By default, it uses the
+// Connect protocol with the binary Protobuf Codec, asks for gzipped responses, and sends
+// uncompressed requests. To use the gRPC or gRPC-Web protocols, supply the connect.WithGRPC() or
+// connect.WithGRPCWeb() options.
+//
+// The URL supplied here should be the base URL for the Connect or gRPC server (for example,
+// http://api.acme.com or https://acme.com/grpc).
+func NewSecretsClient(httpClient connect.HTTPClient, baseURL string, opts ...connect.ClientOption) SecretsClient {
+	baseURL = strings.TrimRight(baseURL, "/")
+	secretsMethods := protos.File_api_proto.Services().ByName("Secrets").Methods()
+	return &secretsClient{
+		list: connect.NewClient[protos.ListRequest, protos.ListResponse](
+			httpClient,
+			baseURL+SecretsListProcedure,
+			connect.WithSchema(secretsMethods.ByName("List")),
+			connect.WithClientOptions(opts...),
+		),
+		get: connect.NewClient[protos.GetRequest, protos.GetResponse](
+			httpClient,
+			baseURL+SecretsGetProcedure,
+			connect.WithSchema(secretsMethods.ByName("Get")),
+			connect.WithClientOptions(opts...),
+		),
+		put: connect.NewClient[protos.PutRequest, protos.PutResponse](
+			httpClient,
+			baseURL+SecretsPutProcedure,
+			connect.WithSchema(secretsMethods.ByName("Put")),
+			connect.WithClientOptions(opts...),
+		),
+		delete: connect.NewClient[protos.DeleteRequest, protos.DeleteResponse](
+			httpClient,
+			baseURL+SecretsDeleteProcedure,
+			connect.WithSchema(secretsMethods.ByName("Delete")),
+			connect.WithClientOptions(opts...),
+		),
+		search: connect.NewClient[protos.SearchRequest, protos.SearchResponse](
+			httpClient,
+			baseURL+SecretsSearchProcedure,
+			connect.WithSchema(secretsMethods.ByName("Search")),
+			connect.WithClientOptions(opts...),
+		),
+	}
+}
+
+// secretsClient implements SecretsClient.
+type secretsClient struct {
+	list   *connect.Client[protos.ListRequest, protos.ListResponse]
+	get    *connect.Client[protos.GetRequest, protos.GetResponse]
+	put    *connect.Client[protos.PutRequest, protos.PutResponse]
+	delete *connect.Client[protos.DeleteRequest, protos.DeleteResponse]
+	search *connect.Client[protos.SearchRequest, protos.SearchResponse]
+}
+
+// List calls raptor.Secrets.List.
+func (c *secretsClient) List(ctx context.Context, req *connect.Request[protos.ListRequest]) (*connect.Response[protos.ListResponse], error) {
+	return c.list.CallUnary(ctx, req)
+}
+
+// Get calls raptor.Secrets.Get.
+func (c *secretsClient) Get(ctx context.Context, req *connect.Request[protos.GetRequest]) (*connect.Response[protos.GetResponse], error) {
+	return c.get.CallUnary(ctx, req)
+}
+
+// Put calls raptor.Secrets.Put.
+func (c *secretsClient) Put(ctx context.Context, req *connect.Request[protos.PutRequest]) (*connect.Response[protos.PutResponse], error) {
+	return c.put.CallUnary(ctx, req)
+}
+
+// Delete calls raptor.Secrets.Delete.
+func (c *secretsClient) Delete(ctx context.Context, req *connect.Request[protos.DeleteRequest]) (*connect.Response[protos.DeleteResponse], error) {
+	return c.delete.CallUnary(ctx, req)
+}
+
+// Search calls raptor.Secrets.Search.
+func (c *secretsClient) Search(ctx context.Context, req *connect.Request[protos.SearchRequest]) (*connect.Response[protos.SearchResponse], error) {
+	return c.search.CallUnary(ctx, req)
+}
+
+// SecretsHandler is an implementation of the raptor.Secrets service.
+type SecretsHandler interface {
+	// List returns the secrets of the box without their values
+	List(context.Context, *connect.Request[protos.ListRequest]) (*connect.Response[protos.ListResponse], error)
+	// Get returns the password or the value of an item of a secret
+	Get(context.Context, *connect.Request[protos.GetRequest]) (*connect.Response[protos.GetResponse], error)
+	// Put creates or replaces a secret and saves the box
+	Put(context.Context, *connect.Request[protos.PutRequest]) (*connect.Response[protos.PutResponse], error)
+	// Delete removes a secret and saves the box
+	Delete(context.Context, *connect.Request[protos.DeleteRequest]) (*connect.Response[protos.DeleteResponse], error)
+	// Search returns the secrets matching the query, without their values
+	Search(context.Context, *connect.Request[protos.SearchRequest]) (*connect.Response[protos.SearchResponse], error)
+}
+
+// NewSecretsHandler builds an HTTP handler from the service implementation. It returns the path on
+// which to mount the handler and the handler itself.
+//
+// By default, handlers support the Connect, gRPC, and gRPC-Web protocols with the binary Protobuf
+// and JSON codecs. They also support gzip compression.
+func NewSecretsHandler(svc SecretsHandler, opts ...connect.HandlerOption) (string, http.Handler) {
+	secretsMethods := protos.File_api_proto.Services().ByName("Secrets").Methods()
+	secretsListHandler := connect.NewUnaryHandler(
+		SecretsListProcedure,
+		svc.List,
+		connect.WithSchema(secretsMethods.ByName("List")),
+		connect.WithHandlerOptions(opts...),
+	)
+	secretsGetHandler := connect.NewUnaryHandler(
+		SecretsGetProcedure,
+		svc.Get,
+		connect.WithSchema(secretsMethods.ByName("Get")),
+		connect.WithHandlerOptions(opts...),
+	)
+	secretsPutHandler := connect.NewUnaryHandler(
+		SecretsPutProcedure,
+		svc.Put,
+		connect.WithSchema(secretsMethods.ByName("Put")),
+		connect.WithHandlerOptions(opts...),
+	)
+	secretsDeleteHandler := connect.NewUnaryHandler(
+		SecretsDeleteProcedure,
+		svc.Delete,
+		connect.WithSchema(secretsMethods.ByName("Delete")),
+		connect.WithHandlerOptions(opts...),
+	)
+	secretsSearchHandler := connect.NewUnaryHandler(
+		SecretsSearchProcedure,
+		svc.Search,
+		connect.WithSchema(secretsMethods.ByName("Search")),
+		connect.WithHandlerOptions(opts...),
+	)
+	return "/raptor.Secrets/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case SecretsListProcedure:
+			secretsListHandler.ServeHTTP(w, r)
+		case SecretsGetProcedure:
+			secretsGetHandler.ServeHTTP(w, r)
+		case SecretsPutProcedure:
+			secretsPutHandler.ServeHTTP(w, r)
+		case SecretsDeleteProcedure:
+			secretsDeleteHandler.ServeHTTP(w, r)
+		case SecretsSearchProcedure:
+			secretsSearchHandler.ServeHTTP(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// UnimplementedSecretsHandler returns CodeUnimplemented from all methods.
+type UnimplementedSecretsHandler struct{}
+
+func (UnimplementedSecretsHandler) List(context.Context, *connect.Request[protos.ListRequest]) (*connect.Response[protos.ListResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("raptor.Secrets.List is not implemented"))
+}
+
+func (UnimplementedSecretsHandler) Get(context.Context, *connect.Request[protos.GetRequest]) (*connect.Response[protos.GetResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("raptor.Secrets.Get is not implemented"))
+}
+
+func (UnimplementedSecretsHandler) Put(context.Context, *connect.Request[protos.PutRequest]) (*connect.Response[protos.PutResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("raptor.Secrets.Put is not implemented"))
+}
+
+func (UnimplementedSecretsHandler) Delete(context.Context, *connect.Request[protos.DeleteRequest]) (*connect.Response[protos.DeleteResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("raptor.Secrets.Delete is not implemented"))
+}
+
+func (UnimplementedSecretsHandler) Search(context.Context, *connect.Request[protos.SearchRequest]) (*connect.Response[protos.SearchResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("raptor.Secrets.Search is not implemented"))
+}
@@ -0,0 +1,185 @@
+package security
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/mas2020-golang/goutils/output"
+)
+
+// The files encrypted for public keys use the age v1 format
+// (https://age-encryption.org/v1), so that they can be decrypted with any age
+// implementation. The original name and metadata are not stored: the format
+// has no room for them.
+const (
+	AgeExt = ".age"
+	// AgeIdentityPrefix is the prefix of the age secret keys
+	AgeIdentityPrefix = "AGE-SECRET-KEY-1"
+)
+
+// GenerateIdentity returns a new X25519 secret key and its public key
+func GenerateIdentity() (identity, recipient string, err error) {
+	id, err := age.GenerateX25519Identity()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate the identity: %v", err)
+	}
+	return id.String(), id.Recipient().String(), nil
+}
+
+// IsAgeFile returns true if the path has the age extension
+func IsAgeFile(path string) bool {
+	return strings.HasSuffix(path, AgeExt)
+}
+
+func parseRecipients(keys []string) ([]age.Recipient, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no recipient given")
+	}
+	recipients := make([]age.Recipient, 0, len(keys))
+	for _, k := range keys {
+		r, err := age.ParseX25519Recipient(strings.TrimSpace(k))
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipient %q: %v", k, err)
+		}
+		recipients = append(recipients, r)
+	}
+	return recipients, nil
+}
+
+// parseIdentities returns the identities for the secret keys, the passphrase
+// (when given) decrypts the age files encrypted with a passphrase
+func parseIdentities(keys []string, passphrase string) ([]age.Identity, error) {
+	identities := make([]age.Identity, 0, len(keys)+1)
+	for _, k := range keys {
+		id, err := age.ParseX25519Identity(strings.TrimSpace(k))
+		if err != nil {
+			return nil, fmt.Errorf("invalid identity: %v", err)
+		}
+		identities = append(identities, id)
+	}
+	if len(passphrase) > 0 {
+		id, err := age.NewScryptIdentity(passphrase)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, id)
+	}
+	if len(identities) == 0 {
+		return nil, fmt.Errorf("no identity available to decrypt the age file")
+	}
+	return identities, nil
+}
+
+// EncryptAge reads the plaintext from r and writes it into w encrypted for the
+// recipients (age public keys)
+func EncryptAge(r io.Reader, w io.Writer, recipients []string) error {
+	rs, err := parseRecipients(recipients)
+	if err != nil {
+		return err
+	}
+	aw, err := age.Encrypt(w, rs...)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(aw, r); err != nil {
+		return err
+	}
+	return aw.Close()
+}
+
+// DecryptAge reads the age file from r and writes the plaintext into w using
+// the first identity (age secret key) matching a recipient of the file
+func DecryptAge(r io.Reader, w io.Writer, identities []string, passphrase string) error {
+	ids, err := parseIdentities(identities, passphrase)
+	if err != nil {
+		return err
+	}
+	ar, err := age.Decrypt(r, ids...)
+	if err != nil {
+		var nomatch *age.NoIdentityMatchError
+		if errors.As(err, &nomatch) {
+			return fmt.Errorf("failed to decrypt data: %w", ErrAuthentication)
+		}
+		return fmt.Errorf("failed to decrypt data: %v", err)
+	}
+	if _, err := io.Copy(w, ar); err != nil {
+		return fmt.Errorf("failed to decrypt data: %v", err)
+	}
+	return nil
+}
+
+// encryptAgeFile writes path encrypted for opts.Recipients into <path>.age
+func encryptAgeFile(path string, opts *Options) error {
+	if IsAgeFile(path) {
+		output.Warning("", fmt.Sprintf("file %s skipped as it is already a .age file", path))
+		return ErrInvalidFile
+	}
+	in, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %v", err)
+	}
+	defer in.Close()
+
+	name, err := opts.rename(filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	dst, err := opts.destination(path, name+AgeExt)
+	if err != nil {
+		return err
+	}
+	err = WriteFile(dst, opts.force(), func(out io.Writer) error {
+		return EncryptAge(in, out, opts.Recipients)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encrypt %s: %w", path, err)
+	}
+	slog.Debug(fmt.Sprintf("the file %s has been encrypted", path), "output", dst, "recipients", len(opts.Recipients))
+	in.Close()
+
+	if opts.keep() {
+		return nil
+	}
+	return opts.deleteFile(path)
+}
+
+// decryptAgeFile writes the plaintext of the age file without its extension
+func decryptAgeFile(path, passphrase string, opts *Options) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read encrypted file: %v", err)
+	}
+	defer in.Close()
+
+	name, err := opts.rename(strings.TrimSuffix(filepath.Base(path), AgeExt))
+	if err != nil {
+		return err
+	}
+	dst, err := opts.destination(path, name)
+	if err != nil {
+		return err
+	}
+	var identities []string
+	if opts != nil {
+		identities = opts.Identities
+	}
+	err = WriteFile(dst, opts.force(), func(out io.Writer) error {
+		return DecryptAge(in, out, identities, passphrase)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to decrypt %s: %w", path, err)
+	}
+	slog.Debug(fmt.Sprintf("the file %s has been decrypted", path), "output", dst)
+	in.Close()
+
+	if opts.keep() {
+		return nil
+	}
+	return opts.deleteFile(path)
+}
@@ -0,0 +1,66 @@
+package security
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+)
+
+// TestAgeFile_RoundTrip tests the encryption for a recipient and the
+// decryption with its identity
+func TestAgeFile_RoundTrip(t *testing.T) {
+	identity, recipient, err := GenerateIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, _ := GenerateIdentity()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "report.txt")
+	writeTree(t, dir, map[string]string{"report.txt": "quarterly"})
+
+	if err := EncryptFile(path, "", &Options{Recipients: []string{recipient}}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if _, err := os.Stat(path + AgeExt); err != nil {
+		t.Fatalf("Expected the .age file: %v", err)
+	}
+
+	if err := DecryptFile(path+AgeExt, "", &Options{Identities: []string{other}, Keep: true}); err == nil {
+		t.Fatal("Expected an error with another identity, got nil")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Expected no file written with another identity")
+	}
+	if err := DecryptFile(path+AgeExt, "", &Options{Identities: []string{other, identity}}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "quarterly" {
+		t.Errorf("Expected 'quarterly', got %q", data)
+	}
+}
+
+// TestDecryptAge_Passphrase tests the age files encrypted with a passphrase
+func TestDecryptAge_Passphrase(t *testing.T) {
+	r, err := age.NewScryptRecipient("passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.SetWorkFactor(10)
+	var enc, dec bytes.Buffer
+	w, err := age.Encrypt(&enc, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("from age"))
+	w.Close()
+
+	if err := DecryptAge(&enc, &dec, nil, "passphrase"); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if dec.String() != "from age" {
+		t.Errorf("Expected 'from age', got %q", dec.String())
+	}
+}
@@ -0,0 +1,412 @@
+package security
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mas2020-golang/goutils/output"
+)
+
+// An archive is a folder streamed through tar (optionally gzip compressed) into
+// a single encrypted file: names, structure, sizes and number of the files are
+// not visible anymore.
+const (
+	ArchiveExt = ".raptor"
+
+	archiveTar   = "tar"
+	archiveTarGz = "tar+gzip"
+)
+
+// Symlink policies applied when an archive is extracted
+const (
+	// SymlinkSafe restores the links pointing inside the tree and skips the others
+	SymlinkSafe = "safe"
+	// SymlinkSkip never restores a link
+	SymlinkSkip = "skip"
+	// SymlinkError fails on a link pointing outside the tree
+	SymlinkError = "error"
+)
+
+// ErrUnsafePath is returned when an archive entry would be written outside the
+// destination folder
+var ErrUnsafePath = errors.New("unsafe path in the archive")
+
+func (o *Options) symlinks() string {
+	if o == nil || len(o.Symlinks) == 0 {
+		return SymlinkSafe
+	}
+	return o.Symlinks
+}
+
+// IsArchive returns true if the path has the archive extension
+func IsArchive(path string) bool {
+	return strings.HasSuffix(path, ArchiveExt)
+}
+
+// EncryptArchive writes the dirPath tree into a single encrypted archive named
+// after the folder and returns its path. The tree is wiped unless opts.Keep.
+func EncryptArchive(dirPath, passphrase string, opts *Options) (string, error) {
+	dirPath = filepath.Clean(dirPath)
+	stat, err := os.Stat(dirPath)
+	if err != nil {
+		return "", err
+	}
+	if !stat.IsDir() {
+		return "", fmt.Errorf("%s is not a folder", dirPath)
+	}
+	archivePath, err := opts.destination(dirPath, filepath.Base(dirPath)+ArchiveExt)
+	if err != nil {
+		return "", err
+	}
+	absArchive, _ := filepath.Abs(archivePath)
+	f, err := newFilter(dirPath, opts)
+	if err != nil {
+		return "", err
+	}
+
+	info := NewFileInfo(stat)
+	info.Archive = archiveTar
+	if opts != nil && opts.Compress {
+		info.Archive = archiveTarGz
+	}
+
+	var files, dirs []string
+	err = WriteFile(archivePath, opts.force(), func(out io.Writer) error {
+		ew, err := NewEncryptWriter(out, passphrase, info)
+		if err != nil {
+			return err
+		}
+		var w io.Writer = ew
+		var gz *gzip.Writer
+		if info.Archive == archiveTarGz {
+			gz = gzip.NewWriter(ew)
+			w = gz
+		}
+		tw := tar.NewWriter(w)
+		if files, dirs, err = writeTar(tw, dirPath, absArchive, f); err != nil {
+			return err
+		}
+		if err := tw.Close(); err != nil {
+			return err
+		}
+		if gz != nil {
+			if err := gz.Close(); err != nil {
+				return err
+			}
+		}
+		return ew.Close()
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt %s: %w", dirPath, err)
+	}
+	slog.Debug("security.EncryptArchive()", "dirPath", dirPath, "archive", archivePath, "files", len(files))
+
+	if opts.keep() {
+		return archivePath, nil
+	}
+	for _, f := range files {
+		if err := opts.deleteFile(f); err != nil {
+			return archivePath, err
+		}
+	}
+	// the folders keeping excluded files are not removed
+	for i := len(dirs) - 1; i >= 0; i-- {
+		os.Remove(dirs[i])
+	}
+	if err := os.Remove(dirPath); err != nil && !os.IsNotExist(err) {
+		output.Warning("", fmt.Sprintf("%s kept with the files not archived", dirPath))
+	}
+	return archivePath, nil
+}
+
+// writeTar adds the dirPath tree selected by the filter to the tar writer and
+// returns the files (regular files and links) and the folders added. The
+// symlinks are stored as links, the special files are skipped.
+func writeTar(tw *tar.Writer, dirPath, skip string, f *filter) (files, dirs []string, err error) {
+	err = filepath.Walk(dirPath, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if abs, _ := filepath.Abs(p); abs == skip {
+			return nil
+		}
+		rel, err := filepath.Rel(dirPath, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		if info.IsDir() && f.skipDir(filepath.ToSlash(rel)) {
+			return filepath.SkipDir
+		}
+		if info.Mode().IsRegular() && f.skipFile(filepath.ToSlash(rel), info.Size()) {
+			return nil
+		}
+
+		link := ""
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		case !info.IsDir() && !info.Mode().IsRegular():
+			output.Warning("", fmt.Sprintf("%s skipped as it is not a regular file", p))
+			return nil
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			dirs = append(dirs, p)
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			files = append(files, p)
+			return nil
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if _, err := io.Copy(tw, f); err != nil {
+			return fmt.Errorf("failed to archive %s: %v", p, err)
+		}
+		files = append(files, p)
+		return nil
+	})
+	return files, dirs, err
+}
+
+// openArchive decrypts the archive and returns the tar reader of its content
+func openArchive(r io.Reader, passphrase string) (*tar.Reader, *FileInfo, error) {
+	dr, err := NewDecryptReader(r, passphrase)
+	if err != nil {
+		return nil, nil, err
+	}
+	if dr.Info == nil || len(dr.Info.Archive) == 0 {
+		return nil, nil, fmt.Errorf("the file is not a raptor archive")
+	}
+	var tr io.Reader = dr
+	switch dr.Info.Archive {
+	case archiveTar:
+	case archiveTarGz:
+		if tr, err = gzip.NewReader(dr); err != nil {
+			return nil, nil, err
+		}
+	default:
+		return nil, nil, fmt.Errorf("unknown archive format %q", dr.Info.Archive)
+	}
+	return tar.NewReader(tr), dr.Info, nil
+}
+
+// ListArchive calls fn for every entry of the archive without writing anything
+// on the disk
+func ListArchive(archivePath, passphrase string, fn func(*tar.Header)) error {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	tr, _, err := openArchive(f, passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt %s: %w", archivePath, err)
+	}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", archivePath, err)
+		}
+		fn(hdr)
+	}
+}
+
+// DecryptArchive restores the tree of the archive in a folder with the original
+// name and returns its path. The archive is wiped unless opts.Keep.
+func DecryptArchive(archivePath, passphrase string, opts *Options) (string, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	tr, info, err := openArchive(f, passphrase)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt %s: %w", archivePath, err)
+	}
+
+	name := strings.TrimSuffix(filepath.Base(archivePath), ArchiveExt)
+	if isPlainName(info.Name) {
+		name = info.Name
+	}
+	dirPath, err := opts.destination(archivePath, name)
+	if err != nil {
+		return "", err
+	}
+	if err := os.Mkdir(dirPath, 0700); err != nil {
+		if os.IsExist(err) {
+			return "", fmt.Errorf("%w: %s", ErrExists, dirPath)
+		}
+		return "", err
+	}
+	if err := extractTar(tr, dirPath, opts.symlinks()); err != nil {
+		os.RemoveAll(dirPath)
+		return "", fmt.Errorf("failed to decrypt %s: %w", archivePath, err)
+	}
+	if err := info.restore(dirPath); err != nil {
+		output.Warning("", fmt.Sprintf("failed to restore the metadata of %s: %v", dirPath, err))
+	}
+	f.Close()
+
+	if opts.keep() {
+		return dirPath, nil
+	}
+	return dirPath, opts.deleteFile(archivePath)
+}
+
+// extractTar writes the entries into dirPath refusing any path outside of it
+func extractTar(tr *tar.Reader, dirPath, symlinks string) error {
+	type dirMeta struct {
+		path string
+		info *FileInfo
+	}
+	// the folder modes are applied at the end, a read-only folder would
+	// prevent the creation of its content
+	var dirs []dirMeta
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		name, err := localPath(hdr.Name)
+		if err != nil {
+			return err
+		}
+		if err := noLinkIn(dirPath, name); err != nil {
+			return err
+		}
+		target := filepath.Join(dirPath, name)
+		meta := &FileInfo{Mode: hdr.FileInfo().Mode().Perm(), ModTime: hdr.ModTime.UnixNano(),
+			Uid: hdr.Uid, Gid: hdr.Gid, HasOwner: true}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0700); err != nil {
+				return err
+			}
+			dirs = append(dirs, dirMeta{target, meta})
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+				return err
+			}
+			err := WriteFile(target, false, func(out io.Writer) error {
+				_, err := io.Copy(out, tr)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			if err := meta.restore(target); err != nil {
+				output.Warning("", fmt.Sprintf("failed to restore the metadata of %s: %v", target, err))
+			}
+		case tar.TypeSymlink:
+			if symlinks == SymlinkSkip {
+				output.Warning("", fmt.Sprintf("symlink %s skipped", hdr.Name))
+				continue
+			}
+			if !isLocalLink(name, hdr.Linkname) {
+				if symlinks == SymlinkError {
+					return fmt.Errorf("%w: the symlink %s points to %s", ErrUnsafePath, hdr.Name, hdr.Linkname)
+				}
+				output.Warning("", fmt.Sprintf("symlink %s skipped as it points outside the folder (%s)", hdr.Name, hdr.Linkname))
+				continue
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+				return err
+			}
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+		default:
+			output.Warning("", fmt.Sprintf("%s skipped as it is not a regular file", hdr.Name))
+		}
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := dirs[i].info.restore(dirs[i].path); err != nil {
+			output.Warning("", fmt.Sprintf("failed to restore the metadata of %s: %v", dirs[i].path, err))
+		}
+	}
+	return nil
+}
+
+// localPath converts the archive entry name into a relative path that can't
+// escape the destination folder
+func localPath(name string) (string, error) {
+	p := filepath.FromSlash(path.Clean(strings.TrimSuffix(name, "/")))
+	if !filepath.IsLocal(p) {
+		return "", fmt.Errorf("%w: %s", ErrUnsafePath, name)
+	}
+	return p, nil
+}
+
+// noLinkIn returns ErrUnsafePath if a component of the name, the last one
+// included, is a symlink already extracted in dirPath: a chain of local links
+// (a -> ., a/b -> ..) would otherwise write outside of the folder
+func noLinkIn(dirPath, name string) error {
+	p := dirPath
+	for _, c := range strings.Split(name, string(filepath.Separator)) {
+		p = filepath.Join(p, c)
+		fi, err := os.Lstat(p)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("%w: %s goes through the symlink %s", ErrUnsafePath, name, strings.TrimPrefix(p, dirPath+string(filepath.Separator)))
+		}
+	}
+	return nil
+}
+
+// isLocalLink returns true if the link in the name entry resolves inside the
+// destination folder
+func isLocalLink(name, link string) bool {
+	if filepath.IsAbs(link) || strings.HasPrefix(link, "/") {
+		return false
+	}
+	return filepath.IsLocal(filepath.Join(filepath.Dir(name), filepath.FromSlash(link)))
+}
+
+// ArchiveEntry formats a tar header like ls -l does
+func ArchiveEntry(hdr *tar.Header) string {
+	name := hdr.Name
+	if hdr.Typeflag == tar.TypeSymlink {
+		name += " -> " + hdr.Linkname
+	}
+	return fmt.Sprintf("%s %10d %s %s", hdr.FileInfo().Mode(), hdr.Size, hdr.ModTime.Format(time.DateTime), name)
+}
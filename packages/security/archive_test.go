@@ -0,0 +1,163 @@
+package security
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestArchive_RoundTrip tests that the tree and the links are restored
+func TestArchive_RoundTrip(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		root := t.TempDir()
+		src := filepath.Join(root, "docs")
+		files := map[string]string{"a.txt": "a", "sub/dir/b.txt": "b"}
+		writeTree(t, src, files)
+		if err := os.Symlink("sub/dir/b.txt", filepath.Join(src, "link")); err != nil {
+			t.Fatal(err)
+		}
+
+		archive, err := EncryptArchive(src, "passphrase", &Options{Compress: compress})
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if archive != src+ArchiveExt {
+			t.Errorf("Expected the archive %s, got %s", src+ArchiveExt, archive)
+		}
+		if _, err := os.Stat(src); !os.IsNotExist(err) {
+			t.Fatal("Expected the folder to be wiped")
+		}
+
+		var names []string
+		if err := ListArchive(archive, "passphrase", func(h *tar.Header) { names = append(names, h.Name) }); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if len(names) != 5 {
+			t.Errorf("Expected 5 entries, got %v", names)
+		}
+
+		dir, err := DecryptArchive(archive, "passphrase", nil)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		for name, content := range files {
+			data, err := os.ReadFile(filepath.Join(dir, name))
+			if err != nil || string(data) != content {
+				t.Errorf("Expected %s to be restored with %q, got %q (%v)", name, content, data, err)
+			}
+		}
+		if link, err := os.Readlink(filepath.Join(dir, "link")); err != nil || link != "sub/dir/b.txt" {
+			t.Errorf("Expected the link to be restored, got %q (%v)", link, err)
+		}
+		if _, err := os.Stat(archive); !os.IsNotExist(err) {
+			t.Error("Expected the archive to be wiped")
+		}
+	}
+}
+
+// TestArchive_WrongPassphrase tests that nothing is written with a wrong passphrase
+func TestArchive_WrongPassphrase(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "docs")
+	writeTree(t, src, map[string]string{"a.txt": "a"})
+	archive, err := EncryptArchive(src, "passphrase", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecryptArchive(archive, "wrong", nil); err == nil {
+		t.Fatal("Expected an error, got nil")
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Error("Expected no folder to be created")
+	}
+}
+
+// writeArchive encrypts a tar with the given entries
+func writeArchive(t *testing.T, path string, headers ...*tar.Header) {
+	t.Helper()
+	var buf bytes.Buffer
+	ew, err := NewEncryptWriter(&buf, "passphrase", &FileInfo{Name: "evil", Archive: archiveTar})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw := tar.NewWriter(ew)
+	for _, h := range headers {
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		if h.Size > 0 {
+			tw.Write(bytes.Repeat([]byte("x"), int(h.Size)))
+		}
+	}
+	tw.Close()
+	ew.Close()
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestArchive_UnsafePaths tests that the entries escaping the folder are refused
+func TestArchive_UnsafePaths(t *testing.T) {
+	for _, name := range []string{"../escape.txt", "/etc/escape.txt", "sub/../../escape.txt"} {
+		root := t.TempDir()
+		archive := filepath.Join(root, "evil"+ArchiveExt)
+		writeArchive(t, archive, &tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0600, Size: 1})
+
+		_, err := DecryptArchive(archive, "passphrase", &Options{Keep: true})
+		if !errors.Is(err, ErrUnsafePath) {
+			t.Errorf("%s: expected ErrUnsafePath, got: %v", name, err)
+		}
+		if _, err := os.Stat(filepath.Join(root, "evil")); !os.IsNotExist(err) {
+			t.Errorf("%s: expected the partial folder to be removed", name)
+		}
+	}
+}
+
+// TestArchive_Symlinks tests the symlink policies on a link escaping the folder
+func TestArchive_Symlinks(t *testing.T) {
+	link := &tar.Header{Name: "passwd", Typeflag: tar.TypeSymlink, Linkname: "../../etc/passwd", Mode: 0777}
+	for policy, wantErr := range map[string]bool{SymlinkSafe: false, SymlinkSkip: false, SymlinkError: true} {
+		root := t.TempDir()
+		archive := filepath.Join(root, "evil"+ArchiveExt)
+		writeArchive(t, archive, link)
+
+		dir, err := DecryptArchive(archive, "passphrase", &Options{Keep: true, Symlinks: policy})
+		if wantErr {
+			if !errors.Is(err, ErrUnsafePath) {
+				t.Errorf("%s: expected ErrUnsafePath, got: %v", policy, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: expected no error, got: %v", policy, err)
+		}
+		if _, err := os.Lstat(filepath.Join(dir, "passwd")); !os.IsNotExist(err) {
+			t.Errorf("%s: expected the link not to be created", policy)
+		}
+	}
+}
+
+// TestArchive_ChainedSymlinks tests that the entries going through a symlink
+// of the archive are refused: a -> . then a/b -> .. would make a/b/escaped.txt
+// land in the parent of the folder
+func TestArchive_ChainedSymlinks(t *testing.T) {
+	for _, policy := range []string{SymlinkSafe, SymlinkError} {
+		root := t.TempDir()
+		archive := filepath.Join(root, "evil"+ArchiveExt)
+		writeArchive(t, archive,
+			&tar.Header{Name: "a", Typeflag: tar.TypeSymlink, Linkname: ".", Mode: 0777},
+			&tar.Header{Name: "a/b", Typeflag: tar.TypeSymlink, Linkname: "..", Mode: 0777},
+			&tar.Header{Name: "a/b/escaped.txt", Typeflag: tar.TypeReg, Mode: 0600, Size: 1})
+
+		_, err := DecryptArchive(archive, "passphrase", &Options{Keep: true, Symlinks: policy})
+		if !errors.Is(err, ErrUnsafePath) {
+			t.Errorf("%s: expected ErrUnsafePath, got: %v", policy, err)
+		}
+		if _, err := os.Lstat(filepath.Join(root, "escaped.txt")); !os.IsNotExist(err) {
+			t.Errorf("%s: expected no file written outside of the folder", policy)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package security
+
+import "bytes"
+
+// protoMagic marks a box encoded with protobuf, in front of its container (and
+// of the key file mark): the boxes without it are encoded with YAML
+var protoMagic = []byte("RAPTOR-PROTO\x00\x01")
+
+// MarkProto marks the box as encoded with protobuf
+func MarkProto(data []byte) []byte {
+	return append(append([]byte{}, protoMagic...), data...)
+}
+
+// UnmarkProto returns the container of the box without the encoding mark and
+// true if the box is encoded with protobuf
+func UnmarkProto(data []byte) ([]byte, bool) {
+	if bytes.HasPrefix(data, protoMagic) {
+		return data[len(protoMagic):], true
+	}
+	return data, false
+}
@@ -0,0 +1,300 @@
+package security
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/mas2020-golang/goutils/output"
+)
+
+// The files of a folder are selected with gitignore-style patterns:
+//
+//   - a pattern without a slash matches a name at any level, *.log
+//   - a pattern with a slash is relative to the folder, /build or docs/*.md
+//   - ** matches any number of folders, **/cache or logs/**
+//   - a trailing slash matches folders only, tmp/
+//   - ! negates the pattern, the last matching pattern wins
+//
+// A pattern matching a folder matches all its content. The patterns are
+// matched against the names without the .enc and .age extensions, so that the
+// same patterns select the files to decrypt.
+const (
+	// IgnoreFile is the file of a folder with the patterns to exclude, one per
+	// line (# starts a comment)
+	IgnoreFile = ".raptorignore"
+
+	// SymlinkFollow follows the links walking a folder: a linked folder is
+	// walked as part of the tree, a linked file is replaced by the result and
+	// its target is left untouched
+	SymlinkFollow = "follow"
+)
+
+// pattern is a parsed gitignore-style pattern
+type pattern struct {
+	segs    []string
+	negate  bool
+	dirOnly bool
+}
+
+func parsePattern(text string) (*pattern, error) {
+	line := strings.TrimRight(text, " \t\r")
+	if len(line) == 0 || strings.HasPrefix(line, "#") {
+		return nil, nil
+	}
+	p := &pattern{}
+	switch {
+	case strings.HasPrefix(line, "!"):
+		p.negate = true
+		line = line[1:]
+	case strings.HasPrefix(line, `\#`), strings.HasPrefix(line, `\!`):
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if len(line) == 0 {
+		return nil, fmt.Errorf("invalid pattern %q", text)
+	}
+	p.segs = strings.Split(line, "/")
+	if !anchored {
+		p.segs = append([]string{"**"}, p.segs...)
+	}
+	for _, s := range p.segs {
+		if _, err := path.Match(s, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", text, err)
+		}
+	}
+	return p, nil
+}
+
+// match tells if the pattern matches the slash separated relative path
+func (p *pattern) match(rel string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	return matchSegs(p.segs, strings.Split(rel, "/"))
+}
+
+func matchSegs(pat, name []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			pat = pat[1:]
+			if len(pat) == 0 {
+				return len(name) > 0
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegs(pat, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pat[0], name[0]); !ok {
+			return false
+		}
+		pat, name = pat[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// matchList applies the patterns to the path and its folders, the last
+// matching pattern wins
+func matchList(patterns []*pattern, rel string, isDir bool) bool {
+	matched := false
+	segs := strings.Split(rel, "/")
+	for i := 1; i <= len(segs); i++ {
+		sub, dir := strings.Join(segs[:i], "/"), i < len(segs) || isDir
+		for _, p := range patterns {
+			if p.match(sub, dir) {
+				matched = !p.negate
+			}
+		}
+	}
+	return matched
+}
+
+// filter selects the elements of a folder
+type filter struct {
+	include, exclude []*pattern
+	minSize, maxSize int64
+}
+
+// newFilter returns the filter of the options and the IgnoreFile of the folder
+func newFilter(dirPath string, opts *Options) (*filter, error) {
+	f := &filter{}
+	var include, exclude []string
+	if opts != nil {
+		include, exclude = opts.Include, opts.Exclude
+		f.minSize, f.maxSize = opts.MinSize, opts.MaxSize
+	}
+	ignore, err := readIgnoreFile(filepath.Join(dirPath, IgnoreFile))
+	if err != nil {
+		return nil, err
+	}
+	// the command line patterns win over the file
+	exclude = append(ignore, exclude...)
+
+	for _, lines := range []struct {
+		in  []string
+		out *[]*pattern
+	}{{include, &f.include}, {exclude, &f.exclude}} {
+		for _, l := range lines.in {
+			p, err := parsePattern(l)
+			if err != nil {
+				return nil, err
+			}
+			if p != nil {
+				*lines.out = append(*lines.out, p)
+			}
+		}
+	}
+	return f, nil
+}
+
+func readIgnoreFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	defer file.Close()
+	var lines []string
+	s := bufio.NewScanner(file)
+	for s.Scan() {
+		lines = append(lines, s.Text())
+	}
+	return lines, s.Err()
+}
+
+// skipDir tells if the folder is excluded
+func (f *filter) skipDir(rel string) bool {
+	return matchList(f.exclude, rel, true)
+}
+
+// skipFile tells if the file is excluded, not included or out of the size range
+func (f *filter) skipFile(rel string, size int64) bool {
+	rel = strings.TrimSuffix(strings.TrimSuffix(rel, ".enc"), AgeExt)
+	switch {
+	case f.minSize > 0 && size < f.minSize, f.maxSize > 0 && size > f.maxSize:
+		return true
+	case len(f.include) > 0 && !matchList(f.include, rel, false):
+		return true
+	}
+	return matchList(f.exclude, rel, false)
+}
+
+// walkSymlinks returns the symlinks policy walking a folder
+func (o *Options) walkSymlinks() string {
+	if o == nil {
+		return SymlinkSkip
+	}
+	switch o.Symlinks {
+	case SymlinkFollow, SymlinkError:
+		return o.Symlinks
+	}
+	return SymlinkSkip
+}
+
+// collectTree returns the files and the folders of the dirPath tree selected by
+// the options, in walk order. The output folder, the journal and the special
+// files are skipped, the symlinks follow the walk policy.
+func collectTree(dirPath string, opts *Options) (files, dirs []string, err error) {
+	f, err := newFilter(dirPath, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	outputDir := ""
+	if opts != nil && len(opts.OutputDir) > 0 {
+		outputDir, _ = filepath.Abs(opts.OutputDir)
+	}
+	skip := map[string]bool{filepath.Join(dirPath, JournalName): true, filepath.Join(dirPath, IgnoreFile): true,
+		filepath.Join(dirPath, ManifestName): true}
+	links := opts.walkSymlinks()
+
+	var visit func(p string, info os.FileInfo, parents []os.FileInfo) error
+	visit = func(p string, info os.FileInfo, parents []os.FileInfo) error {
+		rel, err := filepath.Rel(dirPath, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if info.Mode()&os.ModeSymlink != 0 {
+			switch links {
+			case SymlinkError:
+				return fmt.Errorf("%s is a symlink", p)
+			case SymlinkFollow:
+				if info, err = os.Stat(p); err != nil {
+					output.Warning("", fmt.Sprintf("symlink %s skipped: %v", p, err))
+					return nil
+				}
+			default:
+				output.Warning("", fmt.Sprintf("symlink %s skipped", p))
+				return nil
+			}
+		}
+
+		if !info.IsDir() {
+			switch {
+			case skip[p]:
+			case !info.Mode().IsRegular():
+				output.Warning("", fmt.Sprintf("%s skipped as it is not a regular file", p))
+			case !f.skipFile(rel, info.Size()):
+				files = append(files, p)
+			}
+			return nil
+		}
+		if rel != "." {
+			if abs, _ := filepath.Abs(p); abs == outputDir || f.skipDir(rel) {
+				return nil
+			}
+			for _, parent := range parents {
+				if os.SameFile(parent, info) {
+					output.Warning("", fmt.Sprintf("symlink %s skipped as it creates a loop", p))
+					return nil
+				}
+			}
+			dirs = append(dirs, p)
+		}
+		entries, err := os.ReadDir(p)
+		if err != nil {
+			return err
+		}
+		parents = append(parents, info)
+		for _, e := range entries {
+			child := filepath.Join(p, e.Name())
+			ci, err := os.Lstat(child)
+			if err != nil {
+				return err
+			}
+			if err := visit(child, ci, parents); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	info, err := os.Stat(dirPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return files, dirs, visit(dirPath, info, nil)
+}
+
+// SelectFiles returns the files of the folder EncryptDirectory and
+// DecryptDirectory would process with the options
+func SelectFiles(dirPath string, opts *Options) ([]string, error) {
+	files, _, err := collectTree(dirPath, opts)
+	return files, err
+}
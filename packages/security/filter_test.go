@@ -0,0 +1,87 @@
+package security
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestPattern_Match(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		isDir, want   bool
+	}{
+		{"*.log", "app.log", false, true},
+		{"*.log", "a/b/app.log", false, true},
+		{"/build", "build", true, true},
+		{"/build", "src/build", true, false},
+		{"docs/*.md", "docs/a.md", false, true},
+		{"docs/*.md", "docs/sub/a.md", false, false},
+		{"**/cache", "a/b/cache", true, true},
+		{"logs/**", "logs/a/b.txt", false, true},
+		{"logs/**", "logs", true, false},
+		{"a/**/b", "a/b", true, true},
+		{"a/**/b", "a/x/y/b", true, true},
+		{"tmp/", "tmp", false, false},
+		{"tmp/", "x/tmp", true, true},
+	}
+	for _, tt := range tests {
+		p, err := parsePattern(tt.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := p.match(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("%q on %q: got %v, expected %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+	if _, err := parsePattern("a/[b"); err == nil {
+		t.Error("Expected an error for an invalid pattern")
+	}
+}
+
+// TestSelectFiles tests the patterns, the ignore file, the sizes and the links
+func TestSelectFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		".git/config":  "x",
+		"app.log":      "x",
+		"keep.log":     "x",
+		"docs/a.md":    "x",
+		"docs/big.pdf": "0123456789",
+		"src/main.go":  "x",
+		IgnoreFile:     "*.log\n!keep.log\n# comment\n",
+	})
+	ext := t.TempDir()
+	writeTree(t, ext, map[string]string{"linked.txt": "x"})
+	if err := os.Symlink(ext, filepath.Join(dir, "ext")); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(opts *Options, want ...string) {
+		t.Helper()
+		files, err := SelectFiles(dir, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, f := range files {
+			rel, _ := filepath.Rel(dir, f)
+			got = append(got, filepath.ToSlash(rel))
+		}
+		sort.Strings(got)
+		sort.Strings(want)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, expected %v", got, want)
+		}
+	}
+	check(&Options{Exclude: []string{".git/"}}, "keep.log", "docs/a.md", "docs/big.pdf", "src/main.go")
+	check(&Options{Exclude: []string{".git/"}, MaxSize: 5}, "keep.log", "docs/a.md", "src/main.go")
+	check(&Options{Include: []string{"docs/"}, MinSize: 5}, "docs/big.pdf")
+	check(&Options{Include: []string{"*.txt"}, Symlinks: SymlinkFollow}, "ext/linked.txt")
+
+	if _, err := SelectFiles(dir, &Options{Symlinks: SymlinkError}); err == nil {
+		t.Error("Expected an error for the symlink")
+	}
+}
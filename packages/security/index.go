@@ -0,0 +1,279 @@
+package security
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+
+	"gopkg.in/yaml.v2"
+)
+
+// The indexed layout seals the index of a box and every record (the sensitive
+// part of a secret) separately, so that a record is decrypted only when it is
+// accessed and a save encrypts again only the changed records:
+//
+//	magic | salt (16 bytes) | keyring length (4 bytes) | keyring | index frame | record frame...
+//	frame = length (4 bytes) | nonce | AES-GCM(data)
+//
+// The key is derived from the passphrase and the salt, for a shared box it is
+// the data key wrapped in the keyring (YAML, empty for a password box). The
+// header is authenticated as additional data of the index. The index starts
+// with the SHA-256 of every record frame: a record replaced, swapped, removed
+// or restored from an older version fails the verification.
+//
+//	index = records (4 bytes) | SHA-256 of the frames | data
+const indexMaxFrame = 256 * 1024 * 1024
+
+var (
+	indexMagic = []byte("RAPTOR-INDEX\x00\x01")
+	// ErrRecord is returned when a record doesn't match the index
+	ErrRecord = errors.New("a record of the box doesn't match its index")
+)
+
+// RecordKey seals the index and the records of a box of the indexed layout
+type RecordKey struct {
+	salt    []byte
+	keyring *Keyring
+	key     *LockedBuffer
+	gcm     cipher.AEAD
+}
+
+// NewRecordKey returns a new key for an indexed box: the data key of the
+// keyring for a shared box, otherwise a key derived from the passphrase and a
+// new salt
+func NewRecordKey(passphrase []byte, k *Keyring) (*RecordKey, error) {
+	salt := make([]byte, streamSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %v", err)
+	}
+	return newRecordKey(passphrase, k, salt)
+}
+
+func newRecordKey(passphrase []byte, k *Keyring, salt []byte) (*RecordKey, error) {
+	var raw []byte
+	if k != nil {
+		if len(k.dataKey) == 0 {
+			return nil, fmt.Errorf("the keyring is locked")
+		}
+		raw = append([]byte{}, k.dataKey...)
+	} else {
+		raw = streamKey(passphrase, salt)
+	}
+	key, err := NewLockedBufferFrom(raw)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key.Bytes())
+	runtime.KeepAlive(key)
+	if err != nil {
+		key.Destroy()
+		return nil, fmt.Errorf("failed to create cipher: %v", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		key.Destroy()
+		return nil, err
+	}
+	return &RecordKey{salt: salt, keyring: k, key: key, gcm: gcm}, nil
+}
+
+// Matches is true when the key still seals the box of the passphrase and the
+// keyring: the records sealed with it can be kept as they are
+func (rk *RecordKey) Matches(passphrase []byte, k *Keyring) bool {
+	if rk == nil || rk.key.Bytes() == nil || rk.keyring != k {
+		return false
+	}
+	want := streamKey(passphrase, rk.salt)
+	defer clear(want)
+	if k != nil {
+		want = k.dataKey
+	}
+	defer runtime.KeepAlive(rk.key)
+	return subtle.ConstantTimeCompare(rk.key.Bytes(), want) == 1
+}
+
+// Keyring returns the keyring of a shared box, nil for a password box
+func (rk *RecordKey) Keyring() *Keyring {
+	return rk.keyring
+}
+
+// Seal encrypts a record
+func (rk *RecordKey) Seal(plaintext []byte) ([]byte, error) {
+	return rk.seal(plaintext, nil)
+}
+
+// Open decrypts a record sealed by Seal
+func (rk *RecordKey) Open(record []byte) ([]byte, error) {
+	return rk.open(record, nil)
+}
+
+// Destroy zeroes the key
+func (rk *RecordKey) Destroy() {
+	if rk != nil {
+		rk.key.Destroy()
+	}
+}
+
+func (rk *RecordKey) seal(plaintext, ad []byte) ([]byte, error) {
+	nonce := make([]byte, rk.gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %v", err)
+	}
+	return rk.gcm.Seal(nonce, nonce, plaintext, ad), nil
+}
+
+func (rk *RecordKey) open(sealed, ad []byte) ([]byte, error) {
+	if len(sealed) < rk.gcm.NonceSize() {
+		return nil, ErrTruncated
+	}
+	n := rk.gcm.NonceSize()
+	plaintext, err := rk.gcm.Open(nil, sealed[:n], sealed[n:], ad)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data: %v", err)
+	}
+	return plaintext, nil
+}
+
+// IsIndexedBox returns true if the data is a box of the indexed layout
+func IsIndexedBox(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimPrefix(data, keyFileMagic), indexMagic)
+}
+
+// IsSharedIndexedBox returns true if the data is a shared box of the indexed
+// layout
+func IsSharedIndexedBox(data []byte) bool {
+	k, _, _, err := parseIndexedBox(data)
+	return err == nil && k != nil
+}
+
+// OpenIndexedBox decrypts the index of the box and returns its data with the
+// key and the sealed records, verified against the index. The secret is the
+// passphrase or, for a shared box, the age secret key of a member.
+func OpenIndexedBox(data []byte, secret []byte) (*RecordKey, []byte, [][]byte, error) {
+	k, header, frames, err := parseIndexedBox(data)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	salt := header[len(indexMagic) : len(indexMagic)+streamSaltSize]
+	if k != nil {
+		if err := k.unlock(secret); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	rk, err := newRecordKey(secret, k, append([]byte{}, salt...))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	index, err := rk.open(frames[0], header)
+	if err != nil {
+		rk.Destroy()
+		return nil, nil, nil, err
+	}
+
+	records := frames[1:]
+	if len(index) < 4 {
+		rk.Destroy()
+		return nil, nil, nil, ErrTruncated
+	}
+	n := binary.BigEndian.Uint32(index)
+	index = index[4:]
+	if int64(n) != int64(len(records)) || len(index) < int(n)*sha256.Size {
+		rk.Destroy()
+		return nil, nil, nil, ErrRecord
+	}
+	for i, r := range records {
+		sum := sha256.Sum256(r)
+		if !bytes.Equal(sum[:], index[i*sha256.Size:(i+1)*sha256.Size]) {
+			rk.Destroy()
+			return nil, nil, nil, fmt.Errorf("%w: record %d", ErrRecord, i)
+		}
+	}
+	return rk, index[int(n)*sha256.Size:], records, nil
+}
+
+// parseIndexedBox returns the locked keyring (nil for a password box), the
+// header and the frames, the index first
+func parseIndexedBox(data []byte) (*Keyring, []byte, [][]byte, error) {
+	data = bytes.TrimPrefix(data, keyFileMagic)
+	if !bytes.HasPrefix(data, indexMagic) {
+		return nil, nil, nil, fmt.Errorf("the box is not an indexed box")
+	}
+	size := len(indexMagic) + streamSaltSize + 4
+	if len(data) < size {
+		return nil, nil, nil, ErrTruncated
+	}
+	n := binary.BigEndian.Uint32(data[size-4:])
+	if n > keyringMaxHeader || int64(n) > int64(len(data)-size) {
+		return nil, nil, nil, fmt.Errorf("invalid header size %d", n)
+	}
+	header, rest := data[:size+int(n)], data[size+int(n):]
+
+	var k *Keyring
+	if n > 0 {
+		k = &Keyring{}
+		if err := yaml.Unmarshal(header[size:], k); err != nil {
+			return nil, nil, nil, fmt.Errorf("invalid header: %v", err)
+		}
+	}
+
+	var frames [][]byte
+	for len(rest) > 0 {
+		if len(rest) < 4 {
+			return nil, nil, nil, ErrTruncated
+		}
+		size := binary.BigEndian.Uint32(rest)
+		if size > indexMaxFrame || int64(size) > int64(len(rest)-4) {
+			return nil, nil, nil, ErrTruncated
+		}
+		frames = append(frames, rest[4:4+size])
+		rest = rest[4+size:]
+	}
+	if len(frames) == 0 {
+		return nil, nil, nil, ErrTruncated
+	}
+	return k, header, frames, nil
+}
+
+// SealIndexedBox encrypts the index data and writes it with the records sealed
+// by the key
+func SealIndexedBox(rk *RecordKey, data []byte, records [][]byte) ([]byte, error) {
+	var header []byte
+	if rk.keyring != nil {
+		var err error
+		if header, err = yaml.Marshal(rk.keyring); err != nil {
+			return nil, err
+		}
+	}
+	out := append([]byte{}, indexMagic...)
+	out = append(out, rk.salt...)
+	out = binary.BigEndian.AppendUint32(out, uint32(len(header)))
+	out = append(out, header...)
+
+	index := binary.BigEndian.AppendUint32(nil, uint32(len(records)))
+	for _, r := range records {
+		sum := sha256.Sum256(r)
+		index = append(index, sum[:]...)
+	}
+	index = append(index, data...)
+	sealed, err := rk.seal(index, out)
+	clear(index)
+	if err != nil {
+		return nil, err
+	}
+
+	out = binary.BigEndian.AppendUint32(out, uint32(len(sealed)))
+	out = append(out, sealed...)
+	for _, r := range records {
+		out = binary.BigEndian.AppendUint32(out, uint32(len(r)))
+		out = append(out, r...)
+	}
+	return out, nil
+}
@@ -0,0 +1,105 @@
+package security
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// TestIndexedBox tests that the index and the records are opened back and a
+// record swapped or restored from an older box is refused
+func TestIndexedBox(t *testing.T) {
+	rk, err := NewRecordKey([]byte("pwd"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records [][]byte
+	for _, r := range []string{"first", "second"} {
+		sealed, err := rk.Seal([]byte(r))
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, sealed)
+	}
+	box, err := SealIndexedBox(rk, []byte("index"), records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsIndexedBox(box) || IsSharedIndexedBox(box) || IsSharedBox(box) {
+		t.Fatal("Expected a password box of the indexed layout")
+	}
+
+	opened, index, got, err := OpenIndexedBox(box, []byte("pwd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(index) != "index" || len(got) != 2 {
+		t.Fatalf("Expected the index and 2 records, got %q and %d", index, len(got))
+	}
+	if plaintext, err := opened.Open(got[1]); err != nil || string(plaintext) != "second" {
+		t.Errorf("Expected the second record, got %q (%v)", plaintext, err)
+	}
+	if !opened.Matches([]byte("pwd"), nil) || opened.Matches([]byte("wrong"), nil) {
+		t.Error("Expected the key to match only its passphrase")
+	}
+	if _, _, _, err := OpenIndexedBox(box, []byte("wrong")); err == nil {
+		t.Error("Expected an error with the wrong passphrase, got nil")
+	}
+
+	// the records swapped keep a valid encryption but not the index
+	swapped, err := SealIndexedBox(rk, []byte("index"), [][]byte{records[0], records[1]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := bytes.Index(swapped, records[0]) - 4
+	second := bytes.Index(swapped, records[1]) - 4
+	frame := func(r []byte) []byte { return append(binary.BigEndian.AppendUint32(nil, uint32(len(r))), r...) }
+	tampered := append([]byte{}, swapped[:first]...)
+	tampered = append(tampered, frame(records[1])...)
+	tampered = append(tampered, frame(records[0])...)
+	tampered = append(tampered, swapped[second+4+len(records[1]):]...)
+	if _, _, _, err := OpenIndexedBox(tampered, []byte("pwd")); !errors.Is(err, ErrRecord) {
+		t.Errorf("Expected ErrRecord for swapped records, got: %v", err)
+	}
+}
+
+// TestIndexedBox_Shared tests that a shared box of the indexed layout is
+// opened by its members with the data key
+func TestIndexedBox_Shared(t *testing.T) {
+	k, err := NewKeyring("owner", []byte("owner-pwd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := k.AddPassphraseMember("alice", []byte("alice-pwd")); err != nil {
+		t.Fatal(err)
+	}
+	rk, err := NewRecordKey([]byte("owner-pwd"), k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, err := rk.Seal([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	box, err := SealIndexedBox(rk, []byte("index"), [][]byte{record})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsSharedIndexedBox(box) {
+		t.Fatal("Expected a shared box")
+	}
+	opened, _, records, err := OpenIndexedBox(box, []byte("alice-pwd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened.Keyring().Unlocked() != "alice" {
+		t.Errorf("Expected the box unlocked by alice, got %s", opened.Keyring().Unlocked())
+	}
+	if plaintext, err := opened.Open(records[0]); err != nil || string(plaintext) != "secret" {
+		t.Errorf("Expected the record, got %q (%v)", plaintext, err)
+	}
+	if _, _, _, err := OpenIndexedBox(box, []byte("wrong")); !errors.Is(err, ErrNotMember) {
+		t.Errorf("Expected ErrNotMember, got: %v", err)
+	}
+}
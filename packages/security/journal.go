@@ -0,0 +1,437 @@
+package security
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/mas2020-golang/goutils/output"
+	"gopkg.in/yaml.v2"
+)
+
+// The journal records the progress of EncryptDirectory in the encrypted folder,
+// so that an interrupted run can be resumed or rolled back. It is written in
+// append only mode and synced after every record:
+//
+//	magic | salt | (uint32 length | nonce | AES-GCM(YAML record))...
+//
+// The records are sealed with the stream key and the record number as
+// additional data: removed or reordered records fail the authentication. A
+// record cut by a crash at the end of the journal is ignored.
+//
+// Every file goes through start (the destination is being written), written
+// (the destination is complete, the source is being wiped) and done.
+const (
+	// JournalName is the name of the journal in the encrypted folder
+	JournalName = ".raptor-journal"
+
+	journalStart   = "start"
+	journalWritten = "written"
+	journalDone    = "done"
+	journalRenamed = "renamed"
+	journalPlan    = "plan"
+
+	journalMaxRecord = 256 * 1024 * 1024
+)
+
+var journalMagic = []byte("RAPTOR-JOURNAL\x00\x01")
+
+// ErrUnfinished is returned when a folder has the journal of an interrupted run
+var ErrUnfinished = errors.New("an interrupted encryption has to be resumed or rolled back first")
+
+// runPlan is the first record: the options of the run and the elements of the
+// tree, relative to the folder
+type runPlan struct {
+	OutputDir    string   `yaml:"outputDir,omitempty"`
+	Keep         bool     `yaml:"keep,omitempty"`
+	Force        bool     `yaml:"force,omitempty"`
+	EncryptNames bool     `yaml:"encryptNames,omitempty"`
+	Wipe         string   `yaml:"wipe,omitempty"`
+	Files        []string `yaml:"files"`
+	Dirs         []string `yaml:"dirs,omitempty"`
+
+	// ReplaceManifest is the option of the run, see Options
+	ReplaceManifest bool `yaml:"replaceManifest,omitempty"`
+}
+
+type journalRecord struct {
+	Op   string   `yaml:"op"`
+	Path string   `yaml:"path,omitempty"`
+	Dst  string   `yaml:"dst,omitempty"`
+	Plan *runPlan `yaml:"plan,omitempty"`
+}
+
+// journal appends the records of a run
+type journal struct {
+	root string
+	f    *os.File
+	gcm  cipher.AEAD
+	seq  uint64
+	// dsts are the destinations of the started files by source
+	dsts map[string]string
+}
+
+// HasJournal returns true if the folder has the journal of an interrupted run
+func HasJournal(dirPath string) bool {
+	_, err := os.Lstat(filepath.Join(dirPath, JournalName))
+	return err == nil
+}
+
+// createJournal writes a new journal with the plan of the run
+func createJournal(dirPath, passphrase string, plan *runPlan) (*journal, error) {
+	salt := make([]byte, streamSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %v", err)
+	}
+	gcm, err := newStreamGCM(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dirPath, JournalName), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		if os.IsExist(err) {
+			return nil, fmt.Errorf("%w: %s", ErrUnfinished, dirPath)
+		}
+		return nil, fmt.Errorf("failed to create the journal: %v", err)
+	}
+	j := &journal{root: dirPath, f: f, gcm: gcm}
+	if _, err := f.Write(append(append([]byte{}, journalMagic...), salt...)); err != nil {
+		j.close()
+		return nil, err
+	}
+	if err := j.append(&journalRecord{Op: journalPlan, Plan: plan}); err != nil {
+		j.close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return j, nil
+}
+
+// openJournal reads the journal of the folder and opens it to append the
+// next records
+func openJournal(dirPath, passphrase string) (*journal, []journalRecord, error) {
+	path := filepath.Join(dirPath, JournalName)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, fmt.Errorf("no interrupted encryption found in %s", dirPath)
+		}
+		return nil, nil, fmt.Errorf("failed to read the journal: %v", err)
+	}
+	if !bytes.HasPrefix(data, journalMagic) || len(data) < len(journalMagic)+streamSaltSize {
+		return nil, nil, fmt.Errorf("%s is not a raptor journal", path)
+	}
+	data = data[len(journalMagic):]
+	gcm, err := newStreamGCM(passphrase, data[:streamSaltSize])
+	if err != nil {
+		return nil, nil, err
+	}
+	j := &journal{root: dirPath, gcm: gcm}
+	data = data[streamSaltSize:]
+
+	var records []journalRecord
+	for len(data) >= 4 {
+		size := binary.BigEndian.Uint32(data)
+		if int64(size) > int64(len(data)-4) || size < uint32(gcm.NonceSize()) {
+			// cut by a crash
+			break
+		}
+		sealed := data[4 : 4+size]
+		data = data[4+size:]
+		plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], j.ad())
+		if err != nil {
+			if j.seq == 0 {
+				return nil, nil, fmt.Errorf("failed to decrypt the journal: wrong password")
+			}
+			if len(data) == 0 {
+				break
+			}
+			return nil, nil, fmt.Errorf("the journal %s is corrupted", path)
+		}
+		var r journalRecord
+		if err := yaml.Unmarshal(plain, &r); err != nil {
+			return nil, nil, fmt.Errorf("the journal %s is corrupted: %v", path, err)
+		}
+		records = append(records, r)
+		j.seq++
+	}
+	if len(records) == 0 || records[0].Op != journalPlan || records[0].Plan == nil {
+		return nil, nil, fmt.Errorf("the journal %s has no plan", path)
+	}
+	if j.f, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600); err != nil {
+		return nil, nil, fmt.Errorf("failed to open the journal: %v", err)
+	}
+	return j, records, nil
+}
+
+// ad is the additional data of the next record
+func (j *journal) ad() []byte {
+	return binary.BigEndian.AppendUint64(nil, j.seq)
+}
+
+// append seals the record at the end of the journal and syncs it on the disk
+func (j *journal) append(r *journalRecord) error {
+	plain, err := yaml.Marshal(r)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, j.gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %v", err)
+	}
+	sealed := j.gcm.Seal(nonce, nonce, plain, j.ad())
+	if len(sealed) > journalMaxRecord {
+		return fmt.Errorf("the journal record is too big")
+	}
+	buf := binary.BigEndian.AppendUint32(nil, uint32(len(sealed)))
+	if _, err := j.f.Write(append(buf, sealed...)); err != nil {
+		return fmt.Errorf("failed to write the journal: %v", err)
+	}
+	if err := j.f.Sync(); err != nil {
+		return fmt.Errorf("failed to write the journal: %v", err)
+	}
+	j.seq++
+	return nil
+}
+
+// rel returns the path relative to the folder when it is inside of it
+func (j *journal) rel(path string) string {
+	if rel, err := filepath.Rel(j.root, path); err == nil && filepath.IsLocal(rel) {
+		return rel
+	}
+	abs, _ := filepath.Abs(path)
+	return abs
+}
+
+// abs returns the path of a journal record
+func (j *journal) abs(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(j.root, path)
+}
+
+// record appends a record for the path, a nil journal records nothing
+func (j *journal) record(op, path, dst string) error {
+	if j == nil {
+		return nil
+	}
+	r := &journalRecord{Op: op, Path: j.rel(path)}
+	if len(dst) > 0 {
+		r.Dst = j.rel(dst)
+	}
+	if op == journalStart {
+		if j.dsts == nil {
+			j.dsts = map[string]string{}
+		}
+		j.dsts[r.Path] = r.Dst
+	}
+	return j.append(r)
+}
+
+func (j *journal) close() {
+	if j != nil && j.f != nil {
+		j.f.Close()
+	}
+}
+
+// finish removes the journal of a completed run
+func (j *journal) finish() error {
+	j.close()
+	return os.Remove(j.f.Name())
+}
+
+// fileState is the last operation recorded for a file and its destination
+type fileState struct {
+	op, dst string
+}
+
+// replay returns the plan, the state of the files and the renamed folders (in
+// the order of the renaming, destination by source)
+func replay(records []journalRecord) (*runPlan, map[string]fileState, []journalRecord) {
+	files := map[string]fileState{}
+	var renamed []journalRecord
+	for _, r := range records[1:] {
+		switch r.Op {
+		case journalStart:
+			files[r.Path] = fileState{r.Op, r.Dst}
+		case journalWritten, journalDone:
+			files[r.Path] = fileState{r.Op, files[r.Path].dst}
+		case journalRenamed:
+			renamed = append(renamed, r)
+		}
+	}
+	return records[0].Plan, files, renamed
+}
+
+// ResumeEncryptDirectory completes the interrupted encryption of the folder
+// with the options of the interrupted run
+func ResumeEncryptDirectory(dirPath, passphrase string) error {
+	j, records, err := openJournal(dirPath, passphrase)
+	if err != nil {
+		return err
+	}
+	defer j.close()
+	plan, files, renamed := replay(records)
+	j.dsts = map[string]string{}
+	for rel, state := range files {
+		j.dsts[rel] = state.dst
+	}
+
+	opts := &Options{OutputDir: plan.OutputDir, Keep: plan.Keep, Force: plan.Force, Wipe: plan.Wipe,
+		ReplaceManifest: plan.ReplaceManifest, root: dirPath, journal: j}
+	if plan.EncryptNames {
+		nc, err := newNameCipher(passphrase)
+		if err != nil {
+			return err
+		}
+		opts.names = nc.encrypt
+	}
+
+	for _, rel := range plan.Files {
+		path := j.abs(rel)
+		state := files[rel]
+		switch state.op {
+		case journalDone:
+			continue
+		case journalWritten:
+			// the destination is complete, the source may be partially wiped
+			if !opts.keep() {
+				if err := opts.deleteFile(path); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+			}
+			if err := j.record(journalDone, path, ""); err != nil {
+				return err
+			}
+			continue
+		case journalStart:
+			// the destination may be partial
+			if err := os.Remove(j.abs(state.dst)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if _, err := os.Lstat(path); os.IsNotExist(err) {
+			output.Warning("", fmt.Sprintf("file %s skipped as it doesn't exist anymore", path))
+			if err := j.record(journalDone, path, ""); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := EncryptFile(path, passphrase, opts); err != nil && !errors.Is(err, ErrInvalidFile) {
+			return err
+		}
+	}
+
+	if opts.names != nil && len(opts.OutputDir) == 0 {
+		done := map[string]bool{}
+		for _, r := range renamed {
+			done[r.Path] = true
+		}
+		var dirs []string
+		for _, rel := range plan.Dirs {
+			if done[rel] {
+				continue
+			}
+			// renamed right before the interruption
+			src := j.abs(rel)
+			if _, err := os.Lstat(src); os.IsNotExist(err) {
+				name, err := opts.rename(filepath.Base(src))
+				if err != nil {
+					return err
+				}
+				if err := j.record(journalRenamed, src, filepath.Join(filepath.Dir(src), name)); err != nil {
+					return err
+				}
+				continue
+			}
+			dirs = append(dirs, src)
+		}
+		if err := opts.renameDirs(dirs); err != nil {
+			return err
+		}
+	}
+	slog.Debug("security.ResumeEncryptDirectory()", "dirPath", dirPath, "files", len(plan.Files))
+	if err := writeRunManifest(j, passphrase, opts); err != nil {
+		return err
+	}
+	return j.finish()
+}
+
+// RollbackEncryptDirectory restores the folder as it was before the interrupted
+// encryption: the renamed folders get back their names and the encrypted files
+// are decrypted into their sources.
+func RollbackEncryptDirectory(dirPath, passphrase string) error {
+	j, records, err := openJournal(dirPath, passphrase)
+	if err != nil {
+		return err
+	}
+	defer j.close()
+	plan, files, renamed := replay(records)
+
+	// the parents were renamed after their children
+	for i := len(renamed) - 1; i >= 0; i-- {
+		src, dst := j.abs(renamed[i].Path), j.abs(renamed[i].Dst)
+		if _, err := os.Lstat(dst); os.IsNotExist(err) {
+			continue
+		}
+		if err := os.Rename(dst, src); err != nil {
+			return err
+		}
+	}
+
+	for _, rel := range plan.Files {
+		state, ok := files[rel]
+		if !ok {
+			continue
+		}
+		path, dst := j.abs(rel), j.abs(state.dst)
+		if _, err := os.Lstat(dst); os.IsNotExist(err) {
+			continue
+		}
+		// a source wiped, or being wiped, is decrypted from the destination
+		if state.op != journalStart && !plan.Keep {
+			if err := restoreFile(dst, path, passphrase); err != nil {
+				return err
+			}
+		}
+		if err := os.Remove(dst); err != nil {
+			return err
+		}
+	}
+	slog.Debug("security.RollbackEncryptDirectory()", "dirPath", dirPath, "files", len(files))
+	return j.finish()
+}
+
+// restoreFile decrypts the encrypted file into path
+func restoreFile(encPath, path, passphrase string) error {
+	in, err := os.Open(encPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	dr, err := NewDecryptReader(in, passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt %s: %w", encPath, err)
+	}
+	err = WriteFile(path, true, func(out io.Writer) error {
+		_, err := io.Copy(out, dr)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to decrypt %s: %w", encPath, err)
+	}
+	if dr.Info != nil {
+		if err := dr.Info.restore(path); err != nil {
+			output.Warning("", fmt.Sprintf("failed to restore the metadata of %s: %v", path, err))
+		}
+	}
+	return nil
+}
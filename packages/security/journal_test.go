@@ -0,0 +1,137 @@
+package security
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// interruptedRun leaves a journal in dir: b.txt.enc exists before the run, so
+// the encryption stops on b.txt after a.txt has been encrypted
+func interruptedRun(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"a.txt": "a", "b.txt": "b", "b.txt.enc": "old", "sub/c.txt": "c"})
+	err := EncryptDirectory(dir, "passphrase", &Options{Wipe: WipeNone})
+	if !errors.Is(err, ErrExists) {
+		t.Fatalf("Expected ErrExists, got: %v", err)
+	}
+	if !HasJournal(dir) {
+		t.Fatal("Expected the journal of the interrupted run")
+	}
+	return dir
+}
+
+// TestJournal_Resume tests that an interrupted run is completed by the resume
+func TestJournal_Resume(t *testing.T) {
+	dir := interruptedRun(t)
+	if err := EncryptDirectory(dir, "passphrase", nil); !errors.Is(err, ErrUnfinished) {
+		t.Fatalf("Expected ErrUnfinished, got: %v", err)
+	}
+	if err := DecryptDirectory(dir, "passphrase", nil); !errors.Is(err, ErrUnfinished) {
+		t.Fatalf("Expected ErrUnfinished, got: %v", err)
+	}
+	if err := ResumeEncryptDirectory(dir, "wrong"); err == nil || !strings.Contains(err.Error(), "wrong password") {
+		t.Fatalf("Expected a wrong password error, got: %v", err)
+	}
+
+	os.Remove(filepath.Join(dir, "b.txt.enc"))
+	if err := ResumeEncryptDirectory(dir, "passphrase"); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if HasJournal(dir) {
+		t.Error("Expected the journal to be removed")
+	}
+	for _, name := range []string{"a.txt", "b.txt", "sub/c.txt"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be wiped", name)
+		}
+		if _, err := os.Stat(filepath.Join(dir, name+".enc")); err != nil {
+			t.Errorf("Expected %s to be encrypted: %v", name, err)
+		}
+	}
+}
+
+// TestJournal_Rollback tests that an interrupted run is undone by the rollback
+func TestJournal_Rollback(t *testing.T) {
+	dir := interruptedRun(t)
+	// a record cut by a crash is ignored
+	f, err := os.OpenFile(filepath.Join(dir, JournalName), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{0, 0, 1})
+	f.Close()
+
+	if err := RollbackEncryptDirectory(dir, "passphrase"); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if HasJournal(dir) {
+		t.Error("Expected the journal to be removed")
+	}
+	for name, content := range map[string]string{"a.txt": "a", "b.txt": "b", "b.txt.enc": "old", "sub/c.txt": "c"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(data) != content {
+			t.Errorf("Expected %s with %q, got %q (%v)", name, content, data, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.txt.enc")); !os.IsNotExist(err) {
+		t.Error("Expected a.txt.enc to be removed")
+	}
+}
+
+// TestJournal_RollbackPartialWipe tests a run interrupted while wiping a source
+func TestJournal_RollbackPartialWipe(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"a.txt": "secret"})
+	path := filepath.Join(dir, "a.txt")
+
+	j, err := createJournal(dir, "passphrase", &runPlan{Files: []string{"a.txt"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := EncryptFile(path, "passphrase", &Options{Keep: true}); err != nil {
+		t.Fatal(err)
+	}
+	j.record(journalStart, path, path+".enc")
+	j.record(journalWritten, path, "")
+	j.close()
+	os.WriteFile(path, []byte("\x00\x00\x00"), 0600)
+
+	if err := RollbackEncryptDirectory(dir, "passphrase"); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "secret" {
+		t.Errorf("Expected the source to be restored, got %q", data)
+	}
+}
+
+// TestJournal_ResumeNames tests the resume of a run renaming the folders
+func TestJournal_ResumeNames(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"docs/a.txt": "a", "docs/b.txt": "b"})
+	nc, _ := newNameCipher("passphrase")
+	token, _ := nc.encrypt("b.txt")
+	writeTree(t, dir, map[string]string{"docs/" + token + ".enc": "old"})
+
+	if err := EncryptDirectory(dir, "passphrase", &Options{EncryptNames: true}); !errors.Is(err, ErrExists) {
+		t.Fatalf("Expected ErrExists, got: %v", err)
+	}
+	os.Remove(filepath.Join(dir, "docs", token+".enc"))
+	if err := ResumeEncryptDirectory(dir, "passphrase"); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	docs, _ := nc.encrypt("docs")
+	if _, err := os.Stat(filepath.Join(dir, docs, token+".enc")); err != nil {
+		t.Errorf("Expected the encrypted names: %v", err)
+	}
+
+	if err := DecryptDirectory(dir, "passphrase", nil); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "docs", "b.txt")); string(data) != "b" {
+		t.Errorf("Expected docs/b.txt to be restored, got %q", data)
+	}
+}
@@ -0,0 +1,112 @@
+package security
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// A key file is a second factor: the key is derived from the passphrase with
+// scrypt salted with the key file digest, so that the passphrase alone can't
+// decrypt the data and guessing it stays slow for who has the key file. The boxes protected by a
+// key file are marked, a box sealed by EncryptBox with the keyFileMagic prefix
+// and a shared box with the KeyFile flag of its header.
+const (
+	// KeyFileSize is the size of the key files created by GenerateKeyFile
+	KeyFileSize = 64
+	// keyFileMaxSize limits the files read as key file
+	keyFileMaxSize = 1024 * 1024
+)
+
+var (
+	keyFileMagic = []byte("RAPTOR-KEYFILE\x00\x01")
+	// keyFilePrefix starts the keys given by WithKeyFile
+	keyFilePrefix = "keyfile:"
+	// keyFileWorkFactor is the scrypt work factor of WithKeyFile (lowered by
+	// the tests)
+	keyFileWorkFactor = 18
+)
+
+// GenerateKeyFile writes a new random key file readable by the owner only, an
+// existing file is never overwritten
+func GenerateKeyFile(path string) error {
+	key := make([]byte, KeyFileSize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return fmt.Errorf("failed to generate the key file: %v", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0400)
+	if err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("%w: %s", ErrExists, path)
+		}
+		return err
+	}
+	if _, err := f.Write(key); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+// ReadKeyFile returns the digest of the key file. Any file can be used as a key
+// file, up to 1MiB.
+func ReadKeyFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the key file: %v", err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, io.LimitReader(f, keyFileMaxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the key file: %v", err)
+	}
+	if n == 0 || n > keyFileMaxSize {
+		return nil, fmt.Errorf("the key file %s must not be empty and up to 1MiB", path)
+	}
+	return h.Sum(nil), nil
+}
+
+// WithKeyFile returns the key derived from the passphrase and the key file
+// digest, the passphrase itself if there is no digest
+func WithKeyFile(passphrase []byte, digest []byte) ([]byte, error) {
+	if len(digest) == 0 {
+		return passphrase, nil
+	}
+	key, err := scrypt.Key(passphrase, digest, 1<<keyFileWorkFactor, 8, 1, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive the key with the key file: %v", err)
+	}
+	defer clear(key)
+	out := make([]byte, len(keyFilePrefix)+hex.EncodedLen(len(key)))
+	hex.Encode(out[copy(out, keyFilePrefix):], key)
+	return out, nil
+}
+
+// MarkKeyFile marks a box sealed by EncryptBox as protected by a key file
+func MarkKeyFile(data []byte) []byte {
+	return append(append([]byte{}, keyFileMagic...), data...)
+}
+
+// RequiresKeyFile returns true if the box can be opened with a key file only
+func RequiresKeyFile(data []byte) bool {
+	if bytes.HasPrefix(data, keyFileMagic) {
+		return true
+	}
+	if IsSharedBox(data) {
+		k, _, _, err := parseSharedBox(data)
+		return err == nil && k.KeyFile
+	}
+	if IsIndexedBox(data) {
+		k, _, _, err := parseIndexedBox(data)
+		return err == nil && k != nil && k.KeyFile
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package security
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestKeyFile_Box tests that a box sealed with a key file needs both factors
+func TestKeyFile_Box(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "raptor.key")
+	if err := GenerateKeyFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := GenerateKeyFile(path); !errors.Is(err, ErrExists) {
+		t.Errorf("Expected ErrExists, got: %v", err)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0400 {
+		t.Errorf("Expected mode 0400, got %v", info.Mode().Perm())
+	}
+	digest, err := ReadKeyFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := WithKeyFile([]byte("pwd"), digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := EncryptBox([]byte("box"), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc = MarkKeyFile(enc)
+	if !RequiresKeyFile(enc) {
+		t.Error("Expected the box to require the key file")
+	}
+	if _, err := DecryptBox(enc, []byte("pwd")); err == nil {
+		t.Error("Expected an error without the key file, got nil")
+	}
+	if dec, err := DecryptBox(enc, key); err != nil || string(dec) != "box" {
+		t.Errorf("Expected 'box', got %q (%v)", dec, err)
+	}
+
+	k, err := NewKeyring("owner", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k.KeyFile = true
+	shared, _ := SealSharedBox(k, []byte("box"))
+	if !RequiresKeyFile(shared) {
+		t.Error("Expected the shared box to require the key file")
+	}
+}
+
+// TestReadKeyFile_Empty tests that an empty key file is refused
+func TestReadKeyFile_Empty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "empty")
+	os.WriteFile(path, nil, 0600)
+	if _, err := ReadKeyFile(path); err == nil {
+		t.Error("Expected an error, got nil")
+	}
+}
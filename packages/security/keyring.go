@@ -0,0 +1,406 @@
+package security
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"filippo.io/age"
+	"gopkg.in/yaml.v2"
+)
+
+// A shared box is sealed with a random data key. The data key is wrapped for
+// every member and the wrapped keys are stored in the box header:
+//
+//	magic (12 bytes) | header length (4 bytes) | header (YAML Keyring) | nonce | sealed box
+//
+// Every member has an X25519 key pair (age format) and the data key is
+// encrypted for its public key. A passphrase member has its secret key
+// encrypted with the passphrase and stored in the header too, a public key
+// member keeps the secret key for itself. Since only the public keys are needed
+// to wrap a key, the data key can be rotated without knowing the passphrases.
+// The header is authenticated as additional data of the sealed box.
+const (
+	dataKeySize      = 32
+	keyringMaxHeader = 1024 * 1024
+)
+
+var (
+	boxMagic = []byte("RAPTOR-BOX\x00\x01")
+	// keyringWorkFactor is the scrypt work factor protecting the secret keys
+	// of the passphrase members (lowered by the tests)
+	keyringWorkFactor = 18
+	// ErrNotMember is returned when the password or the key doesn't unlock any member
+	ErrNotMember = errors.New("the password or the key doesn't match any member of the box")
+)
+
+// Keyring is the header of a shared box
+type Keyring struct {
+	Members []*Member `yaml:"members"`
+	// KeyFile is true when the passphrase members combine their passphrase
+	// with the key file of the box
+	KeyFile bool `yaml:"keyfile,omitempty"`
+
+	dataKey []byte
+	// unlocked is the name of the member who opened the box
+	unlocked string
+}
+
+// Member is a person who can open a shared box
+type Member struct {
+	Name string `yaml:"name"`
+	// Recipient is the age public key of the member
+	Recipient string `yaml:"recipient"`
+	// Identity is the age secret key encrypted with the passphrase of the
+	// member, empty for a public key member
+	Identity string `yaml:"identity,omitempty"`
+	// Key is the data key encrypted for Recipient
+	Key string `yaml:"key"`
+	// Recovery is true for the member whose secret key is split into the
+	// recovery shares
+	Recovery bool `yaml:"recovery,omitempty"`
+}
+
+// IsPassphrase returns true if the member opens the box with a passphrase
+func (m *Member) IsPassphrase() bool {
+	return len(m.Identity) > 0
+}
+
+// IsSharedBox returns true if the data is a box sealed with a data key
+func IsSharedBox(data []byte) bool {
+	return bytes.HasPrefix(data, boxMagic)
+}
+
+// NewKeyring creates a keyring with a new data key and the first member opening
+// the box with the passphrase
+func NewKeyring(name string, passphrase []byte) (*Keyring, error) {
+	k := &Keyring{dataKey: make([]byte, dataKeySize), unlocked: name}
+	if _, err := io.ReadFull(rand.Reader, k.dataKey); err != nil {
+		return nil, fmt.Errorf("failed to generate the data key: %v", err)
+	}
+	return k, k.AddPassphraseMember(name, passphrase)
+}
+
+// Unlocked returns the name of the member who opened the box
+func (k *Keyring) Unlocked() string {
+	return k.unlocked
+}
+
+// Member returns the member with the name, nil if missing
+func (k *Keyring) Member(name string) *Member {
+	for _, m := range k.Members {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+// AddPassphraseMember adds a member opening the box with the passphrase
+func (k *Keyring) AddPassphraseMember(name string, passphrase []byte) error {
+	m, err := newPassphraseMember(name, passphrase)
+	if err != nil {
+		return err
+	}
+	return k.addMember(m)
+}
+
+// newPassphraseMember generates the key pair of the member and seals the
+// secret key with the passphrase
+func newPassphraseMember(name string, passphrase []byte) (*Member, error) {
+	if len(passphrase) == 0 {
+		return nil, fmt.Errorf("the password can't be empty")
+	}
+	identity, recipient, err := GenerateIdentity()
+	if err != nil {
+		return nil, err
+	}
+	// age takes the passphrase as a string
+	pr, err := age.NewScryptRecipient(string(passphrase))
+	if err != nil {
+		return nil, err
+	}
+	pr.SetWorkFactor(keyringWorkFactor)
+	sealed, err := ageSeal([]byte(identity), pr)
+	if err != nil {
+		return nil, err
+	}
+	return &Member{Name: name, Recipient: recipient, Identity: sealed}, nil
+}
+
+// AddRecipientMember adds a member opening the box with the secret key of the
+// recipient (age public key)
+func (k *Keyring) AddRecipientMember(name, recipient string) error {
+	return k.addMember(&Member{Name: name, Recipient: recipient})
+}
+
+func (k *Keyring) addMember(m *Member) error {
+	if len(m.Name) == 0 {
+		return fmt.Errorf("the member name can't be empty")
+	}
+	if k.Member(m.Name) != nil {
+		return fmt.Errorf("the member %s already exists", m.Name)
+	}
+	if err := k.wrap(m); err != nil {
+		return err
+	}
+	k.Members = append(k.Members, m)
+	return nil
+}
+
+// wrap encrypts the data key for the member
+func (k *Keyring) wrap(m *Member) error {
+	r, err := age.ParseX25519Recipient(m.Recipient)
+	if err != nil {
+		return fmt.Errorf("invalid recipient %q: %v", m.Recipient, err)
+	}
+	m.Key, err = ageSeal(k.dataKey, r)
+	return err
+}
+
+// RecoveryMember returns the recovery member, nil if the box has no recovery
+func (k *Keyring) RecoveryMember() *Member {
+	for _, m := range k.Members {
+		if m.Recovery {
+			return m
+		}
+	}
+	return nil
+}
+
+// SetRecovery adds a recovery member and returns its secret key to be split.
+// A previous recovery member is removed rotating the data key, so that its
+// shares can't open the new versions of the box anymore.
+func (k *Keyring) SetRecovery(name string) (string, error) {
+	if old := k.RecoveryMember(); old != nil {
+		if err := k.RemoveMember(old.Name); err != nil {
+			return "", err
+		}
+	}
+	identity, recipient, err := GenerateIdentity()
+	if err != nil {
+		return "", err
+	}
+	if err := k.addMember(&Member{Name: name, Recipient: recipient, Recovery: true}); err != nil {
+		return "", err
+	}
+	return identity, nil
+}
+
+// ResetPassphrase replaces the passphrase of the member, or adds the member
+// if missing. The box must be unlocked.
+func (k *Keyring) ResetPassphrase(name string, passphrase []byte) error {
+	for i, m := range k.Members {
+		if m.Name != name {
+			continue
+		}
+		if !m.IsPassphrase() {
+			return fmt.Errorf("the member %s doesn't open the box with a password", name)
+		}
+		reset, err := newPassphraseMember(name, passphrase)
+		if err != nil {
+			return err
+		}
+		if err := k.wrap(reset); err != nil {
+			return err
+		}
+		k.Members[i] = reset
+		return nil
+	}
+	return k.AddPassphraseMember(name, passphrase)
+}
+
+// RemoveMember removes the member and rotates the data key: the new key is
+// wrapped for the remaining members only
+func (k *Keyring) RemoveMember(name string) error {
+	members := make([]*Member, 0, len(k.Members))
+	for _, m := range k.Members {
+		if m.Name != name {
+			members = append(members, m)
+		}
+	}
+	if len(members) == len(k.Members) {
+		return fmt.Errorf("the member %s doesn't exist", name)
+	}
+	if len(members) == 0 {
+		return fmt.Errorf("the last member of the box can't be removed")
+	}
+	return k.rotate(members)
+}
+
+// rotate generates a new data key and wraps it for the members
+func (k *Keyring) rotate(members []*Member) error {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return fmt.Errorf("failed to generate the data key: %v", err)
+	}
+	rotated := &Keyring{dataKey: dataKey}
+	for _, m := range members {
+		c := *m
+		if err := rotated.wrap(&c); err != nil {
+			return err
+		}
+		rotated.Members = append(rotated.Members, &c)
+	}
+	k.Members, k.dataKey = rotated.Members, dataKey
+	return nil
+}
+
+// unlock finds the data key with the passphrase or the age secret key
+func (k *Keyring) unlock(secret []byte) error {
+	var identity age.Identity
+	if bytes.HasPrefix(secret, []byte(AgeIdentityPrefix)) {
+		id, err := age.ParseX25519Identity(string(bytes.TrimSpace(secret)))
+		if err != nil {
+			return fmt.Errorf("invalid identity: %v", err)
+		}
+		identity = id
+	}
+	for _, m := range k.Members {
+		id := identity
+		if id == nil {
+			if !m.IsPassphrase() {
+				continue
+			}
+			var err error
+			if id, err = openIdentity(m.Identity, secret); err != nil {
+				continue
+			}
+		}
+		dataKey, err := ageOpen(m.Key, id)
+		if err != nil {
+			continue
+		}
+		k.dataKey, k.unlocked = dataKey, m.Name
+		return nil
+	}
+	return ErrNotMember
+}
+
+// openIdentity decrypts the secret key of a passphrase member
+func openIdentity(sealed string, passphrase []byte) (age.Identity, error) {
+	si, err := age.NewScryptIdentity(string(passphrase))
+	if err != nil {
+		return nil, err
+	}
+	secret, err := ageOpen(sealed, si)
+	if err != nil {
+		return nil, err
+	}
+	return age.ParseX25519Identity(string(secret))
+}
+
+// OpenSharedBox returns the keyring and the plaintext of the shared box. The
+// secret is the passphrase or the age secret key of a member.
+func OpenSharedBox(data []byte, secret []byte) (*Keyring, []byte, error) {
+	k, header, sealed, err := parseSharedBox(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := k.unlock(secret); err != nil {
+		return nil, nil, err
+	}
+	gcm, err := newDataGCM(k.dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, nil, ErrTruncated
+	}
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], header)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decrypt data: %v", err)
+	}
+	return k, plaintext, nil
+}
+
+// parseSharedBox returns the locked keyring, the header and the sealed box
+func parseSharedBox(data []byte) (*Keyring, []byte, []byte, error) {
+	if !IsSharedBox(data) {
+		return nil, nil, nil, fmt.Errorf("the box is not a shared box")
+	}
+	rest := data[len(boxMagic):]
+	if len(rest) < 4 {
+		return nil, nil, nil, ErrTruncated
+	}
+	n := binary.BigEndian.Uint32(rest)
+	rest = rest[4:]
+	if n > keyringMaxHeader || int(n) > len(rest) {
+		return nil, nil, nil, fmt.Errorf("invalid header size %d", n)
+	}
+	header, sealed := rest[:n], rest[n:]
+
+	k := &Keyring{}
+	if err := yaml.Unmarshal(header, k); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid header: %v", err)
+	}
+	return k, header, sealed, nil
+}
+
+// SealSharedBox encrypts the plaintext with the data key of the keyring
+func SealSharedBox(k *Keyring, plaintext []byte) ([]byte, error) {
+	if len(k.dataKey) == 0 {
+		return nil, fmt.Errorf("the keyring is locked")
+	}
+	header, err := yaml.Marshal(k)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newDataGCM(k.dataKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %v", err)
+	}
+
+	out := append([]byte{}, boxMagic...)
+	out = binary.BigEndian.AppendUint32(out, uint32(len(header)))
+	out = append(out, header...)
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, plaintext, header), nil
+}
+
+func newDataGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %v", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// ageSeal encrypts data for the recipient and returns it base64 encoded
+func ageSeal(data []byte, r age.Recipient) (string, error) {
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, r)
+	if err != nil {
+		return "", err
+	}
+	if _, err := w.Write(data); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// ageOpen decrypts the base64 data encrypted by ageSeal
+func ageOpen(sealed string, id age.Identity) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return nil, err
+	}
+	r, err := age.Decrypt(bytes.NewReader(data), id)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
@@ -0,0 +1,128 @@
+package security
+
+import (
+	"errors"
+	"testing"
+)
+
+func init() {
+	keyringWorkFactor = 10
+	keyFileWorkFactor = 10
+}
+
+// TestKeyring_Members tests that every member opens the box and a removed
+// member can't open the new versions
+func TestKeyring_Members(t *testing.T) {
+	identity, recipient, err := GenerateIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := NewKeyring("owner", []byte("owner-pwd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := k.AddPassphraseMember("alice", []byte("alice-pwd")); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.AddRecipientMember("bob", recipient); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.AddRecipientMember("bob", recipient); err == nil {
+		t.Error("Expected an error adding a member twice, got nil")
+	}
+	sealed, err := SealSharedBox(k, []byte("secrets"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for member, secret := range map[string]string{"owner": "owner-pwd", "alice": "alice-pwd", "bob": identity} {
+		opened, plaintext, err := OpenSharedBox(sealed, []byte(secret))
+		if err != nil {
+			t.Fatalf("%s: expected no error, got: %v", member, err)
+		}
+		if string(plaintext) != "secrets" || opened.Unlocked() != member {
+			t.Errorf("%s: got %q unlocked by %s", member, plaintext, opened.Unlocked())
+		}
+	}
+	if _, _, err := OpenSharedBox(sealed, []byte("wrong")); !errors.Is(err, ErrNotMember) {
+		t.Errorf("Expected ErrNotMember, got: %v", err)
+	}
+
+	// alice removes bob without knowing the owner password
+	opened, _, _ := OpenSharedBox(sealed, []byte("alice-pwd"))
+	if err := opened.RemoveMember("bob"); err != nil {
+		t.Fatal(err)
+	}
+	rotated, err := SealSharedBox(opened, []byte("new secrets"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := OpenSharedBox(rotated, []byte(identity)); !errors.Is(err, ErrNotMember) {
+		t.Errorf("Expected the removed member to be refused, got: %v", err)
+	}
+	if _, plaintext, err := OpenSharedBox(rotated, []byte("owner-pwd")); err != nil || string(plaintext) != "new secrets" {
+		t.Errorf("Expected the owner to open the rotated box, got %q (%v)", plaintext, err)
+	}
+	if err := opened.RemoveMember("owner"); err != nil {
+		t.Fatal(err)
+	}
+	if err := opened.RemoveMember("alice"); err == nil {
+		t.Error("Expected an error removing the last member, got nil")
+	}
+}
+
+// TestOpenSharedBox_TamperedHeader tests that the header is authenticated
+func TestOpenSharedBox_TamperedHeader(t *testing.T) {
+	k, err := NewKeyring("owner", []byte("pwd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, _ := SealSharedBox(k, []byte("secrets"))
+	_, recipient, _ := GenerateIdentity()
+	k.Members[0].Recipient = recipient
+	tamperedHeader, _ := SealSharedBox(k, nil)
+	// the header of the second box with the ciphertext of the first one
+	headerLen := len(tamperedHeader) - 12 - 16
+	tampered := append(append([]byte{}, tamperedHeader[:headerLen]...), sealed[headerLen:]...)
+	if _, _, err := OpenSharedBox(tampered, []byte("pwd")); err == nil {
+		t.Error("Expected an error, got nil")
+	}
+}
+
+// TestKeyring_Recovery tests that the recovery key opens the box and the
+// password can be reset
+func TestKeyring_Recovery(t *testing.T) {
+	k, err := NewKeyring("owner", []byte("forgotten"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	identity, err := k.SetRecovery("recovery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, _ := SealSharedBox(k, []byte("secrets"))
+
+	opened, _, err := OpenSharedBox(sealed, []byte(identity))
+	if err != nil || opened.Unlocked() != "recovery" {
+		t.Fatalf("Expected the recovery key to open the box, got: %v", err)
+	}
+	if err := opened.ResetPassphrase("owner", []byte("new-pwd")); err != nil {
+		t.Fatal(err)
+	}
+	reset, _ := SealSharedBox(opened, []byte("secrets"))
+	if _, _, err := OpenSharedBox(reset, []byte("forgotten")); !errors.Is(err, ErrNotMember) {
+		t.Errorf("Expected the old password to be refused, got: %v", err)
+	}
+	if _, _, err := OpenSharedBox(reset, []byte("new-pwd")); err != nil {
+		t.Errorf("Expected the new password to open the box, got: %v", err)
+	}
+
+	// a new recovery key replaces the old one
+	if _, err := opened.SetRecovery("recovery"); err != nil {
+		t.Fatal(err)
+	}
+	resplit, _ := SealSharedBox(opened, []byte("secrets"))
+	if _, _, err := OpenSharedBox(resplit, []byte(identity)); !errors.Is(err, ErrNotMember) {
+		t.Errorf("Expected the old recovery key to be refused, got: %v", err)
+	}
+}
@@ -0,0 +1,360 @@
+package security
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mas2020-golang/goutils/output"
+	"gopkg.in/yaml.v2"
+)
+
+// The manifest lists the files written by EncryptDirectory with their size and
+// the MAC of their encrypted content, so that deleted, added, swapped or rolled
+// back files are detected: the encryption authenticates the content of a file,
+// not its place in the tree. It is sealed with the passphrase in the encrypted
+// folder:
+//
+//	magic | salt | nonce | AES-GCM(YAML manifest)
+//
+// The MACs are keyed from the passphrase only, so that the entries of a
+// manifest can be kept by the next runs.
+const ManifestName = ".raptor-manifest"
+
+var manifestMagic = []byte("RAPTOR-MANIFEST\x00\x01")
+
+// ErrManifest is returned when the folder doesn't match its manifest
+var ErrManifest = errors.New("the folder doesn't match its manifest")
+
+type manifestEntry struct {
+	Path string `yaml:"path"`
+	Size int64  `yaml:"size"`
+	MAC  string `yaml:"mac"`
+}
+
+type manifest struct {
+	// Created is the unix time in nanoseconds of the last update
+	Created int64            `yaml:"created"`
+	Files   []*manifestEntry `yaml:"files"`
+
+	root   string
+	macKey []byte
+}
+
+// VerifyReport is the result of the verification of a folder against its
+// manifest, the paths are relative to the folder
+type VerifyReport struct {
+	// Created is the time the manifest was written
+	Created time.Time
+	// Files is the number of files in the manifest
+	Files int
+	// Missing are the files of the manifest not found
+	Missing []string
+	// Extra are the encrypted files not in the manifest
+	Extra []string
+	// Swapped are the files with the content of another file of the manifest
+	Swapped []string
+	// Stale are the files with a content unknown to the manifest: an older
+	// version of the file or a modified one
+	Stale []string
+}
+
+// OK returns true when the folder matches the manifest
+func (r *VerifyReport) OK() bool {
+	return len(r.Missing)+len(r.Extra)+len(r.Swapped)+len(r.Stale) == 0
+}
+
+func (r *VerifyReport) String() string {
+	var parts []string
+	for _, p := range []struct {
+		name  string
+		paths []string
+	}{{"missing", r.Missing}, {"extra", r.Extra}, {"swapped", r.Swapped}, {"stale", r.Stale}} {
+		if len(p.paths) > 0 {
+			parts = append(parts, fmt.Sprintf("%s: %s", p.name, strings.Join(p.paths, ", ")))
+		}
+	}
+	if len(parts) == 0 {
+		return fmt.Sprintf("%d files verified", r.Files)
+	}
+	return strings.Join(parts, "; ")
+}
+
+func manifestMACKey(passphrase string) []byte {
+	key := sha256.Sum256([]byte(passphrase))
+	mac := hmac.New(sha256.New, key[:])
+	mac.Write([]byte("raptor manifest mac"))
+	return mac.Sum(nil)
+}
+
+// HasManifest returns true if the folder has a manifest
+func HasManifest(dirPath string) bool {
+	_, err := os.Lstat(filepath.Join(dirPath, ManifestName))
+	return err == nil
+}
+
+// openManifest reads the manifest of the folder, nil if it has none
+func openManifest(dirPath, passphrase string) (*manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dirPath, ManifestName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read the manifest: %v", err)
+	}
+	if len(data) < len(manifestMagic)+streamSaltSize || string(data[:len(manifestMagic)]) != string(manifestMagic) {
+		return nil, fmt.Errorf("%w: invalid manifest", ErrManifest)
+	}
+	salt := data[len(manifestMagic) : len(manifestMagic)+streamSaltSize]
+	sealed := data[len(manifestMagic)+streamSaltSize:]
+	gcm, err := newStreamGCM(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("%w: invalid manifest", ErrManifest)
+	}
+	plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], manifestMagic)
+	if err != nil {
+		return nil, fmt.Errorf("%w: the manifest can't be authenticated (wrong password or modified)", ErrManifest)
+	}
+	m := &manifest{root: dirPath, macKey: manifestMACKey(passphrase)}
+	if err := yaml.Unmarshal(plain, m); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrManifest, err)
+	}
+	return m, nil
+}
+
+// save writes the manifest sealed with the passphrase, replacing the old one
+// only when the new one is complete
+func (m *manifest) save(passphrase string) error {
+	sort.Slice(m.Files, func(i, j int) bool { return m.Files[i].Path < m.Files[j].Path })
+	m.Created = time.Now().UnixNano()
+	plain, err := yaml.Marshal(m)
+	if err != nil {
+		return err
+	}
+	salt := make([]byte, streamSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return fmt.Errorf("failed to generate salt: %v", err)
+	}
+	gcm, err := newStreamGCM(passphrase, salt)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %v", err)
+	}
+	data := append(append(append([]byte{}, manifestMagic...), salt...), gcm.Seal(nonce, nonce, plain, manifestMagic)...)
+
+	path := filepath.Join(m.root, ManifestName)
+	tmp := path + ".tmp"
+	err = WriteFile(tmp, true, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write the manifest: %v", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// entry computes the manifest entry of the file, rel to the manifest folder
+func (m *manifest) entry(rel string) (*manifestEntry, error) {
+	f, err := os.Open(filepath.Join(m.root, rel))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	mac := hmac.New(sha256.New, m.macKey)
+	size, err := io.Copy(mac, f)
+	if err != nil {
+		return nil, err
+	}
+	return &manifestEntry{Path: filepath.ToSlash(rel), Size: size, MAC: hex.EncodeToString(mac.Sum(nil))}, nil
+}
+
+// update adds (or replaces) the entries of the added files and drops the
+// removed ones, the paths are relative to the manifest folder
+func (m *manifest) update(added, removed []string) error {
+	files := map[string]*manifestEntry{}
+	for _, e := range m.Files {
+		files[e.Path] = e
+	}
+	for _, rel := range removed {
+		delete(files, filepath.ToSlash(rel))
+	}
+	for _, rel := range added {
+		e, err := m.entry(rel)
+		if err != nil {
+			return fmt.Errorf("failed to add %s to the manifest: %v", rel, err)
+		}
+		files[e.Path] = e
+	}
+	m.Files = m.Files[:0]
+	for _, e := range files {
+		m.Files = append(m.Files, e)
+	}
+	return nil
+}
+
+// manifestToUpdate opens the manifest of the folder, a new one when missing.
+// A manifest that can't be opened with the passphrase is replaced only when
+// replace is true.
+func manifestToUpdate(dirPath, passphrase string, replace bool) (*manifest, error) {
+	m, err := openManifest(dirPath, passphrase)
+	if err != nil {
+		if !replace {
+			return nil, fmt.Errorf("%w, use --replace-manifest to write a new one", err)
+		}
+		output.Warning("", fmt.Sprintf("%v: it is replaced", err))
+		m = nil
+	}
+	if m == nil {
+		m = &manifest{root: dirPath, macKey: manifestMACKey(passphrase)}
+	}
+	return m, nil
+}
+
+// updateManifest adds the files to the manifest of the folder, creating it
+// when missing
+func updateManifest(dirPath, passphrase string, added []string, replace bool) error {
+	m, err := manifestToUpdate(dirPath, passphrase, replace)
+	if err != nil {
+		return err
+	}
+	if err := m.update(added, nil); err != nil {
+		return err
+	}
+	return m.save(passphrase)
+}
+
+// hasEncryptedFiles returns true if the folder has .enc files, the ones a
+// manifest lists
+func hasEncryptedFiles(dirPath string) (bool, error) {
+	found := false
+	err := filepath.WalkDir(dirPath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() && strings.HasSuffix(p, ".enc") {
+			found = true
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return found, err
+}
+
+// verify compares the encrypted files of the folder with the manifest
+func (m *manifest) verify() (*VerifyReport, error) {
+	r := &VerifyReport{Created: time.Unix(0, m.Created), Files: len(m.Files)}
+	files := map[string]*manifestEntry{}
+	macs := map[string]string{}
+	for _, e := range m.Files {
+		files[e.Path] = e
+		macs[e.MAC] = e.Path
+	}
+
+	found := map[string]bool{}
+	err := filepath.Walk(m.root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() || !strings.HasSuffix(p, ".enc") {
+			return nil
+		}
+		rel, err := filepath.Rel(m.root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		want, ok := files[rel]
+		if !ok {
+			r.Extra = append(r.Extra, rel)
+			return nil
+		}
+		found[rel] = true
+		got, err := m.entry(rel)
+		if err != nil {
+			return err
+		}
+		switch {
+		case got.Size == want.Size && hmac.Equal([]byte(got.MAC), []byte(want.MAC)):
+		case macs[got.MAC] != "":
+			r.Swapped = append(r.Swapped, rel)
+		default:
+			r.Stale = append(r.Stale, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range m.Files {
+		if !found[e.Path] {
+			r.Missing = append(r.Missing, e.Path)
+		}
+	}
+	return r, nil
+}
+
+// VerifyDirectory checks the encrypted files of the folder against its manifest
+func VerifyDirectory(dirPath, passphrase string) (*VerifyReport, error) {
+	m, err := openManifest(dirPath, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, fmt.Errorf("no manifest found in %s", dirPath)
+	}
+	return m.verify()
+}
+
+// runOutputs returns the encrypted files written by the run, relative to the
+// folder where the manifest is written
+func runOutputs(j *journal, opts *Options) (string, []string, error) {
+	root := j.root
+	if len(opts.OutputDir) > 0 {
+		root = opts.OutputDir
+	}
+	var outputs []string
+	for _, dst := range j.dsts {
+		rel, err := filepath.Rel(root, j.abs(dst))
+		if err != nil {
+			return "", nil, err
+		}
+		if opts.names != nil && len(opts.OutputDir) == 0 {
+			// the folders have been renamed after their files
+			dir, err := opts.renameRel(filepath.Dir(rel))
+			if err != nil {
+				return "", nil, err
+			}
+			rel = filepath.Join(dir, filepath.Base(rel))
+		}
+		if _, err := os.Lstat(filepath.Join(root, rel)); err == nil {
+			outputs = append(outputs, rel)
+		}
+	}
+	return root, outputs, nil
+}
+
+// writeRunManifest adds the files encrypted by the run to the manifest
+func writeRunManifest(j *journal, passphrase string, opts *Options) error {
+	root, outputs, err := runOutputs(j, opts)
+	if err != nil {
+		return err
+	}
+	return updateManifest(root, passphrase, outputs, opts.ReplaceManifest)
+}
@@ -0,0 +1,130 @@
+package security
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestManifest_Verify tests the detection of missing, extra, swapped and stale files
+func TestManifest_Verify(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"a.txt": "a", "b.txt": "b", "sub/c.txt": "c", "d.txt": "d"})
+	if err := EncryptDirectory(dir, "passphrase", &Options{Wipe: WipeNone}); err != nil {
+		t.Fatal(err)
+	}
+	report, err := VerifyDirectory(dir, "passphrase")
+	if err != nil || !report.OK() || report.Files != 4 {
+		t.Fatalf("Expected 4 files verified, got %v (%v)", report, err)
+	}
+	if _, err := VerifyDirectory(dir, "wrong"); !errors.Is(err, ErrManifest) {
+		t.Errorf("Expected ErrManifest with the wrong passphrase, got: %v", err)
+	}
+
+	// an older version of d.txt
+	old := filepath.Join(t.TempDir(), "d.txt")
+	os.WriteFile(old, []byte("old d"), 0600)
+	if err := EncryptFile(old, "passphrase", nil); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(old + ".enc")
+	os.WriteFile(filepath.Join(dir, "d.txt.enc"), data, 0600)
+	data, _ = os.ReadFile(filepath.Join(dir, "b.txt.enc"))
+	os.WriteFile(filepath.Join(dir, "a.txt.enc"), data, 0600)
+	os.Remove(filepath.Join(dir, "sub", "c.txt.enc"))
+	os.WriteFile(filepath.Join(dir, "e.txt.enc"), data, 0600)
+
+	report, err = VerifyDirectory(dir, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &VerifyReport{Created: report.Created, Files: 4, Missing: []string{"sub/c.txt.enc"},
+		Extra: []string{"e.txt.enc"}, Swapped: []string{"a.txt.enc"}, Stale: []string{"d.txt.enc"}}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("got %+v, expected %+v", report, want)
+	}
+	if err := DecryptDirectory(dir, "passphrase", nil); !errors.Is(err, ErrManifest) {
+		t.Errorf("Expected ErrManifest, got: %v", err)
+	}
+}
+
+// TestManifest_Names tests the manifest of a folder encrypted with the names,
+// the decryption removes it
+func TestManifest_Names(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{"docs/a.txt": "a", "b.txt": "b"}
+	writeTree(t, dir, files)
+	if err := EncryptDirectory(dir, "passphrase", &Options{EncryptNames: true}); err != nil {
+		t.Fatal(err)
+	}
+	if report, err := VerifyDirectory(dir, "passphrase"); err != nil || !report.OK() || report.Files != 2 {
+		t.Fatalf("Expected 2 files verified, got %v (%v)", report, err)
+	}
+
+	// a new file encrypted later is added to the manifest
+	writeTree(t, dir, map[string]string{"c.txt": "c"})
+	if err := EncryptDirectory(dir, "passphrase", &Options{EncryptNames: true}); err != nil {
+		t.Fatal(err)
+	}
+	if report, err := VerifyDirectory(dir, "passphrase"); err != nil || !report.OK() || report.Files != 3 {
+		t.Fatalf("Expected 3 files verified, got %v (%v)", report, err)
+	}
+
+	if err := DecryptDirectory(dir, "passphrase", nil); err != nil {
+		t.Fatal(err)
+	}
+	if HasManifest(dir) {
+		t.Error("Expected the manifest to be removed")
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "docs", "a.txt")); string(data) != "a" {
+		t.Errorf("Expected docs/a.txt to be restored, got %q", data)
+	}
+}
+
+// TestManifest_Missing tests that a folder with encrypted files and no manifest
+// is decrypted only with IgnoreManifest
+func TestManifest_Missing(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"a.txt": "a"})
+	if err := EncryptDirectory(dir, "passphrase", &Options{Wipe: WipeNone}); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(filepath.Join(dir, ManifestName))
+	if err := DecryptDirectory(dir, "passphrase", nil); !errors.Is(err, ErrManifest) {
+		t.Errorf("Expected ErrManifest, got: %v", err)
+	}
+	if err := DecryptDirectory(dir, "passphrase", &Options{IgnoreManifest: true}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "a.txt")); string(data) != "a" {
+		t.Errorf("Expected a.txt to be restored, got %q", data)
+	}
+}
+
+// TestManifest_Replace tests that a manifest written with another passphrase
+// is replaced only with ReplaceManifest
+func TestManifest_Replace(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"a.txt": "a"})
+	if err := EncryptDirectory(dir, "other", &Options{Wipe: WipeNone}); err != nil {
+		t.Fatal(err)
+	}
+	writeTree(t, dir, map[string]string{"b.txt": "b"})
+	if err := EncryptDirectory(dir, "passphrase", &Options{Wipe: WipeNone}); !errors.Is(err, ErrManifest) {
+		t.Errorf("Expected ErrManifest, got: %v", err)
+	}
+	if HasJournal(dir) {
+		t.Error("Expected the run to be refused before starting")
+	}
+	if _, err := VerifyDirectory(dir, "other"); err != nil {
+		t.Errorf("Expected the manifest to be kept, got: %v", err)
+	}
+	if err := EncryptDirectory(dir, "passphrase", &Options{Wipe: WipeNone, ReplaceManifest: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyDirectory(dir, "passphrase"); err != nil {
+		t.Errorf("Expected the new manifest, got: %v", err)
+	}
+}
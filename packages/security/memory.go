@@ -0,0 +1,82 @@
+package security
+
+import (
+	"runtime"
+	"sync"
+)
+
+// LockedBuffer holds a key or decrypted data outside of the Go heap: where the
+// platform allows it the memory is locked (never written to the swap) and
+// excluded from the core dumps. Destroy zeroes it, a buffer is never reused.
+type LockedBuffer struct {
+	mu sync.Mutex
+	// mem is the whole allocation, data the part in use
+	mem, data []byte
+	locked    bool
+}
+
+// NewLockedBuffer returns a zeroed buffer of the given size. The memory is
+// still usable when it can't be locked (e.g. over RLIMIT_MEMLOCK), Locked
+// tells it.
+func NewLockedBuffer(size int) (*LockedBuffer, error) {
+	mem, err := allocLocked(max(size, 1))
+	if err != nil {
+		return nil, err
+	}
+	b := &LockedBuffer{mem: mem, data: mem[:size]}
+	b.locked = lockMemory(mem) == nil
+	excludeFromCore(mem)
+	// the memory is not collected by the GC, it is released when the buffer
+	// is forgotten without Destroy
+	runtime.SetFinalizer(b, (*LockedBuffer).Destroy)
+	return b, nil
+}
+
+// NewLockedBufferFrom moves the data into a new buffer, data is zeroed
+func NewLockedBufferFrom(data []byte) (*LockedBuffer, error) {
+	b, err := NewLockedBuffer(len(data))
+	if err != nil {
+		clear(data)
+		return nil, err
+	}
+	copy(b.data, data)
+	clear(data)
+	return b, nil
+}
+
+// Bytes returns the content of the buffer, nil after Destroy. The slice must
+// not be used after Destroy and the buffer must stay reachable while the slice
+// is used (runtime.KeepAlive): the finalizer of a buffer no longer referenced
+// releases the memory under the slice.
+func (b *LockedBuffer) Bytes() []byte {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.data
+}
+
+// Locked is true when the memory of the buffer can't be swapped
+func (b *LockedBuffer) Locked() bool {
+	return b != nil && b.locked
+}
+
+// Destroy zeroes and releases the memory, it can be called more than once
+func (b *LockedBuffer) Destroy() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.mem == nil {
+		return
+	}
+	clear(b.mem)
+	if b.locked {
+		unlockMemory(b.mem)
+	}
+	freeLocked(b.mem)
+	b.mem, b.data, b.locked = nil, nil, false
+	runtime.SetFinalizer(b, nil)
+}
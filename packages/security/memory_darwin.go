@@ -0,0 +1,17 @@
+package security
+
+import (
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+var noCoreDumps sync.Once
+
+// excludeFromCore disables the core dumps of the process: macOS can't leave
+// single pages out of them
+func excludeFromCore(mem []byte) {
+	noCoreDumps.Do(func() {
+		unix.Setrlimit(unix.RLIMIT_CORE, &unix.Rlimit{})
+	})
+}
@@ -0,0 +1,8 @@
+package security
+
+import "golang.org/x/sys/unix"
+
+// excludeFromCore leaves the pages out of the core dumps
+func excludeFromCore(mem []byte) {
+	unix.Madvise(mem, unix.MADV_DONTDUMP)
+}
@@ -0,0 +1,21 @@
+//go:build !linux && !darwin
+
+package security
+
+import "errors"
+
+// allocLocked can't lock the memory on this platform, the buffer is still
+// zeroed by Destroy
+func allocLocked(size int) ([]byte, error) {
+	return make([]byte, size), nil
+}
+
+func freeLocked(mem []byte) {}
+
+func lockMemory(mem []byte) error {
+	return errors.New("locked memory not supported")
+}
+
+func unlockMemory(mem []byte) {}
+
+func excludeFromCore(mem []byte) {}
@@ -0,0 +1,38 @@
+package security
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestLockedBuffer tests that the data is moved into the buffer and zeroed
+// by Destroy
+func TestLockedBuffer(t *testing.T) {
+	src := []byte("my secret password")
+	b, err := NewLockedBufferFrom(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(src, make([]byte, len(src))) {
+		t.Error("Expected the source to be zeroed")
+	}
+	if got := string(b.Bytes()); got != "my secret password" {
+		t.Errorf("Expected the password in the buffer, got %q", got)
+	}
+	t.Logf("memory locked: %v", b.Locked())
+
+	b.Destroy()
+	if b.Bytes() != nil || b.Locked() {
+		t.Error("Expected no data after Destroy")
+	}
+	b.Destroy()
+
+	empty, err := NewLockedBuffer(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(empty.Bytes()) != 0 {
+		t.Errorf("Expected an empty buffer, got %d bytes", len(empty.Bytes()))
+	}
+	empty.Destroy()
+}
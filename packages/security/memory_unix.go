@@ -0,0 +1,30 @@
+//go:build linux || darwin
+
+package security
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// allocLocked maps anonymous pages, out of the Go heap
+func allocLocked(size int) ([]byte, error) {
+	mem, err := unix.Mmap(-1, 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_ANON|unix.MAP_PRIVATE)
+	if err != nil {
+		return nil, fmt.Errorf("allocating the locked memory: %v", err)
+	}
+	return mem, nil
+}
+
+func freeLocked(mem []byte) {
+	unix.Munmap(mem)
+}
+
+func lockMemory(mem []byte) error {
+	return unix.Mlock(mem)
+}
+
+func unlockMemory(mem []byte) {
+	unix.Munlock(mem)
+}
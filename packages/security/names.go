@@ -0,0 +1,134 @@
+package security
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base32"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The names of the files and folders are encrypted into deterministic tokens,
+// so that a sync tool sees the same name for the same file across runs. The
+// nonce is derived from the name itself (SIV construction): the same name
+// always gives the same token and a modified token fails the authentication.
+//
+//	token = base32(nonce (12 bytes) | AES-GCM(name))
+//
+// base32 is used as some file systems are not case sensitive.
+const maxNameToken = 251 // 255 minus the .enc extension
+
+var nameEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// nameAD binds the ciphertext to its use
+var nameAD = []byte("raptor name")
+
+// nameCipher encrypts and decrypts the names
+type nameCipher struct {
+	gcm    cipher.AEAD
+	macKey []byte
+}
+
+func newNameCipher(passphrase string) (*nameCipher, error) {
+	key := sha256.Sum256([]byte(passphrase))
+	derive := func(label string) []byte {
+		mac := hmac.New(sha256.New, key[:])
+		mac.Write([]byte(label))
+		return mac.Sum(nil)
+	}
+	block, err := aes.NewCipher(derive("raptor names encryption"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %v", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &nameCipher{gcm: gcm, macKey: derive("raptor names nonce")}, nil
+}
+
+func (c *nameCipher) nonce(name string) []byte {
+	mac := hmac.New(sha256.New, c.macKey)
+	mac.Write([]byte(name))
+	return mac.Sum(nil)[:c.gcm.NonceSize()]
+}
+
+// encrypt returns the token of the name, a name that is already a token is
+// kept so that a folder can be encrypted again after adding files
+func (c *nameCipher) encrypt(name string) (string, error) {
+	if plain, _ := c.decrypt(name); plain != name {
+		return name, nil
+	}
+	nonce := c.nonce(name)
+	token := nameEncoding.EncodeToString(c.gcm.Seal(nonce, nonce, []byte(name), nameAD))
+	if len(token) > maxNameToken {
+		return "", fmt.Errorf("the name %s is too long to be encrypted", name)
+	}
+	return token, nil
+}
+
+// decrypt returns the name of the token, or the token itself when it is not
+// a valid token for this passphrase
+func (c *nameCipher) decrypt(token string) (string, error) {
+	data, err := nameEncoding.DecodeString(token)
+	if err != nil || len(data) < c.gcm.NonceSize()+c.gcm.Overhead() {
+		return token, nil
+	}
+	nonce := data[:c.gcm.NonceSize()]
+	name, err := c.gcm.Open(nil, nonce, data[len(nonce):], nameAD)
+	if err != nil || !hmac.Equal(nonce, c.nonce(string(name))) || !isPlainName(string(name)) {
+		return token, nil
+	}
+	return string(name), nil
+}
+
+// rename returns the name to give to the destination of the path element
+func (o *Options) rename(name string) (string, error) {
+	if o == nil || o.names == nil {
+		return name, nil
+	}
+	return o.names(name)
+}
+
+// renameRel renames every element of the relative path
+func (o *Options) renameRel(rel string) (string, error) {
+	if o == nil || o.names == nil || rel == "." {
+		return rel, nil
+	}
+	parts := strings.Split(rel, string(filepath.Separator))
+	for i, p := range parts {
+		var err error
+		if parts[i], err = o.names(p); err != nil {
+			return "", err
+		}
+	}
+	return filepath.Join(parts...), nil
+}
+
+// renameDirs renames the folders in place, the children before their parent
+func (o *Options) renameDirs(dirs []string) error {
+	for i := len(dirs) - 1; i >= 0; i-- {
+		name, err := o.rename(filepath.Base(dirs[i]))
+		if err != nil {
+			return err
+		}
+		if name == filepath.Base(dirs[i]) {
+			continue
+		}
+		dst := filepath.Join(filepath.Dir(dirs[i]), name)
+		if _, err := os.Lstat(dst); err == nil {
+			return fmt.Errorf("%w: %s", ErrExists, dst)
+		}
+		if err := os.Rename(dirs[i], dst); err != nil {
+			return err
+		}
+		if err := o.record(journalRenamed, dirs[i], dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+//go:build !windows
+
+package security
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileOwner returns the uid and the gid of the file
+func fileOwner(info os.FileInfo) (int, int, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return int(st.Uid), int(st.Gid), true
+}
+
+// restoreOwner changes the ownership of the path, only root can do it so
+// nothing is done for the other users
+func restoreOwner(path string, uid, gid int) error {
+	if os.Geteuid() != 0 {
+		return nil
+	}
+	return os.Lchown(path, uid, gid)
+}
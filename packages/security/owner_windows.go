@@ -0,0 +1,15 @@
+//go:build windows
+
+package security
+
+import "os"
+
+// fileOwner is not supported on Windows
+func fileOwner(info os.FileInfo) (int, int, bool) {
+	return 0, 0, false
+}
+
+// restoreOwner is not supported on Windows
+func restoreOwner(path string, uid, gid int) error {
+	return nil
+}
@@ -1,6 +1,7 @@
 package security
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
@@ -8,14 +9,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"log/slog"
 
-	wipe "github.com/0x9ef/go-wiper/wipe"
 	"github.com/mas2020-golang/goutils/output"
 )
 
@@ -23,12 +22,14 @@ var ErrInvalidFile = errors.New("invalid file type for encryption or decryption"
 
 // encryptBox encrypts the in []byte and return the encrypted
 // out []byte or an error
-func EncryptBox(in []byte, key string) ([]byte, error) {
+func EncryptBox(in []byte, key []byte) ([]byte, error) {
 	return encrypt(in, key)
 }
 
-func DecryptBox(in []byte, key string) ([]byte, error) {
-	return decrypt(in, key)
+// DecryptBox decrypts the box encrypted by EncryptBox, the key file marker is
+// ignored (the key must already include the key file digest)
+func DecryptBox(in []byte, key []byte) ([]byte, error) {
+	return decrypt(bytes.TrimPrefix(in, keyFileMagic), key)
 }
 
 // getCypher return the Cipher
@@ -37,9 +38,10 @@ func getCypher(key string) (cipher.Block, error) {
 	return aes.NewCipher(k[:])
 }
 
-func encrypt(data []byte, passphrase string) ([]byte, error) {
+func encrypt(data []byte, passphrase []byte) ([]byte, error) {
 	// Generate a 256-bit key from the passphrase
-	key := sha256.Sum256([]byte(passphrase))
+	key := sha256.Sum256(passphrase)
+	defer clear(key[:])
 
 	// Create a new AES cipher block
 	block, err := aes.NewCipher(key[:])
@@ -64,9 +66,10 @@ func encrypt(data []byte, passphrase string) ([]byte, error) {
 	return ciphertext, nil
 }
 
-func decrypt(ciphertext []byte, passphrase string) ([]byte, error) {
+func decrypt(ciphertext []byte, passphrase []byte) ([]byte, error) {
 	// Generate a 256-bit key from the passphrase
-	key := sha256.Sum256([]byte(passphrase))
+	key := sha256.Sum256(passphrase)
+	defer clear(key[:])
 
 	// Create a new AES cipher block
 	block, err := aes.NewCipher(key[:])
@@ -82,7 +85,7 @@ func decrypt(ciphertext []byte, passphrase string) ([]byte, error) {
 
 	// Ensure the ciphertext length is greater than the nonce size
 	if len(ciphertext) < gcm.NonceSize() {
-		return nil, errors.New("ciphertext too short")
+		return nil, fmt.Errorf("%w: ciphertext too short", ErrFormat)
 	}
 
 	// Extract the nonce and actual ciphertext
@@ -91,116 +94,394 @@ func decrypt(ciphertext []byte, passphrase string) ([]byte, error) {
 	// Decrypt the data using AES-GCM
 	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decrypt data: %v", err)
+		return nil, fmt.Errorf("failed to decrypt data: %w", ErrAuthentication)
 	}
 
 	return plaintext, nil
 }
 
-func EncryptFile(path, passphrase string) error {
+// Options changes where and how the files are encrypted or decrypted. A nil
+// *Options writes the result next to the source and wipes the source.
+type Options struct {
+	// OutputDir is the folder where the results are written mirroring the tree
+	// relative to the encrypted or decrypted folder
+	OutputDir string
+	// Keep doesn't wipe the source files
+	Keep bool
+	// Force overwrites the existing destination files
+	Force bool
+	// Compress gzips the content of an archive before the encryption
+	Compress bool
+	// Symlinks is the policy for the links: walking a folder SymlinkSkip
+	// (default), SymlinkFollow or SymlinkError, extracting an archive
+	// SymlinkSafe (default), SymlinkSkip or SymlinkError
+	Symlinks string
+	// Include and Exclude are the gitignore-style patterns selecting the files
+	// of a folder, see IgnoreFile
+	Include, Exclude []string
+	// MinSize and MaxSize skip the smaller and the bigger files of a folder,
+	// 0 is no limit
+	MinSize, MaxSize int64
+	// EncryptNames replaces the names of the files and folders encrypted by
+	// EncryptDirectory with authenticated tokens. DecryptDirectory always
+	// restores the original names.
+	EncryptNames bool
+	// Recipients are the age public keys the files are encrypted for, the
+	// passphrase is not used when they are given
+	Recipients []string
+	// Identities are the age secret keys used to decrypt the .age files
+	Identities []string
+	// Wipe is the policy wiping the source files: WipeNone, WipeZero,
+	// WipeRandom or WipeDoD (default)
+	Wipe string
+	// IgnoreManifest decrypts a folder not matching its manifest, or without
+	// a manifest
+	IgnoreManifest bool
+	// ReplaceManifest lets EncryptDirectory write a new manifest in place of
+	// one that can't be opened with the passphrase
+	ReplaceManifest bool
+
+	// root is the folder given to EncryptDirectory or DecryptDirectory
+	root string
+	// names encrypts or decrypts the names of the tree elements
+	names func(string) (string, error)
+	// journal records the progress of EncryptDirectory
+	journal *journal
+}
+
+// ErrExists is returned when the destination file already exists and the
+// Force option is not set
+var ErrExists = errors.New("the destination file already exists (use --force to overwrite it)")
+
+// destination returns the path of the output file for the source path
+func (o *Options) destination(path, name string) (string, error) {
+	if o == nil || len(o.OutputDir) == 0 {
+		return filepath.Join(filepath.Dir(path), name), nil
+	}
+	rel := "."
+	if len(o.root) > 0 {
+		var err error
+		if rel, err = filepath.Rel(o.root, filepath.Dir(path)); err != nil {
+			return "", err
+		}
+		if rel, err = o.renameRel(rel); err != nil {
+			return "", err
+		}
+	}
+	dir := filepath.Join(o.OutputDir, rel)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create the output folder %s: %v", dir, err)
+	}
+	return filepath.Join(dir, name), nil
+}
+
+func (o *Options) keep() bool {
+	return o != nil && o.Keep
+}
+
+func (o *Options) force() bool {
+	return o != nil && o.Force
+}
+
+func EncryptFile(path, passphrase string, opts *Options) error {
+	if opts != nil && len(opts.Recipients) > 0 {
+		return encryptAgeFile(path, opts)
+	}
 	// ends with .enc
 	if strings.HasSuffix(path, ".enc") {
 		output.Warning("", fmt.Sprintf("file %s skipped as it is already a .enc file", path))
 		return ErrInvalidFile
 	}
 
-	// Read the file contents
-	data, err := ioutil.ReadFile(path)
+	in, err := os.Open(path)
 	if err != nil {
 		return fmt.Errorf("failed to read file: %v", err)
 	}
-
-	// Encrypt the data
-	encryptedData, err := encrypt(data, passphrase)
+	defer in.Close()
+	stat, err := in.Stat()
 	if err != nil {
-		return fmt.Errorf("failed to encrypt data: %v", err)
+		return fmt.Errorf("failed to read file: %v", err)
 	}
-	slog.Debug(fmt.Sprintf("the file %s has been encrypted", path))
 
 	// Write the encrypted data to a new file with .enc extension
-	encryptedFilePath := path + ".enc"
-	err = ioutil.WriteFile(encryptedFilePath, encryptedData, 0644)
+	name, err := opts.rename(filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	encryptedFilePath, err := opts.destination(path, name+".enc")
+	if err != nil {
+		return err
+	}
+	err = WriteFile(encryptedFilePath, opts.force(), func(out io.Writer) error {
+		if err := opts.record(journalStart, path, encryptedFilePath); err != nil {
+			return err
+		}
+		return encryptStream(in, out, passphrase, NewFileInfo(stat))
+	})
 	if err != nil {
-		return fmt.Errorf("failed to write encrypted file: %v", err)
+		return fmt.Errorf("failed to encrypt %s: %w", path, err)
+	}
+	slog.Debug(fmt.Sprintf("the file %s has been encrypted", path), "output", encryptedFilePath)
+	in.Close()
+	if err := opts.record(journalWritten, path, ""); err != nil {
+		return err
 	}
 
 	// delete the file
-	return deleteFile(path)
+	if !opts.keep() {
+		if err := opts.deleteFile(path); err != nil {
+			return err
+		}
+	}
+	return opts.record(journalDone, path, "")
 }
 
-func DecryptFile(path, passphrase string) error {
+func DecryptFile(path, passphrase string, opts *Options) error {
+	if IsAgeFile(path) {
+		return decryptAgeFile(path, passphrase, opts)
+	}
 	// ends with .enc
 	if !strings.HasSuffix(path, ".enc") {
 		output.Warning("", fmt.Sprintf("file %s skipped as it is not a .enc file", path))
 		return ErrInvalidFile
 	}
 
-	// Read the encrypted file contents
-	encryptedData, err := ioutil.ReadFile(path)
+	in, err := os.Open(path)
 	if err != nil {
 		return fmt.Errorf("failed to read encrypted file: %v", err)
 	}
-
-	// Decrypt the data
-	decryptedData, err := decrypt(encryptedData, passphrase)
+	defer in.Close()
+	dr, err := NewDecryptReader(in, passphrase)
 	if err != nil {
-		return fmt.Errorf("failed to decrypt data: %v", err)
+		return fmt.Errorf("failed to decrypt %s: %w", path, err)
 	}
-	slog.Debug(fmt.Sprintf("the file %s has been decrypted", path))
 
-	// Write the decrypted data to a new file without the .enc extension
-	decryptedFilePath := strings.TrimSuffix(path, ".enc")
-	err = ioutil.WriteFile(decryptedFilePath, decryptedData, 0644)
+	// Write the decrypted data to a new file with the original name, or the
+	// name without the .enc extension
+	name := strings.TrimSuffix(filepath.Base(path), ".enc")
+	if dr.Info != nil && isPlainName(dr.Info.Name) {
+		name = dr.Info.Name
+	} else if name, err = opts.rename(name); err != nil {
+		return err
+	}
+	decryptedFilePath, err := opts.destination(path, name)
 	if err != nil {
-		return fmt.Errorf("failed to write decrypted file: %v", err)
+		return err
+	}
+	err = WriteFile(decryptedFilePath, opts.force(), func(out io.Writer) error {
+		_, err := io.Copy(out, dr)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to decrypt %s: %w", path, err)
+	}
+	if dr.Info != nil {
+		if err := dr.Info.restore(decryptedFilePath); err != nil {
+			output.Warning("", fmt.Sprintf("failed to restore the metadata of %s: %v", decryptedFilePath, err))
+		}
 	}
+	slog.Debug(fmt.Sprintf("the file %s has been decrypted", path), "output", decryptedFilePath)
+	in.Close()
 
 	// delete the .enc file
-	return deleteFile(path)
+	if opts.keep() {
+		return nil
+	}
+	return opts.deleteFile(path)
+}
+
+// isPlainName returns true if the name is a single path element
+func isPlainName(name string) bool {
+	return len(name) > 0 && name != "." && name != ".." &&
+		!strings.ContainsAny(name, `/\`) && filepath.Base(name) == name
+}
+
+// WriteFile creates dst readable and writable by the owner only and fills it
+// using the write function, an existing dst is an ErrExists unless force is
+// true. A partial dst is removed in case of error.
+func WriteFile(dst string, force bool, write func(io.Writer) error) error {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if force {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	out, err := os.OpenFile(dst, flags, 0600)
+	if err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("%w: %s", ErrExists, dst)
+		}
+		return fmt.Errorf("failed to write file: %v", err)
+	}
+	// an overwritten file keeps its mode
+	if force {
+		err = out.Chmod(0600)
+	}
+	if err == nil {
+		err = write(out)
+	}
+	if err == nil {
+		err = out.Close()
+	} else {
+		out.Close()
+	}
+	if err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return nil
 }
 
-func EncryptDirectory(dirPath, passphrase string) error {
-	return filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
+// EncryptDirectory encrypts every file of the tree. The progress is recorded in
+// a journal inside the folder (unless the files are encrypted for age
+// recipients), an interrupted run can be completed by ResumeEncryptDirectory
+// or undone by RollbackEncryptDirectory.
+func EncryptDirectory(dirPath, passphrase string, opts *Options) error {
+	if HasJournal(dirPath) {
+		return fmt.Errorf("%w: %s", ErrUnfinished, dirPath)
+	}
+	var dirOpts Options
+	if opts != nil {
+		dirOpts = *opts
+	}
+	if dirOpts.EncryptNames {
+		nc, err := newNameCipher(passphrase)
 		if err != nil {
 			return err
 		}
-		// Skip directories
-		if info.IsDir() {
-			return nil
-		}
-		err = EncryptFile(path, passphrase)
-		if errors.Is(err, ErrInvalidFile) {
-			return nil
-		}
+		dirOpts.names = nc.encrypt
+	}
+	if len(dirOpts.OutputDir) > 0 {
+		dirOpts.OutputDir, _ = filepath.Abs(dirOpts.OutputDir)
+	}
+	files, dirs, err := collectTree(dirPath, &dirOpts)
+	if err != nil {
 		return err
-	})
+	}
+	if len(dirOpts.Recipients) > 0 {
+		return walkDirectory(dirPath, passphrase, &dirOpts, EncryptFile, files, dirs)
+	}
+	manifestDir := dirPath
+	if len(dirOpts.OutputDir) > 0 {
+		manifestDir = dirOpts.OutputDir
+	}
+	if _, err := manifestToUpdate(manifestDir, passphrase, dirOpts.ReplaceManifest); err != nil {
+		return err
+	}
+
+	plan := &runPlan{OutputDir: dirOpts.OutputDir, Keep: dirOpts.Keep, Force: dirOpts.Force,
+		EncryptNames: dirOpts.EncryptNames, Wipe: dirOpts.Wipe, ReplaceManifest: dirOpts.ReplaceManifest}
+	j := &journal{root: dirPath}
+	for _, f := range files {
+		plan.Files = append(plan.Files, j.rel(f))
+	}
+	for _, d := range dirs {
+		plan.Dirs = append(plan.Dirs, j.rel(d))
+	}
+	if dirOpts.journal, err = createJournal(dirPath, passphrase, plan); err != nil {
+		return err
+	}
+	defer dirOpts.journal.close()
+	if err := walkDirectory(dirPath, passphrase, &dirOpts, EncryptFile, files, dirs); err != nil {
+		return err
+	}
+	if err := writeRunManifest(dirOpts.journal, passphrase, &dirOpts); err != nil {
+		return err
+	}
+	return dirOpts.journal.finish()
 }
 
-func DecryptDirectory(dirPath, passphrase string) error {
-	return filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
+func DecryptDirectory(dirPath, passphrase string, opts *Options) error {
+	if HasJournal(dirPath) {
+		return fmt.Errorf("%w: %s", ErrUnfinished, dirPath)
+	}
+	var dirOpts Options
+	if opts != nil {
+		dirOpts = *opts
+	}
+	nc, err := newNameCipher(passphrase)
+	if err != nil {
+		return err
+	}
+	dirOpts.names = nc.decrypt
+	m, err := openManifest(dirPath, passphrase)
+	if err != nil && !dirOpts.IgnoreManifest {
+		return err
+	}
+	if m == nil && err == nil && !dirOpts.IgnoreManifest {
+		encrypted, err := hasEncryptedFiles(dirPath)
 		if err != nil {
 			return err
 		}
-		// Skip directories
-		if info.IsDir() {
-			return nil
+		if encrypted {
+			return fmt.Errorf("%w: no manifest found in %s, use --ignore-manifest to decrypt it anyway", ErrManifest, dirPath)
 		}
-		err = DecryptFile(path, passphrase)
-		if errors.Is(err, ErrInvalidFile) {
-			return nil
+	}
+	if m != nil && !dirOpts.IgnoreManifest {
+		report, err := m.verify()
+		if err != nil {
+			return err
+		}
+		if !report.OK() {
+			return fmt.Errorf("%w: %s", ErrManifest, report)
 		}
+	}
+	files, dirs, err := collectTree(dirPath, &dirOpts)
+	if err != nil {
 		return err
-	})
-}
+	}
 
-// deleteFile securely deletes the path
-func deleteFile(path string) error {
-	slog.Debug("security.deleteFile()", "path", path)
-	policy := &wipe.Policy{"UsDod5220_22_M", "US Department of Defense DoD 5220.22-M (3 passes)", wipe.RuleUsDod5220_22_M}
-	err := wipe.Wipe(path, policy.Rule)
-	if err != nil {
+	// the decrypted files are removed from the manifest, even when the run
+	// stops on an error
+	var decrypted []string
+	decryptFile := func(path, passphrase string, opts *Options) error {
+		err := DecryptFile(path, passphrase, opts)
+		if err == nil && !opts.keep() {
+			rel, _ := filepath.Rel(dirPath, path)
+			decrypted = append(decrypted, rel)
+		}
+		return err
+	}
+	err = walkDirectory(dirPath, passphrase, &dirOpts, decryptFile, files, dirs)
+	if m == nil || len(decrypted) == 0 {
 		return err
 	}
+	if uerr := m.update(nil, decrypted); uerr != nil {
+		return errors.Join(err, uerr)
+	}
+	if len(m.Files) == 0 {
+		return errors.Join(err, os.Remove(filepath.Join(dirPath, ManifestName)))
+	}
+	return errors.Join(err, m.save(passphrase))
+}
+
+// walkDirectory applies the fn function to every file of the tree. When
+// opts.names is set the folders are renamed too: in place at the end, or while
+// mirroring the tree into the output folder.
+func walkDirectory(dirPath, passphrase string, opts *Options, fn func(string, string, *Options) error, files, dirs []string) error {
+	opts.root = dirPath
+	for _, path := range files {
+		if err := fn(path, passphrase, opts); err != nil && !errors.Is(err, ErrInvalidFile) {
+			return err
+		}
+	}
+	if opts.names == nil || len(opts.OutputDir) > 0 {
+		return nil
+	}
+	return opts.renameDirs(dirs)
+}
 
-	return os.Remove(path)
+// deleteFile securely deletes the path following the wipe policy
+func (o *Options) deleteFile(path string) error {
+	policy := WipeDoD
+	if o != nil && len(o.Wipe) > 0 {
+		policy = o.Wipe
+	}
+	return WipeFile(path, policy)
+}
+
+// record appends the operation on path to the journal of the run, if any
+func (o *Options) record(op, path, dst string) error {
+	if o == nil {
+		return nil
+	}
+	return o.journal.record(op, path, dst)
 }
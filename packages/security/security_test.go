@@ -0,0 +1,140 @@
+package security
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTree creates the files (relative path -> content) under root
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestEncryptDirectory_OutputKeep tests the mirrored output folder keeping the sources
+func TestEncryptDirectory_OutputKeep(t *testing.T) {
+	src, out, restored := t.TempDir(), t.TempDir(), t.TempDir()
+	files := map[string]string{"a.txt": "a", "sub/dir/b.txt": "b"}
+	writeTree(t, src, files)
+
+	if err := EncryptDirectory(src, "passphrase", &Options{OutputDir: out, Keep: true}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	for name := range files {
+		if _, err := os.Stat(filepath.Join(src, name)); err != nil {
+			t.Errorf("Expected the source %s to be kept: %v", name, err)
+		}
+		if _, err := os.Stat(filepath.Join(out, name+".enc")); err != nil {
+			t.Errorf("Expected the encrypted %s in the output folder: %v", name, err)
+		}
+	}
+
+	if err := DecryptDirectory(out, "passphrase", &Options{OutputDir: restored}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	for name, content := range files {
+		data, err := os.ReadFile(filepath.Join(restored, name))
+		if err != nil || string(data) != content {
+			t.Errorf("Expected %s to be restored with %q, got %q (%v)", name, content, data, err)
+		}
+		if _, err := os.Stat(filepath.Join(out, name+".enc")); !os.IsNotExist(err) {
+			t.Errorf("Expected the encrypted %s to be wiped", name)
+		}
+	}
+}
+
+// TestEncryptFile_Exists tests that an existing .enc file is not overwritten without Force
+func TestEncryptFile_Exists(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"a.txt": "new", "a.txt.enc": "old"})
+	path := filepath.Join(dir, "a.txt")
+
+	err := EncryptFile(path, "passphrase", nil)
+	if !errors.Is(err, ErrExists) {
+		t.Fatalf("Expected ErrExists, got: %v", err)
+	}
+	if data, _ := os.ReadFile(path + ".enc"); string(data) != "old" {
+		t.Error("The existing .enc file has been modified")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Error("The source file has been deleted")
+	}
+
+	if err := EncryptFile(path, "passphrase", &Options{Force: true}); err != nil {
+		t.Fatalf("Expected no error with Force, got: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Expected the source file to be wiped")
+	}
+}
+
+// TestEncryptDirectory_Names tests that no name is left in clear and the tree
+// is restored
+func TestEncryptDirectory_Names(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{"salaries-2026.xlsx": "a", "hr/reviews/bob.txt": "b"}
+	writeTree(t, dir, files)
+
+	if err := EncryptDirectory(dir, "passphrase", &Options{EncryptNames: true}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		for _, clear := range []string{"salaries", "hr", "reviews", "bob"} {
+			if strings.Contains(info.Name(), clear) {
+				t.Errorf("Expected no name in clear, found %s", path)
+			}
+		}
+		return nil
+	})
+
+	if err := DecryptDirectory(dir, "passphrase", nil); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	for name, content := range files {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(data) != content {
+			t.Errorf("Expected %s to be restored with %q, got %q (%v)", name, content, data, err)
+		}
+	}
+}
+
+// TestNameCipher tests that the tokens are deterministic and authenticated
+func TestNameCipher(t *testing.T) {
+	c, err := newNameCipher("passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t1, _ := c.encrypt("report.pdf")
+	t2, _ := c.encrypt("report.pdf")
+	if t1 != t2 {
+		t.Errorf("Expected the same token, got %s and %s", t1, t2)
+	}
+	if name, _ := c.decrypt(t1); name != "report.pdf" {
+		t.Errorf("Expected report.pdf, got %s", name)
+	}
+	tampered := "b" + t1[1:]
+	if t1[0] == 'b' {
+		tampered = "c" + t1[1:]
+	}
+	if name, _ := c.decrypt(tampered); name != tampered {
+		t.Errorf("Expected a tampered token to be left as is, got %s", name)
+	}
+	other, _ := newNameCipher("other")
+	if name, _ := other.decrypt(t1); name != t1 {
+		t.Errorf("Expected the token to be left as is with another passphrase, got %s", name)
+	}
+	if _, err := c.encrypt(strings.Repeat("x", 200)); err == nil {
+		t.Error("Expected an error for a long name, got nil")
+	}
+}
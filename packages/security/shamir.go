@@ -0,0 +1,181 @@
+package security
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Shamir's secret sharing over GF(2^8): every byte of the secret is the
+// constant term of a random polynomial of degree threshold-1, share x holds
+// the value of the polynomials in x. Any threshold shares rebuild the secret
+// with the Lagrange interpolation in 0, fewer shares give no information.
+//
+// A share is printed as
+//
+//	RAPTOR-SHARE-<threshold>-<x>-<hex of the values and a 4 bytes checksum>
+const sharePrefix = "RAPTOR-SHARE-"
+
+// ErrInvalidShare is returned when a share is malformed or mistyped
+var ErrInvalidShare = errors.New("invalid share")
+
+var gfExp, gfLog [256]byte
+
+func init() {
+	// 3 is a generator of the multiplicative group with the AES polynomial
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		gfExp[i] = x
+		gfLog[x] = byte(i)
+		x = gfMulSlow(x, 3)
+	}
+	gfExp[255] = gfExp[0]
+}
+
+// gfMulSlow multiplies without the tables, modulo x^8+x^4+x^3+x+1
+func gfMulSlow(a, b byte) byte {
+	var p byte
+	for b > 0 {
+		if b&1 == 1 {
+			p ^= a
+		}
+		carry := a & 0x80
+		a <<= 1
+		if carry != 0 {
+			a ^= 0x1b
+		}
+		b >>= 1
+	}
+	return p
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[(int(gfLog[a])+int(gfLog[b]))%255]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[(int(gfLog[a])-int(gfLog[b])+255)%255]
+}
+
+// Share is a part of a secret split by SplitSecret
+type Share struct {
+	Threshold int
+	X         byte
+	Y         []byte
+}
+
+// SplitSecret splits the secret into n shares, threshold of them rebuild it
+func SplitSecret(secret []byte, n, threshold int) ([]Share, error) {
+	if threshold < 2 || threshold > n || n > 255 {
+		return nil, fmt.Errorf("invalid shares %d and threshold %d: 2 <= threshold <= shares <= 255", n, threshold)
+	}
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("the secret is empty")
+	}
+	shares := make([]Share, n)
+	for i := range shares {
+		shares[i] = Share{Threshold: threshold, X: byte(i + 1), Y: make([]byte, len(secret))}
+	}
+	coeffs := make([]byte, threshold)
+	for b, s := range secret {
+		coeffs[0] = s
+		if _, err := io.ReadFull(rand.Reader, coeffs[1:]); err != nil {
+			return nil, fmt.Errorf("failed to generate the shares: %v", err)
+		}
+		for i := range shares {
+			// Horner's method
+			var y byte
+			for c := threshold - 1; c >= 0; c-- {
+				y = gfMul(y, shares[i].X) ^ coeffs[c]
+			}
+			shares[i].Y[b] = y
+		}
+	}
+	return shares, nil
+}
+
+// CombineShares rebuilds the secret from at least threshold shares
+func CombineShares(shares []Share) ([]byte, error) {
+	if len(shares) == 0 {
+		return nil, fmt.Errorf("no share given")
+	}
+	threshold, size := shares[0].Threshold, len(shares[0].Y)
+	seen := map[byte]bool{}
+	var used []Share
+	for _, s := range shares {
+		if s.Threshold != threshold || len(s.Y) != size || s.X == 0 {
+			return nil, fmt.Errorf("%w: the shares don't belong to the same secret", ErrInvalidShare)
+		}
+		if !seen[s.X] {
+			seen[s.X] = true
+			used = append(used, s)
+		}
+	}
+	if len(used) < threshold {
+		return nil, fmt.Errorf("%d different shares given, %d are needed", len(used), threshold)
+	}
+	used = used[:threshold]
+
+	secret := make([]byte, size)
+	for i, si := range used {
+		// Lagrange basis polynomial of si in 0
+		basis := byte(1)
+		for j, sj := range used {
+			if i != j {
+				basis = gfMul(basis, gfDiv(sj.X, sj.X^si.X))
+			}
+		}
+		for b := range secret {
+			secret[b] ^= gfMul(si.Y[b], basis)
+		}
+	}
+	return secret, nil
+}
+
+func shareChecksum(threshold int, x byte, y []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{byte(threshold), x})
+	h.Write(y)
+	return h.Sum(nil)[:4]
+}
+
+// String returns the printable form of the share
+func (s Share) String() string {
+	data := append(append([]byte{}, s.Y...), shareChecksum(s.Threshold, s.X, s.Y)...)
+	return fmt.Sprintf("%s%d-%d-%s", sharePrefix, s.Threshold, s.X, strings.ToUpper(hex.EncodeToString(data)))
+}
+
+// ParseShare parses the printable form of a share, the spaces are ignored
+func ParseShare(text string) (Share, error) {
+	text = strings.ToUpper(strings.Join(strings.Fields(text), ""))
+	if !strings.HasPrefix(text, sharePrefix) {
+		return Share{}, fmt.Errorf("%w: missing the %s prefix", ErrInvalidShare, sharePrefix)
+	}
+	parts := strings.SplitN(strings.TrimPrefix(text, sharePrefix), "-", 3)
+	if len(parts) != 3 {
+		return Share{}, ErrInvalidShare
+	}
+	threshold, err1 := strconv.Atoi(parts[0])
+	x, err2 := strconv.Atoi(parts[1])
+	data, err3 := hex.DecodeString(parts[2])
+	if err1 != nil || err2 != nil || err3 != nil || x < 1 || x > 255 || len(data) <= 4 {
+		return Share{}, ErrInvalidShare
+	}
+	s := Share{Threshold: threshold, X: byte(x), Y: data[:len(data)-4]}
+	if !bytes.Equal(data[len(data)-4:], shareChecksum(threshold, s.X, s.Y)) {
+		return Share{}, fmt.Errorf("%w: wrong checksum for share %d, check for typos", ErrInvalidShare, x)
+	}
+	return s, nil
+}
@@ -0,0 +1,60 @@
+package security
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// TestShamir_Combine tests that any threshold shares rebuild the secret
+func TestShamir_Combine(t *testing.T) {
+	secret := []byte("AGE-SECRET-KEY-1QYQSZQGPQYQSZQGPQYQSZQGPQYQSZQGPQYQSZQGPQYQSZQGPQYQSZQGP")
+	shares, err := SplitSecret(secret, 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pick := range [][]int{{0, 1, 2}, {4, 2, 0}, {1, 3, 4}, {0, 1, 2, 3, 4}} {
+		var subset []Share
+		for _, i := range pick {
+			subset = append(subset, shares[i])
+		}
+		got, err := CombineShares(subset)
+		if err != nil {
+			t.Fatalf("%v: expected no error, got: %v", pick, err)
+		}
+		if !bytes.Equal(got, secret) {
+			t.Errorf("%v: the secret is not rebuilt", pick)
+		}
+	}
+	if _, err := CombineShares(shares[:2]); err == nil {
+		t.Error("Expected an error with fewer shares than the threshold, got nil")
+	}
+	if _, err := SplitSecret(secret, 2, 3); err == nil {
+		t.Error("Expected an error with a threshold greater than the shares, got nil")
+	}
+}
+
+// TestShare_Parse tests the printable form of a share
+func TestShare_Parse(t *testing.T) {
+	shares, err := SplitSecret([]byte("secret"), 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := shares[1].String()
+	s, err := ParseShare("  " + text[:20] + " " + text[20:] + "\n")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if s.X != 2 || s.Threshold != 2 || !bytes.Equal(s.Y, shares[1].Y) {
+		t.Errorf("Expected share 2 of 2, got %+v", s)
+	}
+	typo := []byte(text)
+	if typo[len(typo)-1] == 'A' {
+		typo[len(typo)-1] = 'B'
+	} else {
+		typo[len(typo)-1] = 'A'
+	}
+	if _, err := ParseShare(string(typo)); !errors.Is(err, ErrInvalidShare) {
+		t.Errorf("Expected ErrInvalidShare for a typo, got: %v", err)
+	}
+}
@@ -0,0 +1,372 @@
+package security
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// The stream format splits the plaintext in chunks sealed separately, so that
+// data of any size can be encrypted and decrypted without keeping it in memory:
+//
+//	magic (8 bytes) | salt (16 bytes) | header length (4 bytes) | header | chunk 0 | ... | last chunk
+//
+// Every chunk is sealed with AES-GCM using a key derived from the passphrase and
+// the salt. The nonce is the chunk counter and a flag set on the last chunk only:
+// reordering, removing or truncating chunks makes the decryption fail.
+// The header is a sealed YAML FileInfo with its own nonce. Version 1 of the
+// format has no header.
+const (
+	streamChunkSize = 64 * 1024
+	streamSaltSize  = 16
+	streamMaxHeader = 64 * 1024
+)
+
+var (
+	streamMagicV1 = []byte("RAPTOR\x00\x01")
+	streamMagic   = []byte("RAPTOR\x00\x02")
+	// ErrTruncated is returned when the encrypted stream ends before the last chunk
+	ErrTruncated = errors.New("the encrypted data is truncated")
+	// ErrAuthentication is returned when the data can't be authenticated with
+	// the key: a wrong password, or altered data
+	ErrAuthentication = errors.New("message authentication failed (wrong password or altered data)")
+	// ErrFormat is returned when the data is not in an encrypted format
+	ErrFormat = errors.New("invalid encrypted data")
+)
+
+// FileInfo is the metadata of the original file stored in the encrypted stream
+type FileInfo struct {
+	Name string `yaml:"name,omitempty"`
+	// Mode contains the permission bits only
+	Mode     os.FileMode `yaml:"mode,omitempty"`
+	ModTime  int64       `yaml:"mtime,omitempty"` // unix time in nanoseconds
+	Uid      int         `yaml:"uid,omitempty"`
+	Gid      int         `yaml:"gid,omitempty"`
+	HasOwner bool        `yaml:"hasOwner,omitempty"`
+	// Archive is the format of the content when a folder is encrypted as an
+	// archive, empty for a single file
+	Archive string `yaml:"archive,omitempty"`
+}
+
+// NewFileInfo returns the metadata to store for the given file
+func NewFileInfo(info os.FileInfo) *FileInfo {
+	fi := &FileInfo{
+		Name:    info.Name(),
+		Mode:    info.Mode().Perm(),
+		ModTime: info.ModTime().UnixNano(),
+	}
+	fi.Uid, fi.Gid, fi.HasOwner = fileOwner(info)
+	return fi
+}
+
+// restore applies the mode, the modification time and (when running as root)
+// the ownership to the path. The data encrypted from a pipe has no metadata.
+func (fi *FileInfo) restore(path string) error {
+	if fi.Mode != 0 {
+		if err := os.Chmod(path, fi.Mode.Perm()); err != nil {
+			return err
+		}
+	}
+	if fi.ModTime != 0 {
+		mtime := time.Unix(0, fi.ModTime)
+		if err := os.Chtimes(path, time.Now(), mtime); err != nil {
+			return err
+		}
+	}
+	if fi.HasOwner {
+		return restoreOwner(path, fi.Uid, fi.Gid)
+	}
+	return nil
+}
+
+// streamKey derives the stream key from the passphrase and the salt
+func streamKey(passphrase []byte, salt []byte) []byte {
+	key := sha256.Sum256(passphrase)
+	defer clear(key[:])
+	mac := hmac.New(sha256.New, key[:])
+	mac.Write(salt)
+	return mac.Sum(nil)
+}
+
+// streamNonce returns the nonce of the chunk number counter
+func streamNonce(counter uint64, last bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[3:11], counter)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// headerNonce is the nonce of the header, it never matches a chunk nonce
+func headerNonce() []byte {
+	nonce := make([]byte, 12)
+	nonce[0] = 1
+	return nonce
+}
+
+func newStreamGCM(passphrase string, salt []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(streamKey([]byte(passphrase), salt))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %v", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptWriter encrypts the data written into it. Close must be called to
+// write the last chunk.
+type EncryptWriter struct {
+	w       io.Writer
+	gcm     cipher.AEAD
+	buf     []byte
+	out     []byte
+	counter uint64
+	closed  bool
+}
+
+// NewEncryptWriter writes the stream header into w and returns the writer for
+// the plaintext. The info can be nil.
+func NewEncryptWriter(w io.Writer, passphrase string, info *FileInfo) (*EncryptWriter, error) {
+	salt := make([]byte, streamSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %v", err)
+	}
+	gcm, err := newStreamGCM(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	if info == nil {
+		info = &FileInfo{}
+	}
+	header, err := yaml.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+	sealed := gcm.Seal(nil, headerNonce(), header, nil)
+
+	prefix := append(append([]byte{}, streamMagic...), salt...)
+	prefix = binary.BigEndian.AppendUint32(prefix, uint32(len(sealed)))
+	if _, err := w.Write(append(prefix, sealed...)); err != nil {
+		return nil, err
+	}
+	return &EncryptWriter{
+		w:   w,
+		gcm: gcm,
+		buf: make([]byte, 0, streamChunkSize),
+		out: make([]byte, 0, streamChunkSize+gcm.Overhead()),
+	}, nil
+}
+
+// Write encrypts p, the full chunks are written immediately
+func (e *EncryptWriter) Write(p []byte) (int, error) {
+	if e.closed {
+		return 0, errors.New("write on a closed EncryptWriter")
+	}
+	n := 0
+	for len(p) > 0 {
+		// the chunk is flushed only when more data follows: the last one is
+		// written by Close
+		if len(e.buf) == streamChunkSize {
+			if err := e.flush(false); err != nil {
+				return n, err
+			}
+		}
+		c := copy(e.buf[len(e.buf):streamChunkSize], p)
+		e.buf = e.buf[:len(e.buf)+c]
+		p = p[c:]
+		n += c
+	}
+	return n, nil
+}
+
+// Close writes the last chunk, it doesn't close the underlying writer
+func (e *EncryptWriter) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	return e.flush(true)
+}
+
+func (e *EncryptWriter) flush(last bool) error {
+	e.out = e.gcm.Seal(e.out[:0], streamNonce(e.counter, last), e.buf, nil)
+	e.counter++
+	e.buf = e.buf[:0]
+	_, err := e.w.Write(e.out)
+	return err
+}
+
+// DecryptReader decrypts the stream read from the underlying reader
+type DecryptReader struct {
+	// Info is the metadata of the original file, nil for the formats without it
+	Info *FileInfo
+
+	br      *bufio.Reader
+	gcm     cipher.AEAD
+	buf     []byte
+	out     []byte
+	pending []byte
+	counter uint64
+	done    bool
+}
+
+// NewDecryptReader reads the stream header from r and returns the reader for
+// the plaintext. Data encrypted with the former formats is accepted as well:
+// the single-block one is decrypted in memory.
+func NewDecryptReader(r io.Reader, passphrase string) (*DecryptReader, error) {
+	br := bufio.NewReaderSize(r, streamChunkSize+64)
+	magic, err := br.Peek(len(streamMagic))
+	if err != nil || (!bytes.Equal(magic, streamMagic) && !bytes.Equal(magic, streamMagicV1)) {
+		plaintext, err := decryptLegacy(br, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		return &DecryptReader{pending: plaintext, done: true}, nil
+	}
+	version := magic[len(magic)-1]
+	if _, err := br.Discard(len(streamMagic)); err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, streamSaltSize)
+	if _, err := io.ReadFull(br, salt); err != nil {
+		return nil, ErrTruncated
+	}
+	gcm, err := newStreamGCM(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	d := &DecryptReader{
+		br:  br,
+		gcm: gcm,
+		buf: make([]byte, streamChunkSize+gcm.Overhead()),
+		out: make([]byte, 0, streamChunkSize),
+	}
+	if version >= 2 {
+		if d.Info, err = d.readHeader(); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+func (d *DecryptReader) readHeader() (*FileInfo, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(d.br, size[:]); err != nil {
+		return nil, ErrTruncated
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n < uint32(d.gcm.Overhead()) || n > streamMaxHeader {
+		return nil, fmt.Errorf("%w: invalid header size %d", ErrFormat, n)
+	}
+	sealed := make([]byte, n)
+	if _, err := io.ReadFull(d.br, sealed); err != nil {
+		return nil, ErrTruncated
+	}
+	header, err := d.gcm.Open(nil, headerNonce(), sealed, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data: %w", ErrAuthentication)
+	}
+	info := &FileInfo{}
+	if err := yaml.Unmarshal(header, info); err != nil {
+		return nil, fmt.Errorf("%w: invalid header: %v", ErrFormat, err)
+	}
+	return info, nil
+}
+
+// Read returns the decrypted data. Every chunk is authenticated before being
+// returned.
+func (d *DecryptReader) Read(p []byte) (int, error) {
+	for len(d.pending) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.pending)
+	d.pending = d.pending[n:]
+	return n, nil
+}
+
+// next decrypts the next chunk into pending
+func (d *DecryptReader) next() error {
+	n, err := io.ReadFull(d.br, d.buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return fmt.Errorf("failed to read data: %v", err)
+	}
+	last := err != nil
+	if !last {
+		// a full chunk is the last one when nothing follows
+		if _, perr := d.br.Peek(1); perr == io.EOF {
+			last = true
+		} else if perr != nil {
+			return fmt.Errorf("failed to read data: %v", perr)
+		}
+	}
+	if n < d.gcm.Overhead() {
+		return ErrTruncated
+	}
+	d.out, err = d.gcm.Open(d.out[:0], streamNonce(d.counter, last), d.buf[:n], nil)
+	if err != nil {
+		if last {
+			// an authentic chunk not flagged as the last one
+			if _, lerr := d.gcm.Open(nil, streamNonce(d.counter, false), d.buf[:n], nil); lerr == nil {
+				return ErrTruncated
+			}
+		}
+		return fmt.Errorf("failed to decrypt data: %w", ErrAuthentication)
+	}
+	d.counter++
+	d.pending = d.out
+	d.done = last
+	return nil
+}
+
+// EncryptStream reads the plaintext from r and writes the encrypted stream into w
+func EncryptStream(r io.Reader, w io.Writer, passphrase string) error {
+	return encryptStream(r, w, passphrase, nil)
+}
+
+func encryptStream(r io.Reader, w io.Writer, passphrase string, info *FileInfo) error {
+	ew, err := NewEncryptWriter(w, passphrase, info)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(ew, r); err != nil {
+		return err
+	}
+	return ew.Close()
+}
+
+// DecryptStream reads the encrypted data from r and writes the plaintext into w.
+func DecryptStream(r io.Reader, w io.Writer, passphrase string) error {
+	dr, err := NewDecryptReader(r, passphrase)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, dr)
+	return err
+}
+
+// decryptLegacy decrypts data encrypted as a single AES-GCM block
+func decryptLegacy(r io.Reader, passphrase string) ([]byte, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data: %v", err)
+	}
+	return decrypt(data, []byte(passphrase))
+}
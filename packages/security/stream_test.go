@@ -0,0 +1,131 @@
+package security
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestStream_RoundTrip tests sizes around the chunk boundaries
+func TestStream_RoundTrip(t *testing.T) {
+	for _, size := range []int{0, 1, streamChunkSize - 1, streamChunkSize, streamChunkSize + 1, 3 * streamChunkSize} {
+		data := make([]byte, size)
+		rand.Read(data)
+
+		var enc, dec bytes.Buffer
+		if err := EncryptStream(bytes.NewReader(data), &enc, "passphrase"); err != nil {
+			t.Fatalf("size %d: expected no error, got: %v", size, err)
+		}
+		if err := DecryptStream(&enc, &dec, "passphrase"); err != nil {
+			t.Fatalf("size %d: expected no error, got: %v", size, err)
+		}
+		if !bytes.Equal(data, dec.Bytes()) {
+			t.Errorf("size %d: decrypted data differs from the original", size)
+		}
+	}
+}
+
+// TestStream_WrongPassphrase tests that a wrong passphrase fails
+func TestStream_WrongPassphrase(t *testing.T) {
+	var enc, dec bytes.Buffer
+	if err := EncryptStream(bytes.NewReader([]byte("secret")), &enc, "passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	if err := DecryptStream(&enc, &dec, "wrong"); err == nil {
+		t.Error("Expected an error, got nil")
+	}
+}
+
+// TestStream_Truncated tests that removing the last chunk is detected
+func TestStream_Truncated(t *testing.T) {
+	data := make([]byte, 2*streamChunkSize+10)
+	var enc, dec bytes.Buffer
+	if err := EncryptStream(bytes.NewReader(data), &enc, "passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	// header and the first two full chunks
+	headerStart := len(streamMagic) + streamSaltSize
+	headerSize := int(binary.BigEndian.Uint32(enc.Bytes()[headerStart:]))
+	cut := headerStart + 4 + headerSize + 2*(streamChunkSize+16)
+	err := DecryptStream(bytes.NewReader(enc.Bytes()[:cut]), &dec, "passphrase")
+	if !errors.Is(err, ErrTruncated) {
+		t.Errorf("Expected ErrTruncated, got: %v", err)
+	}
+}
+
+// TestStream_Tampered tests that a modified byte is detected
+func TestStream_Tampered(t *testing.T) {
+	var enc, dec bytes.Buffer
+	if err := EncryptStream(bytes.NewReader([]byte("secret data")), &enc, "passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	tampered := enc.Bytes()
+	tampered[len(tampered)-1] ^= 0xff
+	if err := DecryptStream(bytes.NewReader(tampered), &dec, "passphrase"); err == nil {
+		t.Error("Expected an error, got nil")
+	}
+}
+
+// TestStream_Legacy tests that the single-block format is still decrypted
+func TestStream_Legacy(t *testing.T) {
+	enc, err := EncryptBox([]byte("legacy data"), []byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dec bytes.Buffer
+	if err := DecryptStream(bytes.NewReader(enc), &dec, "passphrase"); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if dec.String() != "legacy data" {
+		t.Errorf("Expected 'legacy data', got %q", dec.String())
+	}
+}
+
+// TestDecryptFile_Metadata tests that the mode, the modification time and the
+// name are restored
+func TestDecryptFile_Metadata(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "script.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := EncryptFile(path, "passphrase", nil); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	info, err := os.Stat(path + ".enc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the .enc file mode to be 0600, got %v", info.Mode().Perm())
+	}
+	// the original name comes from the header
+	renamed := filepath.Join(dir, "renamed.enc")
+	if err := os.Rename(path+".enc", renamed); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := DecryptFile(renamed, "passphrase", nil); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	info, err = os.Stat(path)
+	if err != nil {
+		t.Fatalf("Expected the original name to be restored: %v", err)
+	}
+	if info.Mode().Perm() != 0750 {
+		t.Errorf("Expected mode 0750, got %v", info.Mode().Perm())
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Errorf("Expected mtime %v, got %v", mtime, info.ModTime())
+	}
+}
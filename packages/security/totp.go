@@ -0,0 +1,76 @@
+package security
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TOTP computes the time-based one-time password (RFC 6238) of the secret at
+// the time t. The secret is the base32 key or an otpauth:// URI, which can set
+// the algorithm, the digits and the period (default SHA1, 6 digits, 30s). The
+// seconds the code is still valid are returned too.
+func TOTP(secret string, t time.Time) (string, int, error) {
+	key, algorithm, digits, period := strings.TrimSpace(secret), "SHA1", 6, 30
+	if strings.HasPrefix(strings.ToLower(key), "otpauth://") {
+		u, err := url.Parse(key)
+		if err != nil {
+			return "", 0, fmt.Errorf("invalid otpauth URI: %v", err)
+		}
+		q := u.Query()
+		key = q.Get("secret")
+		if a := q.Get("algorithm"); len(a) > 0 {
+			algorithm = strings.ToUpper(a)
+		}
+		if d := q.Get("digits"); len(d) > 0 {
+			if digits, err = strconv.Atoi(d); err != nil || digits < 6 || digits > 8 {
+				return "", 0, fmt.Errorf("invalid otpauth digits %q", d)
+			}
+		}
+		if p := q.Get("period"); len(p) > 0 {
+			if period, err = strconv.Atoi(p); err != nil || period <= 0 {
+				return "", 0, fmt.Errorf("invalid otpauth period %q", p)
+			}
+		}
+	}
+
+	var h func() hash.Hash
+	switch algorithm {
+	case "SHA1":
+		h = sha1.New
+	case "SHA256":
+		h = sha256.New
+	case "SHA512":
+		h = sha512.New
+	default:
+		return "", 0, fmt.Errorf("unsupported otp algorithm %q", algorithm)
+	}
+	key = strings.ToUpper(strings.ReplaceAll(key, " ", ""))
+	raw, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(key, "="))
+	if err != nil || len(raw) == 0 {
+		return "", 0, fmt.Errorf("invalid otp secret: not a base32 key")
+	}
+
+	counter := t.Unix() / int64(period)
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+	mac := hmac.New(h, raw)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, code%mod), period - int(t.Unix()%int64(period)), nil
+}
@@ -0,0 +1,41 @@
+package security
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestTOTP tests the RFC 6238 vectors
+func TestTOTP(t *testing.T) {
+	key := func(n int) string {
+		return base32.StdEncoding.EncodeToString([]byte(strings.Repeat("1234567890", 7)[:n]))
+	}
+	tests := []struct {
+		secret string
+		unix   int64
+		want   string
+	}{
+		{"otpauth://totp/x?digits=8&secret=" + key(20), 59, "94287082"},
+		{"otpauth://totp/x?digits=8&secret=" + key(20), 1111111109, "07081804"},
+		{"otpauth://totp/x?digits=8&algorithm=SHA256&secret=" + key(32), 59, "46119246"},
+		{"otpauth://totp/x?digits=8&algorithm=SHA512&secret=" + key(64), 20000000000, "47863826"},
+		{strings.ToLower(key(20)), 59, "287082"},
+	}
+	for _, tt := range tests {
+		code, left, err := TOTP(tt.secret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != tt.want {
+			t.Errorf("%s at %d: got %s, expected %s", tt.secret, tt.unix, code, tt.want)
+		}
+		if want := 30 - int(tt.unix%30); left != want {
+			t.Errorf("Expected %ds left, got %d", want, left)
+		}
+	}
+	if _, _, err := TOTP("not base32!", time.Now()); err == nil {
+		t.Error("Expected an error for an invalid secret")
+	}
+}
@@ -0,0 +1,135 @@
+package security
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// Wipe policies applied to the files deleted after the encryption, the
+// decryption or by Shred
+const (
+	// WipeNone removes the file without overwriting it
+	WipeNone = "none"
+	// WipeZero overwrites the file with zeros (1 pass)
+	WipeZero = "zero"
+	// WipeRandom overwrites the file with random data (1 pass)
+	WipeRandom = "random"
+	// WipeDoD overwrites the file with zeros, ones and random data as
+	// DoD 5220.22-M (3 passes)
+	WipeDoD = "dod"
+)
+
+// wipeChunkSize is the size of the writes overwriting a file
+const wipeChunkSize = 1024 * 1024
+
+// WipePolicies returns the supported policies
+func WipePolicies() []string {
+	return []string{WipeNone, WipeZero, WipeRandom, WipeDoD}
+}
+
+// ValidWipePolicy returns an error if the policy is not supported
+func ValidWipePolicy(policy string) error {
+	for _, p := range WipePolicies() {
+		if p == policy {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid wipe policy %q: none, zero, random or dod", policy)
+}
+
+// wipePasses returns the byte pattern of every pass, nil for random data
+func wipePasses(policy string) ([][]byte, error) {
+	switch policy {
+	case WipeNone:
+		return nil, nil
+	case WipeZero:
+		return [][]byte{{0x00}}, nil
+	case WipeRandom:
+		return [][]byte{nil}, nil
+	case "", WipeDoD:
+		return [][]byte{{0x00}, {0xff}, nil}, nil
+	}
+	return nil, ValidWipePolicy(policy)
+}
+
+// WipeFile overwrites the file following the policy and removes it. A symlink
+// is removed without touching its target.
+func WipeFile(path, policy string) error {
+	slog.Debug("security.WipeFile()", "path", path, "policy", policy)
+	passes, err := wipePasses(policy)
+	if err != nil {
+		return err
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode().IsRegular() && len(passes) > 0 {
+		if err := overwrite(path, info.Size(), passes); err != nil {
+			return fmt.Errorf("failed to wipe %s: %v", path, err)
+		}
+	}
+	return os.Remove(path)
+}
+
+// overwrite writes every pass on the whole file content, flushing it on the
+// disk before the next pass
+func overwrite(path string, size int64, passes [][]byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	buf := make([]byte, wipeChunkSize)
+	for _, pattern := range passes {
+		if pattern != nil {
+			for i := range buf {
+				buf[i] = pattern[0]
+			}
+		}
+		for off := int64(0); off < size; off += int64(len(buf)) {
+			chunk := buf[:min(int64(len(buf)), size-off)]
+			if pattern == nil {
+				if _, err := io.ReadFull(rand.Reader, chunk); err != nil {
+					return err
+				}
+			}
+			if _, err := f.WriteAt(chunk, off); err != nil {
+				return err
+			}
+		}
+		if err := f.Sync(); err != nil {
+			return err
+		}
+	}
+	return f.Close()
+}
+
+// Shred wipes the file, or every file of the tree and then removes it
+func Shred(path, policy string) error {
+	if err := ValidWipePolicy(policy); err != nil {
+		return err
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return WipeFile(path, policy)
+	}
+	err = filepath.Walk(path, func(p string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		return WipeFile(p, policy)
+	})
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(path)
+}
@@ -0,0 +1,26 @@
+package security
+
+import (
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+// untrustedFileSystems are the file systems writing the new data elsewhere:
+// copy-on-write and network ones
+var untrustedFileSystems = map[string]bool{
+	"apfs": true, "zfs": true, "nfs": true, "smbfs": true, "afpfs": true, "webdav": true, "macfuse": true,
+}
+
+// UntrustedFS returns the type of the file system of the path when
+// overwriting the data in place can't be trusted
+func UntrustedFS(path string) (string, bool) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		if err := unix.Statfs(filepath.Dir(path), &st); err != nil {
+			return "", false
+		}
+	}
+	name := unix.ByteSliceToString(st.Fstypename[:])
+	return name, untrustedFileSystems[name]
+}
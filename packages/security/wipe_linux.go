@@ -0,0 +1,43 @@
+package security
+
+import (
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+// zfsSuperMagic is missing from x/sys/unix
+const zfsSuperMagic = 0x2fc12fc1
+
+// untrustedFileSystems are the file systems writing the new data elsewhere:
+// copy-on-write, log structured, network and stacked ones
+var untrustedFileSystems = map[int64]string{
+	unix.BTRFS_SUPER_MAGIC:     "btrfs",
+	zfsSuperMagic:              "zfs",
+	unix.BCACHEFS_SUPER_MAGIC:  "bcachefs",
+	unix.F2FS_SUPER_MAGIC:      "f2fs",
+	unix.NILFS_SUPER_MAGIC:     "nilfs",
+	unix.OVERLAYFS_SUPER_MAGIC: "overlayfs",
+	unix.FUSE_SUPER_MAGIC:      "fuse",
+	unix.NFS_SUPER_MAGIC:       "nfs",
+	unix.CIFS_SUPER_MAGIC:      "cifs",
+	unix.SMB_SUPER_MAGIC:       "smb",
+	unix.SMB2_SUPER_MAGIC:      "smb2",
+	unix.CEPH_SUPER_MAGIC:      "ceph",
+	unix.AFS_SUPER_MAGIC:       "afs",
+	unix.CODA_SUPER_MAGIC:      "coda",
+}
+
+// UntrustedFS returns the type of the file system of the path when
+// overwriting the data in place can't be trusted
+func UntrustedFS(path string) (string, bool) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		// the file may be gone, check its folder
+		if err := unix.Statfs(filepath.Dir(path), &st); err != nil {
+			return "", false
+		}
+	}
+	name, ok := untrustedFileSystems[int64(st.Type)]
+	return name, ok
+}
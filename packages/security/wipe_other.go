@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package security
+
+// UntrustedFS can't detect the file system type on this platform
+func UntrustedFS(path string) (string, bool) {
+	return "", false
+}
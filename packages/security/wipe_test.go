@@ -0,0 +1,80 @@
+package security
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWipeFile(t *testing.T) {
+	dir := t.TempDir()
+	for _, policy := range WipePolicies() {
+		path := filepath.Join(dir, policy)
+		if err := os.WriteFile(path, make([]byte, wipeChunkSize+10), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := WipeFile(path, policy); err != nil {
+			t.Fatalf("policy %s: %v", policy, err)
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("policy %s: the file still exists", policy)
+		}
+	}
+	if err := WipeFile(filepath.Join(dir, "x"), "gutmann"); err == nil {
+		t.Error("expected an error for an invalid policy")
+	}
+}
+
+func TestOverwrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "f")
+	data := []byte("very secret data")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := overwrite(path, int64(len(data)), [][]byte{{0x00}, {0xff}}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the passes overwrite in place, the size doesn't change
+	if len(got) != len(data) {
+		t.Fatalf("size %d, expected %d", len(got), len(data))
+	}
+	for _, b := range got {
+		if b != 0xff {
+			t.Fatalf("content not overwritten: %x", got)
+		}
+	}
+}
+
+func TestShred(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "target")
+	if err := os.WriteFile(target, []byte("keep me"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tree := filepath.Join(dir, "tree")
+	if err := os.MkdirAll(filepath.Join(tree, "sub"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(tree, "a"), []byte("a"), 0600)
+	os.WriteFile(filepath.Join(tree, "sub", "b"), []byte("b"), 0600)
+	if err := os.Symlink(target, filepath.Join(tree, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Shred(tree, WipeZero); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(tree); !os.IsNotExist(err) {
+		t.Error("the tree still exists")
+	}
+	if got, _ := os.ReadFile(target); string(got) != "keep me" {
+		t.Errorf("the link target has been modified: %q", got)
+	}
+	if err := Shred(target, "twice"); err == nil {
+		t.Error("expected an error for an invalid policy")
+	}
+}
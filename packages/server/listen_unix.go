@@ -0,0 +1,22 @@
+//go:build !windows
+
+package server
+
+import (
+	"net"
+	"os"
+)
+
+// listenUnix creates the socket readable and writable by the user only, the
+// mode is set before any connection is accepted
+func listenUnix(socket string) (net.Listener, error) {
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(socket, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
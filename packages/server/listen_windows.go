@@ -0,0 +1,41 @@
+package server
+
+import (
+	"fmt"
+	"net"
+
+	"golang.org/x/sys/windows"
+)
+
+// listenUnix creates the socket with a protected DACL giving access to the
+// current user only, set before any connection is accepted
+func listenUnix(socket string) (net.Listener, error) {
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+	if err := restrictToUser(socket); err != nil {
+		l.Close()
+		return nil, fmt.Errorf("failed to restrict the socket %s to the user: %v", socket, err)
+	}
+	return l, nil
+}
+
+// restrictToUser replaces the DACL of the file with the full access of the
+// user of the process, the inherited entries are dropped
+func restrictToUser(path string) error {
+	user, err := windows.GetCurrentProcessToken().GetTokenUser()
+	if err != nil {
+		return err
+	}
+	sd, err := windows.SecurityDescriptorFromString("D:P(A;;GA;;;" + user.User.Sid.String() + ")")
+	if err != nil {
+		return err
+	}
+	dacl, _, err := sd.DACL()
+	if err != nil {
+		return err
+	}
+	return windows.SetNamedSecurityInfo(path, windows.SE_FILE_OBJECT,
+		windows.DACL_SECURITY_INFORMATION|windows.PROTECTED_DACL_SECURITY_INFORMATION, nil, nil, dacl, nil)
+}
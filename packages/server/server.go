@@ -0,0 +1,285 @@
+// Package server serves the secrets of an open box to the local tools: the
+// Secrets service of protos/api.proto on a Unix socket readable by the user
+// only, the clients authenticate with the token of the session.
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/mas2020-golang/cryptex/packages/protos"
+	"github.com/mas2020-golang/cryptex/packages/protos/protosconnect"
+	"github.com/mas2020-golang/cryptex/pkg/vault"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Server serves the secrets of a box, read-only unless the writes are allowed
+type Server struct {
+	mu    sync.Mutex
+	vault *vault.Vault
+	box   *vault.Box
+	token string
+	write bool
+}
+
+// New returns a server of the open box with a new token, the box is saved
+// after Put and Delete
+func New(v *vault.Vault, write bool) (*Server, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return nil, fmt.Errorf("failed to generate the token: %v", err)
+	}
+	return &Server{vault: v, box: v.Box(), token: hex.EncodeToString(token), write: write}, nil
+}
+
+// Token returns the token of the session, sent by the clients in the header
+// "Authorization: Bearer <token>"
+func (s *Server) Token() string {
+	return s.token
+}
+
+// Handler returns the HTTP handler of the Secrets service, the requests without
+// the token are refused
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(protosconnect.NewSecretsHandler(s, connect.WithInterceptors(connect.UnaryInterceptorFunc(s.authenticate))))
+	return mux
+}
+
+// Serve serves the box on the Unix socket until the context is done, the
+// socket is removed on return
+func (s *Server) Serve(ctx context.Context, socket string) error {
+	l, err := listen(socket)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(socket)
+
+	// gRPC needs HTTP/2, there is no TLS on the socket
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetUnencryptedHTTP2(true)
+	srv := &http.Server{Handler: s.Handler(), Protocols: protocols, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}()
+	if err := srv.Serve(l); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// listen listens on the socket, a stale socket left by a crash is replaced but
+// any other file is kept
+func listen(socket string) (net.Listener, error) {
+	if info, err := os.Lstat(socket); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s already exists and is not a socket", socket)
+		}
+		if c, err := net.Dial("unix", socket); err == nil {
+			c.Close()
+			return nil, fmt.Errorf("the socket %s is in use", socket)
+		}
+		if err := os.Remove(socket); err != nil {
+			return nil, err
+		}
+	}
+	return listenUnix(socket)
+}
+
+func (s *Server) authenticate(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		token, ok := strings.CutPrefix(req.Header().Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("invalid token"))
+		}
+		slog.Debug("server.authenticate()", "procedure", req.Spec().Procedure)
+		return next(ctx, req)
+	}
+}
+
+// List returns the secrets of the box without their values
+func (s *Server) List(ctx context.Context, req *connect.Request[protos.ListRequest]) (*connect.Response[protos.ListResponse], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := &protos.ListResponse{Box: s.box.Name}
+	for _, secret := range s.box.Secrets {
+		res.Secrets = append(res.Secrets, s.info(secret))
+	}
+	return connect.NewResponse(res), nil
+}
+
+// Get returns the password or the value of an item of an exportable secret
+func (s *Server) Get(ctx context.Context, req *connect.Request[protos.GetRequest]) (*connect.Response[protos.GetResponse], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	secret, err := s.secret(req.Msg.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !s.box.Exportable(secret.Name) {
+		return nil, connect.NewError(connect.CodePermissionDenied, fmt.Errorf("the secret %s is not exportable", secret.Name))
+	}
+	if err := secret.Load(); err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	defer secret.Hide()
+
+	value := secret.Pwd
+	if len(req.Msg.Item) > 0 {
+		var ok bool
+		if value, ok = secret.Others[req.Msg.Item]; !ok {
+			return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("no item %s in the secret %s", req.Msg.Item, secret.Name))
+		}
+	}
+	return connect.NewResponse(&protos.GetResponse{Secret: s.info(secret), Value: value}), nil
+}
+
+// Put creates or replaces the secret and saves the box
+func (s *Server) Put(ctx context.Context, req *connect.Request[protos.PutRequest]) (*connect.Response[protos.PutResponse], error) {
+	if err := s.writable(); err != nil {
+		return nil, err
+	}
+	if len(req.Msg.Name) == 0 {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("the name of the secret is required"))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	secret := &vault.Secret{
+		Name:        req.Msg.Name,
+		Login:       req.Msg.Login,
+		Url:         req.Msg.Url,
+		Pwd:         req.Msg.Pwd,
+		Notes:       req.Msg.Notes,
+		Others:      req.Msg.Others,
+		Tags:        req.Msg.Tags,
+		Version:     "1.0.0",
+		LastUpdated: time.Now().Format(time.RFC3339),
+	}
+	old := s.box.Secret(secret.Name)
+	s.box.Put(secret)
+	if err := s.save(ctx); err != nil {
+		if old != nil {
+			s.box.Put(old)
+		} else {
+			s.box.Remove(secret.Name)
+		}
+		secret.Wipe()
+		return nil, err
+	}
+	if old != nil {
+		old.Wipe()
+	}
+	secret.Hide()
+	return connect.NewResponse(&protos.PutResponse{Secret: s.info(secret)}), nil
+}
+
+// Delete removes the secret and saves the box
+func (s *Server) Delete(ctx context.Context, req *connect.Request[protos.DeleteRequest]) (*connect.Response[protos.DeleteResponse], error) {
+	if err := s.writable(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	secret, err := s.secret(req.Msg.Name)
+	if err != nil {
+		return nil, err
+	}
+	s.box.Remove(secret.Name)
+	if err := s.save(ctx); err != nil {
+		s.box.Put(secret)
+		return nil, err
+	}
+	secret.Wipe()
+	return connect.NewResponse(&protos.DeleteResponse{}), nil
+}
+
+// Search returns the secrets matching the query and having all the tags,
+// without their values
+func (s *Server) Search(ctx context.Context, req *connect.Request[protos.SearchRequest]) (*connect.Response[protos.SearchResponse], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	query := strings.ToLower(req.Msg.Query)
+	res := &protos.SearchResponse{}
+	for _, secret := range s.box.Secrets {
+		if !matches(secret, query) {
+			continue
+		}
+		if slices.ContainsFunc(req.Msg.Tags, func(tag string) bool { return !slices.Contains(secret.Tags, tag) }) {
+			continue
+		}
+		res.Secrets = append(res.Secrets, s.info(secret))
+	}
+	return connect.NewResponse(res), nil
+}
+
+// matches is true when the name, the login, the URL or a tag of the secret
+// contains the lower case query
+func matches(s *vault.Secret, query string) bool {
+	for _, field := range append([]string{s.Name, s.Login, s.Url}, s.Tags...) {
+		if strings.Contains(strings.ToLower(field), query) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) secret(name string) (*vault.Secret, error) {
+	secret := s.box.Secret(name)
+	if secret == nil {
+		return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("no secret %s in the box", name))
+	}
+	return secret, nil
+}
+
+func (s *Server) writable() error {
+	if !s.write {
+		return connect.NewError(connect.CodePermissionDenied, errors.New("the session is read-only, start the server with --allow-write"))
+	}
+	return nil
+}
+
+func (s *Server) save(ctx context.Context) error {
+	if err := s.vault.Save(ctx); err != nil {
+		// the box has been changed by another raptor, serve has to be
+		// started again to see the changes
+		if errors.Is(err, vault.ErrConflict) {
+			return connect.NewError(connect.CodeAborted, err)
+		}
+		return connect.NewError(connect.CodeInternal, err)
+	}
+	return nil
+}
+
+// info returns the read-only view of the secret
+func (s *Server) info(secret *vault.Secret) *protos.SecretInfo {
+	info := &protos.SecretInfo{
+		Name:       secret.Name,
+		Login:      secret.Login,
+		Url:        secret.Url,
+		Version:    secret.Version,
+		Tags:       secret.Tags,
+		Items:      secret.Items(),
+		Exportable: s.box.Exportable(secret.Name),
+	}
+	if t, err := time.Parse(time.RFC3339, secret.LastUpdated); err == nil {
+		info.LastUpdated = timestamppb.New(t)
+	}
+	return info
+}
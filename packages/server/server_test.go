@@ -0,0 +1,152 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/mas2020-golang/cryptex/packages/protos"
+	"github.com/mas2020-golang/cryptex/packages/protos/protosconnect"
+	"github.com/mas2020-golang/cryptex/pkg/vault"
+)
+
+func testServer(t *testing.T, write bool) *Server {
+	t.Helper()
+	box := &vault.Box{
+		Name:   "test",
+		Policy: &vault.Policy{NotExportable: []string{"prod-*"}},
+		Secrets: []*vault.Secret{
+			{Name: "github", Login: "me", Pwd: "pwd1", Tags: []string{"dev"}, Others: map[string]string{"token": "x"}},
+			{Name: "prod-db", Pwd: "pwd2", Tags: []string{"prod"}},
+		},
+	}
+	v, err := vault.Create(context.Background(), filepath.Join(t.TempDir(), "test"), box, vault.Key{Passphrase: "pwd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { v.Close() })
+	s, err := New(v, write)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func withToken[T any](msg *T, token string) *connect.Request[T] {
+	req := connect.NewRequest(msg)
+	req.Header().Set("Authorization", "Bearer "+token)
+	return req
+}
+
+// TestServer_Socket tests the gRPC protocol on the socket: the token is
+// required, the session is read-only and the policy is applied
+func TestServer_Socket(t *testing.T) {
+	s := testServer(t, false)
+	socket := filepath.Join(t.TempDir(), "raptor.sock")
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Serve(ctx, socket) }()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+		if _, err := os.Stat(socket); !os.IsNotExist(err) {
+			t.Error("Expected the socket to be removed")
+		}
+	}()
+
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	client := protosconnect.NewSecretsClient(&http.Client{Transport: &http.Transport{
+		Protocols: protocols,
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			for {
+				c, err := d.DialContext(ctx, "unix", socket)
+				if err == nil || ctx.Err() != nil {
+					return c, err
+				}
+				// the server is starting
+				time.Sleep(10 * time.Millisecond)
+			}
+		},
+	}}, "http://raptor", connect.WithGRPC())
+
+	if _, err := client.List(ctx, withToken(&protos.ListRequest{}, "wrong")); connect.CodeOf(err) != connect.CodeUnauthenticated {
+		t.Fatalf("Expected unauthenticated, got: %v", err)
+	}
+	info, err := os.Stat(socket)
+	if err != nil || info.Mode().Perm()&0077 != 0 {
+		t.Errorf("Expected a socket for the user only, got %v (%v)", info.Mode(), err)
+	}
+
+	list, err := client.List(ctx, withToken(&protos.ListRequest{}, s.Token()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Msg.Secrets) != 2 || list.Msg.Secrets[0].Items[0] != "token" || list.Msg.Secrets[1].Exportable {
+		t.Errorf("Expected the two secrets, got %v", list.Msg.Secrets)
+	}
+	get, err := client.Get(ctx, withToken(&protos.GetRequest{Name: "github", Item: "token"}, s.Token()))
+	if err != nil || get.Msg.Value != "x" {
+		t.Errorf("Expected the item of github, got %v (%v)", get, err)
+	}
+	if _, err := client.Get(ctx, withToken(&protos.GetRequest{Name: "prod-db"}, s.Token())); connect.CodeOf(err) != connect.CodePermissionDenied {
+		t.Errorf("Expected the secret not exportable to be refused, got: %v", err)
+	}
+	if _, err := client.Delete(ctx, withToken(&protos.DeleteRequest{Name: "github"}, s.Token())); connect.CodeOf(err) != connect.CodePermissionDenied {
+		t.Errorf("Expected a read-only session, got: %v", err)
+	}
+}
+
+// TestServer_NotASocket tests that a file at the path of the socket is kept
+func TestServer_NotASocket(t *testing.T) {
+	s := testServer(t, false)
+	socket := filepath.Join(t.TempDir(), "raptor.sock")
+	os.WriteFile(socket, []byte("data"), 0600)
+	if err := s.Serve(context.Background(), socket); err == nil {
+		t.Fatal("Expected an error, got nil")
+	}
+	if data, _ := os.ReadFile(socket); string(data) != "data" {
+		t.Error("Expected the file to be kept")
+	}
+}
+
+// TestServer_Write tests that Put and Delete save the box and Search returns
+// the secrets matching the query and the tags
+func TestServer_Write(t *testing.T) {
+	s := testServer(t, true)
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+	client := protosconnect.NewSecretsClient(srv.Client(), srv.URL)
+	ctx := context.Background()
+
+	version := s.vault.Version()
+	_, err := client.Put(ctx, withToken(&protos.PutRequest{Name: "gitlab", Pwd: "pwd3", Tags: []string{"dev"}}, s.Token()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.vault.Version() == version {
+		t.Error("Expected the box saved")
+	}
+	search, err := client.Search(ctx, withToken(&protos.SearchRequest{Query: "GIT", Tags: []string{"dev"}}, s.Token()))
+	if err != nil || len(search.Msg.Secrets) != 2 {
+		t.Fatalf("Expected github and gitlab, got %v (%v)", search, err)
+	}
+	if _, err := client.Delete(ctx, withToken(&protos.DeleteRequest{Name: "github"}, s.Token())); err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Get(ctx, withToken(&protos.GetRequest{Name: "github"}, s.Token()))
+	var cerr *connect.Error
+	if !errors.As(err, &cerr) || cerr.Code() != connect.CodeNotFound {
+		t.Errorf("Expected github deleted, got: %v", err)
+	}
+}
@@ -3,92 +3,80 @@ package utils
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"os"
-	"path"
 	"path/filepath"
 	"runtime"
 	"strings"
 
 	"github.com/mas2020-golang/cryptex/packages/security"
-	"github.com/mas2020-golang/goutils/output"
+	"github.com/mas2020-golang/cryptex/pkg/vault"
 	"golang.org/x/term"
-	"gopkg.in/yaml.v2"
 )
 
 var (
 	Version, GitCommit, BuildDate string
-	BufferBox                     *Box
-	BoxPath, BoxPwd               string
+	// KeyFilePath is the key file given with --keyfile, it overrides RAPTOR_KEYFILE
+	KeyFilePath string
+	// StoreURL is the store of the boxes given with --store, it overrides
+	// RAPTOR_STORE
+	StoreURL string
 )
 
 func init() {
 	Version = "0.5.0-SNAPSHOT"
 }
 
-type Secret struct {
-	Name        string            `yaml:"name,omitempty"`
-	Id          int32             `yaml:"id,omitempty"` // Unique ID number for this secret
-	Pwd         string            `yaml:"pwd,omitempty"`
-	Url         string            `yaml:"url,omitempty"`
-	Notes       string            `yaml:"notes,omitempty"`
-	Others      map[string]string `yaml:"others,omitempty"`
-	Version     string            `yaml:"version,omitempty"`
-	Login       string            `yaml:"login,omitempty"`
-	LastUpdated string            `yaml:"lastUpdated,omitempty"`
-}
-
-type Box struct {
-	Name        string    `yaml:"name,omitempty"`
-	Version     string    `yaml:"version,omitempty"`
-	LastUpdated string    `yaml:"lastUpdated,omitempty"`
-	Owner       string    `yaml:"owner,omitempty"`
-	Secrets     []*Secret `yaml:"secrets,omitempty"`
-	Size        int64     `yaml:"-"`
-}
-
-// GetBytesFromPipe reads from the pipe and return the buffer of bytes of the given argument
+// GetBytesFromPipe returns the standard input when data is piped into the
+// application, nil when the standard input is a terminal
 func GetBytesFromPipe() *os.File {
-	//var bs []byte
-	//buf := bytes.NewBuffer(bs)
-	stat, _ := os.Stdin.Stat()
+	stat, err := os.Stdin.Stat()
+	if err != nil {
+		return nil
+	}
 	if (stat.Mode() & os.ModeCharDevice) == 0 {
-		//scanner := bufio.NewScanner(os.Stdin)
-		//
-		//for scanner.Scan() {
-		//	buf.Write(scanner.Bytes())
-		//	fmt.Print(scanner.Text())
-		//}
-		//
-		//if err := scanner.Err(); err != nil {
-		//	log.Fatal(err)
-		//}
 		return os.Stdin
 	}
-	//fmt.Printf("number of bytes from the pipe are %d\n", len(buf.Bytes()))
 	return nil
 }
 
-// ReadPassword reads the standard input in hidden mode
-func ReadPassword(text string) (string, error) {
-	fmt.Print(text)
-	buf, err := term.ReadPassword(int(os.Stdin.Fd()))
-	return string(buf), err
+// IsTerminal returns true if the file is attached to a terminal
+func IsTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
 }
 
-// Check checks if an error and exit
-func Check(err error, message string) {
-	var errorMsg string
-	if err != nil {
-		if len(message) > 0 {
-			errorMsg = fmt.Sprintf("%s caused by %v", message, err)
-		} else {
-			errorMsg = fmt.Sprintf("%v", err)
-		}
-		output.Error("", errorMsg)
-		os.Exit(1)
+// ReadPasswordFromFd reads the password from the first line of the given file
+// descriptor (e.g. raptor encrypt --pwd-fd 3 3<pwd.txt)
+func ReadPasswordFromFd(fd int) (string, error) {
+	f := os.NewFile(uintptr(fd), fmt.Sprintf("fd%d", fd))
+	if f == nil {
+		return "", fmt.Errorf("invalid file descriptor %d", fd)
 	}
+	defer f.Close()
+	line, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil && len(line) == 0 {
+		return "", fmt.Errorf("failed to read the password from the file descriptor %d: %v", fd, err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// ReadPasswordFromEnv reads the password from the given environment variable
+func ReadPasswordFromEnv(name string) (string, error) {
+	key, ok := os.LookupEnv(name)
+	if !ok || len(key) == 0 {
+		return "", fmt.Errorf("the env variable %s is empty", name)
+	}
+	return key, nil
+}
+
+// ReadPassword reads the standard input in hidden mode. The text is printed on
+// the standard error to keep the standard output clean for the piped data
+func ReadPassword(text string) (string, error) {
+	fmt.Fprint(os.Stderr, text)
+	buf, err := term.ReadPassword(int(os.Stdin.Fd()))
+	return string(buf), err
 }
 
 // GetText returns a text read from a bufio.Reader interface object
@@ -150,13 +138,13 @@ func AskForPassword(text string, twice bool) (key string, err error) {
 		if err != nil {
 			return "", err
 		}
-		fmt.Println("")
+		fmt.Fprintln(os.Stderr, "")
 		if twice {
 			key2, err := ReadPassword("Repeat the pwd:")
 			if err != nil {
 				return "", err
 			}
-			fmt.Println("")
+			fmt.Fprintln(os.Stderr, "")
 			if key != key2 {
 				return "", fmt.Errorf("the passwords do not correspond")
 			}
@@ -221,79 +209,133 @@ func InitFolderBox() (string, error) {
 	return abs, nil
 }
 
-// OpenBox opens a box
-func OpenBox(boxName, pwd string) (string, string, *Box, error) {
-	// if the box is in the buffer you can get into it
-	if BufferBox != nil {
-		return BoxPath, BoxPwd, BufferBox, nil
-	}
+// sessionKey is the context key of the vault of a session
+type sessionKey struct{}
+
+// WithSession returns a context carrying the box kept open by the interactive
+// mode, the commands run with it use that box instead of opening one
+func WithSession(ctx context.Context, v *vault.Vault) context.Context {
+	return context.WithValue(ctx, sessionKey{}, v)
+}
+
+// Session returns the box kept open by the interactive mode, nil out of it
+func Session(ctx context.Context) *vault.Vault {
+	v, _ := ctx.Value(sessionKey{}).(*vault.Vault)
+	return v
+}
 
-	// check if the boxName is a file, in that case BoxPath is overriden by that
-	if validPath, _ := IsValidFilePath(boxName); validPath {
-		BoxPath = boxName
+// OpenBox opens a box, the one of the session if the context has it. The box
+// is a file path, or the name of a box of the store given with --store or
+// RAPTOR_STORE (the box folder by default). The password is asked when pwd is
+// empty, the key file is read when the box requires it.
+func OpenBox(ctx context.Context, boxName, pwd string) (*vault.Vault, error) {
+	if v := Session(ctx); v != nil {
+		return v, nil
 	}
 
 	// search the CRYPTEX_BOX env if name is empty
 	if len(boxName) == 0 {
 		boxName = os.Getenv("CRYPTEX_BOX")
 		if len(boxName) == 0 {
-			return "", "", nil, fmt.Errorf("--box args is not given and the env var CRYPTEX_BOX is empty")
+			return nil, fmt.Errorf("--box args is not given and the env var CRYPTEX_BOX is empty")
 		}
 	}
 
-	// get the folder box
-	boxFolder, err := InitFolderBox()
-	if err != nil {
-		return "", "", nil, err
+	// the key is asked only after the box has been found
+	kp := vault.KeyFunc(func(_ context.Context, _ string, keyFile bool) (vault.Key, error) {
+		var err error
+		if len(pwd) == 0 {
+			if pwd, err = AskForPassword("Password: ", false); err != nil {
+				return vault.Key{}, err
+			}
+		}
+		key := vault.Key{Passphrase: pwd}
+		// the key file is combined with the password, not with an age secret key
+		if keyFile && !strings.HasPrefix(pwd, security.AgeIdentityPrefix) {
+			if key.KeyFile, err = KeyFileDigest(); err != nil {
+				return vault.Key{}, err
+			}
+		}
+		return key, nil
+	})
+
+	var (
+		v   *vault.Vault
+		err error
+	)
+	file, _ := IsValidFilePath(boxName)
+	switch {
+	case file:
+		v, err = vault.Open(ctx, boxName, kp)
+	case len(storeURL()) > 0:
+		var store vault.BoxStore
+		if store, err = vault.NewStore(storeURL()); err != nil {
+			return nil, err
+		}
+		v, err = vault.OpenIn(ctx, store, boxName, kp)
+	default:
+		var folder string
+		if folder, err = InitFolderBox(); err != nil {
+			return nil, err
+		}
+		v, err = vault.Open(ctx, filepath.Join(folder, boxName), kp)
 	}
-
-	// read the box (if it is not assigned yet)
-	if len(BoxPath) == 0 {
-		BoxPath = path.Join(boxFolder, boxName)
+	if errors.Is(err, vault.ErrKeyFileRequired) {
+		return nil, fmt.Errorf("%w, use --keyfile or RAPTOR_KEYFILE", err)
 	}
+	return v, err
+}
 
-	in, err := ioutil.ReadFile(BoxPath)
-	if err != nil {
-		return "", "", nil, fmt.Errorf("reading the file box in %s: %v", BoxPath, err)
+// CloseBox closes the box opened by OpenBox, wiping it and its key from
+// memory. The box of the session stays open.
+func CloseBox(ctx context.Context, v *vault.Vault) {
+	if v != nil && v != Session(ctx) {
+		v.Close()
 	}
+}
 
-	if len(pwd) == 0 {
-		// ask for the password
-		pwd, err = AskForPassword("Password: ", false)
-		if err != nil {
-			return "", "", nil, err
-		}
+// KeyFileDigest returns the digest of the key file given with --keyfile or
+// RAPTOR_KEYFILE, nil when no key file is given
+func KeyFileDigest() ([]byte, error) {
+	path := KeyFilePath
+	if len(path) == 0 {
+		path = os.Getenv("RAPTOR_KEYFILE")
 	}
-
-	// encrypt the box
-	decIn, err := security.DecryptBox(in, pwd)
-	if err != nil {
-		return "", "", nil, fmt.Errorf("decrypting the file box in %s: %v", BoxPath, err)
+	if len(path) == 0 {
+		return nil, nil
 	}
+	return security.ReadKeyFile(path)
+}
 
-	box := &Box{}
-	err = yaml.Unmarshal(decIn, box)
+// WithKeyFile combines the password with the key file, if given
+func WithKeyFile(pwd []byte) ([]byte, error) {
+	digest, err := KeyFileDigest()
 	if err != nil {
-		return "", "", nil, fmt.Errorf("failed to read the box: %v. Maybe an incorrect pwd?", err)
+		return nil, err
 	}
-	BoxPwd = pwd
-	return BoxPath, pwd, box, nil
+	return security.WithKeyFile(pwd, digest)
 }
 
-func SaveBox(path, key string, box *Box) error {
-	out, err := yaml.Marshal(box)
-	if err != nil {
-		return fmt.Errorf("failed to encode the box: %v", err)
+// storeURL returns the store given with --store or RAPTOR_STORE, empty for
+// the box folder
+func storeURL() string {
+	if len(StoreURL) > 0 {
+		return StoreURL
 	}
-	// encrypt the box
-	encOut, err := security.EncryptBox(out, key)
-	if err := ioutil.WriteFile(path, encOut, 0644); err != nil {
-		return fmt.Errorf("failed to encrypt the box: %v", err)
+	return os.Getenv("RAPTOR_STORE")
+}
+
+// Store returns the store of the boxes: the one given with --store or
+// RAPTOR_STORE, the box folder by default
+func Store() (vault.BoxStore, error) {
+	if u := storeURL(); len(u) > 0 {
+		return vault.NewStore(u)
 	}
-	if err := ioutil.WriteFile(path, encOut, 0644); err != nil {
-		return fmt.Errorf("failed to write the box: %v", err)
+	folder, err := InitFolderBox()
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	return vault.NewFileStore(folder), nil
 }
 
 func IsValidFilePath(path string) (bool, error) {
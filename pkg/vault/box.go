@@ -0,0 +1,279 @@
+package vault
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+
+	"github.com/mas2020-golang/cryptex/packages/security"
+)
+
+// Secret is a secret of a box. The password, the notes and the items (Pwd,
+// Notes and Others) are in locked memory until the secret is loaded, see Load.
+type Secret struct {
+	Name        string            `yaml:"name,omitempty"`
+	Id          int32             `yaml:"id,omitempty"` // Unique ID number for this secret
+	Pwd         string            `yaml:"pwd,omitempty"`
+	Url         string            `yaml:"url,omitempty"`
+	Notes       string            `yaml:"notes,omitempty"`
+	Others      map[string]string `yaml:"others,omitempty"`
+	Version     string            `yaml:"version,omitempty"`
+	Login       string            `yaml:"login,omitempty"`
+	LastUpdated string            `yaml:"lastUpdated,omitempty"`
+	Tags        []string          `yaml:"tags,omitempty"`
+	// sealed holds Pwd, Notes and Others until the secret is loaded
+	sealed *security.LockedBuffer
+	// record holds them encrypted for a box of the indexed layout, digest is
+	// the hash of the decrypted record telling if the secret changed
+	record    []byte
+	recordKey *security.RecordKey
+	digest    [32]byte
+	loaded    bool
+	// items are the keys of Others while the secret is not loaded
+	items []string
+	// encoding is the encoding of record and sealed
+	encoding string
+	// metaOnly is true for the copies given by Vault.List, without values
+	metaOnly bool
+}
+
+// Box is the content of a box: its metadata and secrets
+type Box struct {
+	Name        string    `yaml:"name,omitempty"`
+	Version     string    `yaml:"version,omitempty"`
+	LastUpdated string    `yaml:"lastUpdated,omitempty"`
+	Owner       string    `yaml:"owner,omitempty"`
+	Secrets     []*Secret `yaml:"secrets,omitempty"`
+	Policy      *Policy   `yaml:"policy,omitempty"`
+	Size        int64     `yaml:"-"`
+	// Keyring holds the members of a shared box, nil for a box sealed with
+	// a single password
+	Keyring *security.Keyring `yaml:"-"`
+	// KeyFile is true when the box can be opened with the key file only
+	KeyFile bool `yaml:"-"`
+	// Layout is LayoutSingle (default) or LayoutIndexed
+	Layout string `yaml:"-"`
+	// Encoding is EncodingProto or EncodingYAML, Seal migrates the box to
+	// protobuf
+	Encoding string `yaml:"-"`
+
+	// names maps the names to the secrets, see Secret
+	names     map[string]*Secret
+	recordKey *security.RecordKey
+}
+
+// Box layouts
+const (
+	// LayoutSingle seals the whole box at once
+	LayoutSingle = "single"
+	// LayoutIndexed seals the index of the box (names, tags and metadata)
+	// and the password, notes and items of every secret separately
+	LayoutIndexed = "indexed"
+)
+
+// ValidLayout returns an error if the layout is not supported
+func ValidLayout(layout string) error {
+	if layout != LayoutSingle && layout != LayoutIndexed {
+		return fmt.Errorf("invalid layout %q: single or indexed", layout)
+	}
+	return nil
+}
+
+// Policy restricts what leaves the box
+type Policy struct {
+	// NotExportable are the patterns (path.Match) of the names of the secrets
+	// whose values are never given by serve or written by export
+	NotExportable []string `yaml:"notExportable,omitempty"`
+}
+
+// Exportable is false when a pattern of the policy of the box matches the name
+// of the secret
+func (b *Box) Exportable(name string) bool {
+	if b.Policy == nil {
+		return true
+	}
+	for _, pattern := range b.Policy.NotExportable {
+		if ok, _ := path.Match(pattern, name); ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Secret returns the secret with the given name, nil if the box doesn't have
+// it. The lookup uses a map of the names, built again when a secret has been
+// added, removed or renamed without the methods of the box.
+func (b *Box) Secret(name string) *Secret {
+	if s, ok := b.names[name]; ok && s.Name == name && len(b.names) == len(b.Secrets) {
+		return s
+	}
+	b.reindex()
+	return b.names[name]
+}
+
+// Put adds the secret to the box, replacing the one with the same name
+func (b *Box) Put(s *Secret) {
+	if old := b.Secret(s.Name); old != nil {
+		b.Secrets[slices.Index(b.Secrets, old)] = s
+	} else {
+		b.Secrets = append(b.Secrets, s)
+	}
+	b.names[s.Name] = s
+}
+
+// Remove removes the secret with the given name from the box and returns it,
+// nil if the box doesn't have it
+func (b *Box) Remove(name string) *Secret {
+	s := b.Secret(name)
+	if s == nil {
+		return nil
+	}
+	i := slices.Index(b.Secrets, s)
+	b.Secrets = slices.Delete(b.Secrets, i, i+1)
+	delete(b.names, name)
+	return s
+}
+
+// reindex builds the map of the names, the first secret wins on duplicates
+func (b *Box) reindex() {
+	b.names = make(map[string]*Secret, len(b.Secrets))
+	for _, s := range b.Secrets {
+		if _, ok := b.names[s.Name]; !ok {
+			b.names[s.Name] = s
+		}
+	}
+}
+
+// Items returns the sorted keys of the items of the secret, known from the
+// index even when the secret is not loaded
+func (s *Secret) Items() []string {
+	if !s.isLoaded() || s.metaOnly {
+		return s.items
+	}
+	keys := make([]string, 0, len(s.Others))
+	for k := range s.Others {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// indexEntry is a secret in the YAML index of the indexed layout, the password, the
+// notes and the item values are in its record
+type indexEntry struct {
+	Name        string   `yaml:"name,omitempty"`
+	Id          int32    `yaml:"id,omitempty"`
+	Url         string   `yaml:"url,omitempty"`
+	Version     string   `yaml:"version,omitempty"`
+	Login       string   `yaml:"login,omitempty"`
+	LastUpdated string   `yaml:"lastUpdated,omitempty"`
+	Tags        []string `yaml:"tags,omitempty"`
+	Items       []string `yaml:"items,omitempty"`
+}
+
+type boxIndex struct {
+	Name        string        `yaml:"name,omitempty"`
+	Version     string        `yaml:"version,omitempty"`
+	LastUpdated string        `yaml:"lastUpdated,omitempty"`
+	Owner       string        `yaml:"owner,omitempty"`
+	Secrets     []*indexEntry `yaml:"secrets,omitempty"`
+	Policy      *Policy       `yaml:"policy,omitempty"`
+}
+
+// openIndexed decrypts the index of a box of the indexed layout, the records
+// of the secrets are decrypted when they are loaded
+func openIndexed(in []byte, pwd []byte, encoding string) (*Box, error) {
+	rk, data, records, err := security.OpenIndexedBox(in, pwd)
+	if err != nil {
+		return nil, openError(err)
+	}
+	box, err := decodeIndex(data, encoding)
+	clear(data)
+	if err != nil {
+		rk.Destroy()
+		return nil, fmt.Errorf("%w: failed to read the index of the box: %v", ErrCorrupt, err)
+	}
+	if len(box.Secrets) != len(records) {
+		rk.Destroy()
+		return nil, fmt.Errorf("%w: %v", ErrCorrupt, security.ErrRecord)
+	}
+
+	box.Layout, box.Encoding = LayoutIndexed, encoding
+	box.Keyring, box.recordKey = rk.Keyring(), rk
+	for i, s := range box.Secrets {
+		s.record, s.recordKey, s.encoding = records[i], rk, encoding
+	}
+	return box, nil
+}
+
+// sealIndexed encrypts the box in the indexed layout: the records of the
+// secrets not loaded or not changed are kept as they are
+func sealIndexed(box *Box, key []byte) ([]byte, error) {
+	rk := box.recordKey
+	if !rk.Matches(key, box.Keyring) {
+		var err error
+		if rk, err = security.NewRecordKey(key, box.Keyring); err != nil {
+			return nil, err
+		}
+	}
+
+	records := make([][]byte, 0, len(box.Secrets))
+	for _, s := range box.Secrets {
+		record, err := s.seal(rk, box.Encoding)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	data, err := encodeIndex(box, box.Encoding)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the index of the box: %v", err)
+	}
+	defer clear(data)
+	out, err := security.SealIndexedBox(rk, data, records)
+	if err != nil {
+		return nil, err
+	}
+	if rk != box.recordKey {
+		box.recordKey.Destroy()
+		box.recordKey = rk
+	}
+	return out, nil
+}
+
+// seal returns the record of the secret sealed with the key in the encoding,
+// the current one when the secret has not been changed
+func (s *Secret) seal(rk *security.RecordKey, encoding string) ([]byte, error) {
+	if s.record != nil && s.recordKey == rk && s.encoding == encoding && s.sealed == nil {
+		if !s.loaded {
+			return s.record, nil
+		}
+		out, err := s.encode()
+		if err != nil {
+			return nil, err
+		}
+		changed := sha256.Sum256(out) != s.digest
+		clear(out)
+		if !changed {
+			return s.record, nil
+		}
+	}
+
+	if err := s.Load(); err != nil {
+		return nil, err
+	}
+	s.encoding = encoding
+	out, err := s.encode()
+	if err != nil {
+		return nil, err
+	}
+	defer clear(out)
+	record, err := rk.Seal(out)
+	if err != nil {
+		return nil, err
+	}
+	s.record, s.recordKey, s.digest, s.loaded = record, rk, sha256.Sum256(out), true
+	return record, nil
+}
@@ -0,0 +1,132 @@
+package vault
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mas2020-golang/cryptex/packages/security"
+)
+
+// TestBox_Secret tests the lookups of the secrets by name
+func TestBox_Secret(t *testing.T) {
+	box := &Box{Secrets: []*Secret{{Name: "github"}, {Name: "gitlab"}}}
+	if s := box.Secret("gitlab"); s == nil || s != box.Secrets[1] {
+		t.Fatalf("Expected gitlab, got %v", s)
+	}
+	box.Put(&Secret{Name: "gitlab", Login: "me"})
+	box.Put(&Secret{Name: "bank"})
+	if len(box.Secrets) != 3 || box.Secret("gitlab").Login != "me" {
+		t.Fatalf("Expected gitlab replaced and bank added, got %d secrets", len(box.Secrets))
+	}
+	// a secret renamed or appended without the methods of the box is found
+	box.Secrets[0].Name = "github.com"
+	box.Secrets = append(box.Secrets, &Secret{Name: "new"})
+	if box.Secret("github") != nil || box.Secret("github.com") == nil || box.Secret("new") == nil {
+		t.Error("Expected the lookups to follow the changes of the secrets")
+	}
+	if box.Remove("bank") == nil || box.Secret("bank") != nil || len(box.Secrets) != 3 {
+		t.Error("Expected bank removed")
+	}
+	if box.Remove("missing") != nil {
+		t.Error("Expected nil removing a missing secret")
+	}
+}
+
+// TestIndexed tests that a box of the indexed layout is listed without
+// decrypting the records and only the changed records are sealed again
+func TestIndexed(t *testing.T) {
+	for _, encoding := range []string{EncodingYAML, EncodingProto} {
+		t.Run(encoding, func(t *testing.T) { testIndexed(t, encoding) })
+	}
+}
+
+func testIndexed(t *testing.T, encoding string) {
+	box := &Box{Name: "test", Layout: LayoutIndexed, Encoding: encoding, Secrets: []*Secret{
+		{Name: "github", Pwd: "pwd1", Others: map[string]string{"token": "x", "api": "y"}},
+		{Name: "gitlab", Pwd: "pwd2", Tags: []string{"work"}},
+	}}
+	out, err := sealIndexed(box, []byte("pwd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opened, err := openIndexed(out, []byte("pwd"), encoding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	github, gitlab := opened.Secret("github"), opened.Secret("gitlab")
+	if github.Pwd != "" || github.isLoaded() {
+		t.Fatal("Expected the records to be decrypted only when loaded")
+	}
+	if items := github.Items(); len(items) != 2 || items[0] != "api" || gitlab.Tags[0] != "work" {
+		t.Errorf("Expected the items and tags from the index, got %v and %v", items, gitlab.Tags)
+	}
+	if err := gitlab.Load(); err != nil || gitlab.Pwd != "pwd2" {
+		t.Fatalf("Expected the password of gitlab, got %q (%v)", gitlab.Pwd, err)
+	}
+
+	// gitlab is loaded but not changed, github is changed
+	github.Load()
+	github.Pwd = "changed"
+	github.Hide()
+	saved, err := sealIndexed(opened, []byte("pwd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, before, _ := security.OpenIndexedBox(out, []byte("pwd"))
+	_, _, after, err := security.OpenIndexedBox(saved, []byte("pwd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(before[0], after[0]) || !bytes.Equal(before[1], after[1]) {
+		t.Error("Expected only the record of github to be sealed again")
+	}
+
+	// a new password seals every record again
+	changed, err := sealIndexed(opened, []byte("new-pwd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := openIndexed(changed, []byte("new-pwd"), encoding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := reopened.Secret("github"); s.Load() != nil || s.Pwd != "changed" || s.Others["token"] != "x" {
+		t.Errorf("Expected the changed github, got %+v", s)
+	}
+	opened.Wipe()
+	reopened.Wipe()
+}
+
+// TestEncodeBox tests that a box is decoded back from both the encodings and
+// the times are kept
+func TestEncodeBox(t *testing.T) {
+	box := &Box{Name: "test", Owner: "me", LastUpdated: "2025-10-28T10:00:00Z", Secrets: []*Secret{
+		{Name: "github", Pwd: "pwd", Notes: "notes", Others: map[string]string{"token": "x"}, Tags: []string{"work"}, LastUpdated: "2025-10-29T11:30:00+02:00"},
+	}}
+	for _, encoding := range []string{EncodingYAML, EncodingProto} {
+		out, err := encodeBox(box, encoding)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := decodeBox(out, encoding)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := got.Secrets[0]
+		if got.Owner != "me" || s.Pwd != "pwd" || s.Notes != "notes" || s.Others["token"] != "x" || s.Tags[0] != "work" {
+			t.Errorf("%s: expected the box decoded back, got %+v", encoding, s)
+		}
+		if got.LastUpdated != box.LastUpdated || s.LastUpdated != box.Secrets[0].LastUpdated {
+			t.Errorf("%s: expected the times kept as written, got %s and %s", encoding, got.LastUpdated, s.LastUpdated)
+		}
+	}
+	// a time not in RFC 3339 is kept as it is
+	box.Secrets[0].LastUpdated = "2023-01-01"
+	out, err := encodeBox(box, EncodingProto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := decodeBox(out, EncodingProto); err != nil || got.Secrets[0].LastUpdated != "2023-01-01" {
+		t.Errorf("Expected the time kept as written, got %+v (%v)", got, err)
+	}
+}
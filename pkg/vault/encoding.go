@@ -0,0 +1,206 @@
+package vault
+
+import (
+	"time"
+
+	"github.com/mas2020-golang/cryptex/packages/protos"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gopkg.in/yaml.v2"
+)
+
+// Box encodings
+const (
+	// EncodingYAML is the encoding of the boxes written by the previous
+	// versions, they are migrated to protobuf on save
+	EncodingYAML = "yaml"
+	// EncodingProto encodes the box with the messages of protos/box.proto
+	EncodingProto = "protobuf"
+)
+
+// the map fields are sorted so that an unchanged record is encoded again with
+// the same bytes
+var protoOptions = proto.MarshalOptions{Deterministic: true}
+
+// encodeBox encodes the box of the single layout, the secrets are loaded
+func encodeBox(box *Box, encoding string) ([]byte, error) {
+	if encoding != EncodingProto {
+		return yaml.Marshal(box)
+	}
+	pb := boxToProto(box)
+	for i, s := range box.Secrets {
+		pb.Secrets[i].Pwd, pb.Secrets[i].Notes, pb.Secrets[i].Others = s.Pwd, s.Notes, s.Others
+	}
+	return protoOptions.Marshal(pb)
+}
+
+// decodeBox decodes the box of the single layout
+func decodeBox(data []byte, encoding string) (*Box, error) {
+	box := &Box{}
+	if encoding != EncodingProto {
+		return box, yaml.Unmarshal(data, box)
+	}
+	pb := &protos.Box{}
+	if err := proto.Unmarshal(data, pb); err != nil {
+		return nil, err
+	}
+	box = boxFromProto(pb)
+	for i, s := range pb.Secrets {
+		box.Secrets[i].Pwd, box.Secrets[i].Notes, box.Secrets[i].Others = s.Pwd, s.Notes, s.Others
+	}
+	return box, nil
+}
+
+// encodeIndex encodes the index of a box of the indexed layout: the metadata
+// of the secrets and the keys of their items
+func encodeIndex(box *Box, encoding string) ([]byte, error) {
+	if encoding != EncodingProto {
+		index := boxIndex{Name: box.Name, Version: box.Version, LastUpdated: box.LastUpdated, Owner: box.Owner, Policy: box.Policy}
+		for _, s := range box.Secrets {
+			index.Secrets = append(index.Secrets, &indexEntry{
+				Name:        s.Name,
+				Id:          s.Id,
+				Url:         s.Url,
+				Version:     s.Version,
+				Login:       s.Login,
+				LastUpdated: s.LastUpdated,
+				Tags:        s.Tags,
+				Items:       s.Items(),
+			})
+		}
+		return yaml.Marshal(index)
+	}
+	pb := boxToProto(box)
+	for i, s := range box.Secrets {
+		pb.Secrets[i].Items = s.Items()
+	}
+	return protoOptions.Marshal(pb)
+}
+
+// decodeIndex decodes the index of a box of the indexed layout, the secrets
+// have no record yet
+func decodeIndex(data []byte, encoding string) (*Box, error) {
+	if encoding != EncodingProto {
+		var index boxIndex
+		if err := yaml.Unmarshal(data, &index); err != nil {
+			return nil, err
+		}
+		box := &Box{Name: index.Name, Version: index.Version, LastUpdated: index.LastUpdated, Owner: index.Owner, Policy: index.Policy}
+		for _, e := range index.Secrets {
+			box.Secrets = append(box.Secrets, &Secret{
+				Name:        e.Name,
+				Id:          e.Id,
+				Url:         e.Url,
+				Version:     e.Version,
+				Login:       e.Login,
+				LastUpdated: e.LastUpdated,
+				Tags:        e.Tags,
+				items:       e.Items,
+			})
+		}
+		return box, nil
+	}
+	pb := &protos.Box{}
+	if err := proto.Unmarshal(data, pb); err != nil {
+		return nil, err
+	}
+	box := boxFromProto(pb)
+	for i, s := range pb.Secrets {
+		box.Secrets[i].items = s.Items
+	}
+	return box, nil
+}
+
+// encodeRecord encodes the sensitive fields of a secret
+func encodeRecord(d secretData, encoding string) ([]byte, error) {
+	if encoding != EncodingProto {
+		return yaml.Marshal(d)
+	}
+	return protoOptions.Marshal(&protos.Record{Pwd: d.Pwd, Notes: d.Notes, Others: d.Others})
+}
+
+// decodeRecord decodes the sensitive fields of a secret
+func decodeRecord(data []byte, encoding string) (secretData, error) {
+	var d secretData
+	if encoding != EncodingProto {
+		return d, yaml.Unmarshal(data, &d)
+	}
+	var pb protos.Record
+	if err := proto.Unmarshal(data, &pb); err != nil {
+		return d, err
+	}
+	return secretData{Pwd: pb.Pwd, Notes: pb.Notes, Others: pb.Others}, nil
+}
+
+// boxToProto returns the box and the metadata of its secrets as protobuf
+// messages
+func boxToProto(box *Box) *protos.Box {
+	updated, text := toTimestamp(box.LastUpdated)
+	pb := &protos.Box{Name: box.Name, Version: box.Version, LastUpdated: updated, LastUpdatedText: text, Owner: box.Owner}
+	if box.Policy != nil {
+		pb.Policy = &protos.Policy{NotExportable: box.Policy.NotExportable}
+	}
+	for _, s := range box.Secrets {
+		updated, text := toTimestamp(s.LastUpdated)
+		pb.Secrets = append(pb.Secrets, &protos.Secret{
+			Name:            s.Name,
+			Id:              s.Id,
+			Url:             s.Url,
+			Version:         s.Version,
+			Login:           s.Login,
+			LastUpdated:     updated,
+			LastUpdatedText: text,
+			Tags:            s.Tags,
+		})
+	}
+	return pb
+}
+
+// boxFromProto returns the box and the metadata of its secrets
+func boxFromProto(pb *protos.Box) *Box {
+	box := &Box{Name: pb.Name, Version: pb.Version, LastUpdated: fromTimestamp(pb.LastUpdated, pb.LastUpdatedText), Owner: pb.Owner}
+	if pb.Policy != nil {
+		box.Policy = &Policy{NotExportable: pb.Policy.NotExportable}
+	}
+	for _, s := range pb.Secrets {
+		box.Secrets = append(box.Secrets, &Secret{
+			Name:        s.Name,
+			Id:          s.Id,
+			Url:         s.Url,
+			Version:     s.Version,
+			Login:       s.Login,
+			LastUpdated: fromTimestamp(s.LastUpdated, s.LastUpdatedText),
+			Tags:        s.Tags,
+		})
+	}
+	return box
+}
+
+// toTimestamp parses a RFC 3339 time, nil when it's empty or not a time. The
+// text is returned too when the timestamp doesn't give it back (another
+// format or time zone), so that the time is kept as it was written.
+func toTimestamp(s string) (*timestamppb.Timestamp, string) {
+	if len(s) == 0 {
+		return nil, ""
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, s
+	}
+	if t.UTC().Format(time.RFC3339) == s {
+		return timestamppb.New(t), ""
+	}
+	return timestamppb.New(t), s
+}
+
+// fromTimestamp returns the time as it was written, the timestamp formats it
+// as RFC 3339 in UTC when there is no text
+func fromTimestamp(t *timestamppb.Timestamp, text string) string {
+	if len(text) > 0 {
+		return text
+	}
+	if t == nil {
+		return ""
+	}
+	return t.AsTime().UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,48 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mas2020-golang/cryptex/packages/security"
+)
+
+// The errors of the vault, test them with errors.Is
+var (
+	// ErrBoxNotFound is returned when the source of the box doesn't exist
+	ErrBoxNotFound = errors.New("box not found")
+	// ErrBoxExists is returned by Create when the source already exists
+	ErrBoxExists = errors.New("the box already exists")
+	// ErrWrongPassword is returned when the key doesn't open the box
+	ErrWrongPassword = errors.New("wrong password")
+	// ErrKeyFileRequired is returned when the box needs a key file with the
+	// password and the key has none
+	ErrKeyFileRequired = errors.New("the box requires a key file")
+	// ErrSecretNotFound is returned when the box has no secret with the name
+	ErrSecretNotFound = errors.New("secret not found")
+	// ErrCorrupt is returned when the box is truncated, altered or can't be
+	// decoded
+	ErrCorrupt = errors.New("the box is corrupt")
+	// ErrClosed is returned by the methods of a closed vault
+	ErrClosed = errors.New("the vault is closed")
+	// ErrConflict is returned when the box has been changed in the store
+	// since it was read, the changes have to be made again on the new version
+	ErrConflict = errors.New("the box has been changed by someone else since it was opened")
+	// ErrNoVersion is returned when a box is saved but the store gave no
+	// version of it: it can't be written without the risk of overwriting the
+	// changes of someone else, it has to be opened again
+	ErrNoVersion = errors.New("the store gave no version of the box, open it again before saving")
+	// ErrLocked is returned when a session ends with the box locked after a
+	// time of inactivity
+	ErrLocked = errors.New("the box is locked")
+)
+
+// openError returns the error decrypting a box as ErrWrongPassword or
+// ErrCorrupt, keeping the detail: AES-GCM can't tell a wrong key from altered
+// data, the failed authentication is taken as a wrong password
+func openError(err error) error {
+	if errors.Is(err, security.ErrTruncated) || errors.Is(err, security.ErrRecord) || errors.Is(err, security.ErrFormat) {
+		return fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	return fmt.Errorf("%w: %v", ErrWrongPassword, err)
+}
@@ -0,0 +1,140 @@
+package vault
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"maps"
+	"runtime"
+	"slices"
+
+	"github.com/mas2020-golang/cryptex/packages/security"
+)
+
+// secretData are the sensitive fields of a secret, kept encoded in locked
+// memory until the secret is loaded
+type secretData struct {
+	Pwd    string            `yaml:"pwd,omitempty"`
+	Notes  string            `yaml:"notes,omitempty"`
+	Others map[string]string `yaml:"others,omitempty"`
+}
+
+// Load decodes the password, the notes and the items of the secret from the
+// locked memory or decrypts its record, it does nothing on a secret already
+// loaded
+func (s *Secret) Load() error {
+	if s == nil || s.isLoaded() {
+		return nil
+	}
+	out := s.sealed.Bytes()
+	if s.sealed == nil {
+		var err error
+		if out, err = s.recordKey.Open(s.record); err != nil {
+			return fmt.Errorf("%w: failed to decrypt the secret %s: %v", ErrCorrupt, s.Name, err)
+		}
+		defer clear(out)
+	}
+	d, err := decodeRecord(out, s.encoding)
+	if err != nil {
+		return fmt.Errorf("%w: failed to decode the secret %s: %v", ErrCorrupt, s.Name, err)
+	}
+	if s.sealed != nil {
+		s.sealed.Destroy()
+		s.sealed = nil
+	} else {
+		// the record is sealed again only if the secret changes
+		s.digest, s.loaded = sha256.Sum256(out), true
+	}
+	s.Pwd, s.Notes, s.Others = d.Pwd, d.Notes, d.Others
+	return nil
+}
+
+// Hide moves the password, the notes and the items of the secret into locked
+// memory, Load gives them back. The secret of an indexed box not changed
+// keeps its record only.
+func (s *Secret) Hide() error {
+	if s == nil || !s.isLoaded() {
+		return nil
+	}
+	out, err := s.encode()
+	if err != nil {
+		return err
+	}
+	items := s.Items()
+	if s.record == nil || sha256.Sum256(out) != s.digest {
+		if s.sealed, err = security.NewLockedBufferFrom(out); err != nil {
+			return err
+		}
+		s.record = nil
+	}
+	clear(out)
+	s.items = items
+	s.Pwd, s.Notes, s.Others, s.loaded = "", "", nil, false
+	return nil
+}
+
+// Wipe removes the sensitive fields of the secret from memory
+func (s *Secret) Wipe() {
+	s.sealed.Destroy()
+	s.Pwd, s.Notes, s.Others, s.sealed, s.record, s.loaded = "", "", nil, nil, nil, false
+}
+
+// Clone returns a deep copy of the secret, the values in locked memory are
+// copied into a new locked buffer
+func (s *Secret) Clone() (*Secret, error) {
+	c := *s
+	c.Others, c.Tags = maps.Clone(s.Others), slices.Clone(s.Tags)
+	c.record, c.items = slices.Clone(s.record), slices.Clone(s.items)
+	if s.sealed != nil {
+		var err error
+		if c.sealed, err = security.NewLockedBuffer(len(s.sealed.Bytes())); err != nil {
+			return nil, err
+		}
+		copy(c.sealed.Bytes(), s.sealed.Bytes())
+		runtime.KeepAlive(s.sealed)
+	}
+	return &c, nil
+}
+
+// isLoaded is true when Pwd, Notes and Others hold the values of the secret
+func (s *Secret) isLoaded() bool {
+	return s.sealed == nil && (s.record == nil || s.loaded)
+}
+
+func (s *Secret) encode() ([]byte, error) {
+	out, err := encodeRecord(secretData{Pwd: s.Pwd, Notes: s.Notes, Others: s.Others}, s.encoding)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the secret %s: %v", s.Name, err)
+	}
+	return out, nil
+}
+
+// Load decodes all the secrets of the box
+func (b *Box) Load() error {
+	for _, s := range b.Secrets {
+		if err := s.Load(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Hide moves the sensitive fields of all the secrets into locked memory
+func (b *Box) Hide() error {
+	for _, s := range b.Secrets {
+		if err := s.Hide(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Wipe removes the sensitive fields of all the secrets from memory
+func (b *Box) Wipe() {
+	if b == nil {
+		return
+	}
+	for _, s := range b.Secrets {
+		s.Wipe()
+	}
+	b.recordKey.Destroy()
+}
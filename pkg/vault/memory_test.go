@@ -0,0 +1,49 @@
+package vault
+
+import "testing"
+
+// TestSecret_Hide tests that the sensitive fields are moved into locked
+// memory and decoded again by Load
+func TestSecret_Hide(t *testing.T) {
+	s := &Secret{Name: "github", Login: "me", Pwd: "pwd", Notes: "notes", Others: map[string]string{"token": "x"}}
+	if err := s.Hide(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Pwd != "" || s.Notes != "" || s.Others != nil || s.Login != "me" {
+		t.Fatalf("Expected only the sensitive fields to be hidden, got %+v", s)
+	}
+	if err := s.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Pwd != "pwd" || s.Notes != "notes" || s.Others["token"] != "x" {
+		t.Fatalf("Expected the fields to be decoded, got %+v", s)
+	}
+	// a loaded secret is sealed again with its changes
+	s.Pwd = "new"
+	s.Hide()
+	s.Load()
+	if s.Pwd != "new" {
+		t.Errorf("Expected the changed password, got %q", s.Pwd)
+	}
+	s.Hide()
+	s.Wipe()
+	if s.Load(); s.Pwd != "" || s.sealed != nil {
+		t.Error("Expected the secret to be wiped")
+	}
+}
+
+// TestSecret_Clone tests that the copy of a hidden secret has its own locked
+// memory
+func TestSecret_Clone(t *testing.T) {
+	s := &Secret{Name: "github", Pwd: "pwd", Tags: []string{"dev"}}
+	s.Hide()
+	c, err := s.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Wipe()
+	c.Tags = append(c.Tags[:0], "changed")
+	if err := s.Load(); err != nil || s.Pwd != "pwd" || s.Tags[0] != "dev" {
+		t.Errorf("Expected the secret not to be changed by its copy, got %+v (%v)", s, err)
+	}
+}
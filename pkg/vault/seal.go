@@ -0,0 +1,106 @@
+package vault
+
+import (
+	"fmt"
+
+	"github.com/mas2020-golang/cryptex/packages/security"
+)
+
+// RequiresKeyFile returns true if the encrypted box can be opened only with a
+// key file, the box doesn't need to be decrypted
+func RequiresKeyFile(data []byte) bool {
+	data, _ = security.UnmarkProto(data)
+	return security.RequiresKeyFile(data)
+}
+
+// Unseal decrypts the box with the key: the password (combined with the key
+// file digest by security.WithKeyFile, if the box requires it) or the age
+// secret key of a member of a shared box. The secrets stay in locked memory.
+func Unseal(data []byte, key []byte) (*Box, error) {
+	encoding := EncodingYAML
+	if in, ok := security.UnmarkProto(data); ok {
+		data, encoding = in, EncodingProto
+	}
+
+	var (
+		box *Box
+		err error
+	)
+	if security.IsIndexedBox(data) {
+		box, err = openIndexed(data, key, encoding)
+	} else {
+		box, err = openSingle(data, key, encoding)
+	}
+	if err != nil {
+		return nil, err
+	}
+	box.KeyFile = security.RequiresKeyFile(data)
+	return box, nil
+}
+
+// Seal encrypts the box with the key in its layout and the protobuf encoding.
+// All the secrets of a box of the single layout are loaded, an indexed box
+// encrypts again only the secrets changed.
+func Seal(box *Box, key []byte) ([]byte, error) {
+	box.Encoding = EncodingProto
+	if box.Keyring != nil {
+		box.Keyring.KeyFile = box.KeyFile
+	}
+	out, err := sealBox(box, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt the box: %v", err)
+	}
+	if box.Keyring == nil && box.KeyFile {
+		out = security.MarkKeyFile(out)
+	}
+	return security.MarkProto(out), nil
+}
+
+// openSingle decrypts a box of the single layout
+func openSingle(in []byte, key []byte, encoding string) (*Box, error) {
+	var (
+		decIn   []byte
+		keyring *security.Keyring
+		err     error
+	)
+	if security.IsSharedBox(in) {
+		keyring, decIn, err = security.OpenSharedBox(in, key)
+	} else {
+		decIn, err = security.DecryptBox(in, key)
+	}
+	if err != nil {
+		return nil, openError(err)
+	}
+
+	box, err := decodeBox(decIn, encoding)
+	clear(decIn)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	// the secrets are decoded again when accessed
+	if err := box.Hide(); err != nil {
+		return nil, err
+	}
+	box.Keyring = keyring
+	box.Layout, box.Encoding = LayoutSingle, encoding
+	return box, nil
+}
+
+// sealBox encrypts the box, a shared box with its data key
+func sealBox(box *Box, key []byte) ([]byte, error) {
+	if box.Layout == LayoutIndexed {
+		return sealIndexed(box, key)
+	}
+	if err := box.Load(); err != nil {
+		return nil, err
+	}
+	out, err := encodeBox(box, box.Encoding)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the box: %v", err)
+	}
+	defer clear(out)
+	if box.Keyring != nil {
+		return security.SealSharedBox(box.Keyring, out)
+	}
+	return security.EncryptBox(out, key)
+}
@@ -0,0 +1,90 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// AnyVersion given to BoxStore.Write replaces the box whatever its version,
+// the empty version writes the box only if it doesn't exist yet
+const AnyVersion = "*"
+
+// BoxInfo describes a box of a store
+type BoxInfo struct {
+	Name     string
+	Size     int64
+	Modified time.Time
+	// Version is the ETag or the version of the box in the store
+	Version string
+}
+
+// BoxStore is where the boxes are kept: a folder, an S3-compatible bucket or
+// a WebDAV collection. The writes are conditional on the version read, so a
+// box changed by someone else in the meantime is never overwritten.
+type BoxStore interface {
+	// List returns the boxes of the store
+	List(ctx context.Context) ([]BoxInfo, error)
+	// Read returns the box and its version, ErrBoxNotFound if it doesn't
+	// exist. The version is empty when the store can't give it.
+	Read(ctx context.Context, name string) ([]byte, string, error)
+	// Write writes the box if its version in the store is still version
+	// (empty if it must not exist yet, AnyVersion to skip the check) and
+	// returns the new version, ErrConflict otherwise. The new version is
+	// empty when the store can't give it.
+	Write(ctx context.Context, name string, data []byte, version string) (string, error)
+	// Delete removes the box, ErrBoxNotFound if it doesn't exist
+	Delete(ctx context.Context, name string) error
+}
+
+// NewStore returns the store of the URL:
+//   - a path or file:///path: the boxes are the files of the folder
+//   - s3://bucket/prefix: an S3-compatible bucket, the query can give the
+//     endpoint (e.g. endpoint=http://localhost:9000 for MinIO, addressed with
+//     the path style) and the region; the credentials are read from
+//     AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN
+//   - davs://host/path or dav://host/path: a WebDAV collection over HTTPS or
+//     HTTP, the credentials are read from RAPTOR_WEBDAV_USER and
+//     RAPTOR_WEBDAV_PASSWORD
+func NewStore(rawURL string) (BoxStore, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || len(u.Scheme) < 2 {
+		// a path, also a Windows one as C:\boxes
+		return NewFileStore(rawURL), nil
+	}
+	switch u.Scheme {
+	case "file":
+		return NewFileStore(u.Path), nil
+	case "s3":
+		q := u.Query()
+		return NewS3Store(S3Config{
+			Endpoint:     q.Get("endpoint"),
+			Region:       q.Get("region"),
+			Bucket:       u.Host,
+			Prefix:       strings.Trim(u.Path, "/"),
+			AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		})
+	case "dav", "davs":
+		base := &url.URL{Scheme: "https", Host: u.Host, Path: u.Path}
+		if u.Scheme == "dav" {
+			base.Scheme = "http"
+		}
+		return NewWebDAVStore(base.String(), os.Getenv("RAPTOR_WEBDAV_USER"), os.Getenv("RAPTOR_WEBDAV_PASSWORD"))
+	}
+	return nil, fmt.Errorf("unsupported store %q: a path, file://, s3://, dav:// or davs://", rawURL)
+}
+
+// validName refuses the names that would leave the folder, the bucket prefix
+// or the collection of the store
+func validName(name string) error {
+	if name == "" || name != path.Base(name) || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid box name %q", name)
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package vault
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// lockWait is how long a write waits for the lock of the box held by another
+// writer
+const lockWait = 10 * time.Second
+
+// FileStore keeps the boxes as the files of a folder, the version of a box is
+// the SHA-256 of its content
+type FileStore struct {
+	dir string
+	// mu serializes the writes of this process, the lock file the ones of
+	// the other processes
+	mu sync.Mutex
+}
+
+// NewFileStore returns the store of the folder
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{dir: dir}
+}
+
+// String returns the folder of the store
+func (s *FileStore) String() string {
+	return s.dir
+}
+
+// List returns the files of the folder, the hidden ones are left out
+func (s *FileStore) List(ctx context.Context) ([]BoxInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the folder %s: %w", s.dir, err)
+	}
+	var boxes []BoxInfo
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		boxes = append(boxes, BoxInfo{Name: e.Name(), Size: info.Size(), Modified: info.ModTime()})
+	}
+	return boxes, nil
+}
+
+// Read returns the content of the file and its version
+func (s *FileStore) Read(ctx context.Context, name string) ([]byte, string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+	if err := validName(name); err != nil {
+		return nil, "", err
+	}
+	data, err := os.ReadFile(filepath.Join(s.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, "", fmt.Errorf("%w: %s", ErrBoxNotFound, filepath.Join(s.dir, name))
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read the box %s: %v", filepath.Join(s.dir, name), err)
+	}
+	return data, fileVersion(data), nil
+}
+
+// Write writes the file through a temporary file renamed over it, so that a
+// box is never left half written. The version is checked and the file renamed
+// holding the lock file of the box (.name.lock), the other processes can't
+// write the box in between.
+func (s *FileStore) Write(ctx context.Context, name string, data []byte, version string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if err := validName(name); err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unlock, err := s.lock(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	dst := filepath.Join(s.dir, name)
+	if version != AnyVersion {
+		current, err := os.ReadFile(dst)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			if version != "" {
+				return "", fmt.Errorf("%w: %s has been removed", ErrConflict, dst)
+			}
+		case err != nil:
+			return "", fmt.Errorf("failed to read the box %s: %v", dst, err)
+		case version != fileVersion(current):
+			return "", fmt.Errorf("%w: %s", ErrConflict, dst)
+		}
+	}
+
+	f, err := os.CreateTemp(s.dir, "."+name+".tmp-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to write the box: %v", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return "", fmt.Errorf("failed to write the box: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("failed to write the box: %v", err)
+	}
+	if err := os.Chmod(f.Name(), 0600); err != nil {
+		return "", fmt.Errorf("failed to write the box: %v", err)
+	}
+	if err := os.Rename(f.Name(), dst); err != nil {
+		return "", fmt.Errorf("failed to write the box: %v", err)
+	}
+	return fileVersion(data), nil
+}
+
+// Delete removes the file
+func (s *FileStore) Delete(ctx context.Context, name string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := validName(name); err != nil {
+		return err
+	}
+	err := os.Remove(filepath.Join(s.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrBoxNotFound, filepath.Join(s.dir, name))
+	}
+	return err
+}
+
+// lock creates the lock file of the box, waiting up to lockWait for the
+// writer holding it. It returns the function removing it.
+func (s *FileStore) lock(ctx context.Context, name string) (func(), error) {
+	path := filepath.Join(s.dir, "."+name+".lock")
+	deadline := time.Now().Add(lockWait)
+	for {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("failed to lock the box %s: %v", filepath.Join(s.dir, name), err)
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("the box %s is being written by another process, remove %s if none is", filepath.Join(s.dir, name), path)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+}
+
+func fileVersion(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,321 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Config configures an S3-compatible store
+type S3Config struct {
+	// Endpoint is the URL of the service, e.g. http://localhost:9000 for
+	// MinIO; the bucket is addressed with the path style. Empty for AWS, the
+	// bucket is addressed as a virtual host.
+	Endpoint string
+	// Region is the region of the bucket, us-east-1 by default
+	Region string
+	Bucket string
+	// Prefix is the folder of the boxes in the bucket
+	Prefix       string
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	// Client sends the requests, a client with a timeout of 30s by default
+	Client *http.Client
+}
+
+// S3Store keeps the boxes as the objects of an S3-compatible bucket, the
+// version of a box is its ETag. The conditional writes need a service
+// supporting If-Match and If-None-Match on PUT (AWS S3, MinIO).
+type S3Store struct {
+	cfg  S3Config
+	base *url.URL
+	now  func() time.Time
+}
+
+// NewS3Store returns the store of the bucket
+func NewS3Store(cfg S3Config) (*S3Store, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("the bucket of the S3 store is missing")
+	}
+	if cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, errors.New("the credentials of the S3 store are missing: set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 30 * time.Second}
+	}
+	var base *url.URL
+	if cfg.Endpoint == "" {
+		base = &url.URL{Scheme: "https", Host: fmt.Sprintf("%s.s3.%s.amazonaws.com", cfg.Bucket, cfg.Region), Path: "/"}
+	} else {
+		u, err := url.Parse(cfg.Endpoint)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid endpoint %q of the S3 store", cfg.Endpoint)
+		}
+		base = &url.URL{Scheme: u.Scheme, Host: u.Host, Path: strings.TrimSuffix(u.Path, "/") + "/" + cfg.Bucket + "/"}
+	}
+	return &S3Store{cfg: cfg, base: base, now: time.Now}, nil
+}
+
+// String returns the URL of the store
+func (s *S3Store) String() string {
+	return "s3://" + s.cfg.Bucket + "/" + s.cfg.Prefix
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		ETag         string    `xml:"ETag"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List returns the objects under the prefix, the ones in the sub folders are
+// left out
+func (s *S3Store) List(ctx context.Context) ([]BoxInfo, error) {
+	prefix := s.prefix()
+	var (
+		boxes []BoxInfo
+		token string
+	)
+	for {
+		q := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		resp, err := s.do(ctx, http.MethodGet, "", q, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var res listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&res)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the objects of the bucket %s: %v", s.cfg.Bucket, err)
+		}
+		for _, c := range res.Contents {
+			name := strings.TrimPrefix(c.Key, prefix)
+			if name == "" || strings.Contains(name, "/") {
+				continue
+			}
+			boxes = append(boxes, BoxInfo{Name: name, Size: c.Size, Modified: c.LastModified, Version: c.ETag})
+		}
+		if !res.IsTruncated || res.NextContinuationToken == "" {
+			return boxes, nil
+		}
+		token = res.NextContinuationToken
+	}
+}
+
+// Read returns the object and its ETag
+func (s *S3Store) Read(ctx context.Context, name string) ([]byte, string, error) {
+	if err := validName(name); err != nil {
+		return nil, "", err
+	}
+	resp, err := s.do(ctx, http.MethodGet, s.prefix()+name, nil, nil, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read the box %s: %v", name, err)
+	}
+	return data, resp.Header.Get("ETag"), nil
+}
+
+// Write puts the object with If-Match (the ETag read) or If-None-Match (a new
+// box)
+func (s *S3Store) Write(ctx context.Context, name string, data []byte, version string) (string, error) {
+	if err := validName(name); err != nil {
+		return "", err
+	}
+	h := http.Header{"Content-Type": {"application/octet-stream"}}
+	switch version {
+	case AnyVersion:
+	case "":
+		h.Set("If-None-Match", "*")
+	default:
+		h.Set("If-Match", version)
+	}
+	resp, err := s.do(ctx, http.MethodPut, s.prefix()+name, nil, h, data)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return resp.Header.Get("ETag"), nil
+}
+
+// Delete removes the object
+func (s *S3Store) Delete(ctx context.Context, name string) error {
+	if err := validName(name); err != nil {
+		return err
+	}
+	// S3 answers 204 also for a missing object
+	resp, err := s.do(ctx, http.MethodHead, s.prefix()+name, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	resp, err = s.do(ctx, http.MethodDelete, s.prefix()+name, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3Store) prefix() string {
+	if s.cfg.Prefix == "" {
+		return ""
+	}
+	return s.cfg.Prefix + "/"
+}
+
+// do sends the signed request, the errors of the service are returned as
+// ErrBoxNotFound, ErrConflict or with the message of the service
+func (s *S3Store) do(ctx context.Context, method, key string, query url.Values, h http.Header, body []byte) (*http.Response, error) {
+	u := *s.base
+	u.Path += key
+	u.RawQuery = strings.ReplaceAll(query.Encode(), "+", "%20")
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range h {
+		req.Header[k] = v
+	}
+	s.sign(req, body)
+	resp, err := s.cfg.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("%w: s3://%s/%s", ErrBoxNotFound, s.cfg.Bucket, key)
+	case http.StatusPreconditionFailed, http.StatusConflict:
+		return nil, fmt.Errorf("%w: s3://%s/%s", ErrConflict, s.cfg.Bucket, key)
+	}
+	var e struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if xml.Unmarshal(msg, &e) == nil && e.Code != "" {
+		return nil, fmt.Errorf("the S3 store answered %s: %s", e.Code, e.Message)
+	}
+	return nil, fmt.Errorf("the S3 store answered %s", resp.Status)
+}
+
+// sign adds the AWS Signature Version 4 of the request: the host, the
+// x-amz-* headers, the range and the conditions are signed
+func (s *S3Store) sign(req *http.Request, body []byte) {
+	now := s.now().UTC()
+	date := now.Format("20060102T150405Z")
+	day := date[:8]
+	payload := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(payload[:])
+	req.Header.Set("X-Amz-Date", date)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.cfg.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.cfg.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		k = strings.ToLower(k)
+		if strings.HasPrefix(k, "x-amz-") || k == "range" || k == "if-match" || k == "if-none-match" {
+			headers[k] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalPath(req.URL.Path),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + s.cfg.Region + "/s3/aws4_request"
+	crHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + date + "\n" + scope + "\n" + hex.EncodeToString(crHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey), day)
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKey, scope, signedHeaders, signature))
+}
+
+// canonicalPath encodes every segment of the path
+func canonicalPath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, s := range segments {
+		segments[i] = sigv4Escape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+// canonicalQuery sorts the parameters and encodes them as required by SigV4
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		vals := append([]string(nil), q[k]...)
+		sort.Strings(vals)
+		for _, v := range vals {
+			parts = append(parts, sigv4Escape(k)+"="+sigv4Escape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// sigv4Escape encodes all the bytes but the unreserved characters of RFC 3986
+func sigv4Escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,189 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// WebDAVStore keeps the boxes as the files of a WebDAV collection, the
+// version of a box is its ETag
+type WebDAVStore struct {
+	base     *url.URL
+	user     string
+	password string
+	// Client sends the requests, a client with a timeout of 30s by default
+	Client *http.Client
+}
+
+// NewWebDAVStore returns the store of the collection at the URL, the
+// credentials are sent with the basic authentication if given
+func NewWebDAVStore(rawURL, user, password string) (*WebDAVStore, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid URL %q of the WebDAV store", rawURL)
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	return &WebDAVStore{base: u, user: user, password: password, Client: &http.Client{Timeout: 30 * time.Second}}, nil
+}
+
+// String returns the URL of the collection
+func (s *WebDAVStore) String() string {
+	return s.base.String()
+}
+
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:resourcetype/><d:getcontentlength/><d:getlastmodified/><d:getetag/></d:prop></d:propfind>`
+
+type multistatus struct {
+	Responses []struct {
+		Href     string `xml:"href"`
+		Propstat []struct {
+			Prop struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"collection"`
+				} `xml:"resourcetype"`
+				ContentLength int64  `xml:"getcontentlength"`
+				LastModified  string `xml:"getlastmodified"`
+				ETag          string `xml:"getetag"`
+			} `xml:"prop"`
+			Status string `xml:"status"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+// List returns the files of the collection with a PROPFIND of depth 1, the
+// sub collections are left out
+func (s *WebDAVStore) List(ctx context.Context) ([]BoxInfo, error) {
+	h := http.Header{"Depth": {"1"}, "Content-Type": {"application/xml; charset=utf-8"}}
+	resp, err := s.do(ctx, "PROPFIND", "", h, []byte(propfindBody))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var ms multistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("failed to read the collection %s: %v", s.base, err)
+	}
+	var boxes []BoxInfo
+	for _, r := range ms.Responses {
+		href, err := url.Parse(r.Href)
+		if err != nil {
+			continue
+		}
+		name := path.Base(href.Path)
+		if strings.HasSuffix(href.Path, "/") || strings.HasPrefix(name, ".") {
+			continue
+		}
+		for _, ps := range r.Propstat {
+			if !strings.Contains(ps.Status, " 200 ") || ps.Prop.ResourceType.Collection != nil {
+				continue
+			}
+			modified, _ := http.ParseTime(ps.Prop.LastModified)
+			boxes = append(boxes, BoxInfo{Name: name, Size: ps.Prop.ContentLength, Modified: modified, Version: ps.Prop.ETag})
+		}
+	}
+	return boxes, nil
+}
+
+// Read returns the file and its ETag
+func (s *WebDAVStore) Read(ctx context.Context, name string) ([]byte, string, error) {
+	if err := validName(name); err != nil {
+		return nil, "", err
+	}
+	resp, err := s.do(ctx, http.MethodGet, name, nil, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read the box %s: %v", name, err)
+	}
+	return data, resp.Header.Get("ETag"), nil
+}
+
+// Write puts the file with If-Match (the ETag read) or If-None-Match (a new
+// box). When the server doesn't answer the PUT with the ETag, the file is read
+// back: its ETag is the new version if it's still the content written, the
+// version is empty otherwise.
+func (s *WebDAVStore) Write(ctx context.Context, name string, data []byte, version string) (string, error) {
+	if err := validName(name); err != nil {
+		return "", err
+	}
+	h := http.Header{"Content-Type": {"application/octet-stream"}}
+	switch version {
+	case AnyVersion:
+	case "":
+		h.Set("If-None-Match", "*")
+	default:
+		h.Set("If-Match", version)
+	}
+	resp, err := s.do(ctx, http.MethodPut, name, h, data)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		return etag, nil
+	}
+	current, etag, err := s.Read(ctx, name)
+	if err != nil || !bytes.Equal(current, data) {
+		return "", nil
+	}
+	return etag, nil
+}
+
+// Delete removes the file
+func (s *WebDAVStore) Delete(ctx context.Context, name string) error {
+	if err := validName(name); err != nil {
+		return err
+	}
+	resp, err := s.do(ctx, http.MethodDelete, name, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// do sends the request, the errors of the server are returned as
+// ErrBoxNotFound, ErrConflict or with the status
+func (s *WebDAVStore) do(ctx context.Context, method, name string, h http.Header, body []byte) (*http.Response, error) {
+	u := *s.base
+	u.Path += name
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range h {
+		req.Header[k] = v
+	}
+	if s.user != "" {
+		req.SetBasicAuth(s.user, s.password)
+	}
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("%w: %s", ErrBoxNotFound, u.String())
+	case http.StatusPreconditionFailed:
+		return nil, fmt.Errorf("%w: %s", ErrConflict, u.String())
+	}
+	return nil, fmt.Errorf("the WebDAV store answered %s", resp.Status)
+}
@@ -0,0 +1,381 @@
+// Package vault reads and writes raptor boxes. A Vault is an open box: its
+// secrets are kept in locked memory and given as copies by Get, the changes
+// made with Put and Delete are written by Save. It is the library the raptor
+// CLI is built on.
+//
+//	v, err := vault.Open(ctx, "/path/to/box", vault.Passphrase(pwd))
+//	if err != nil {
+//		return err
+//	}
+//	defer v.Close()
+//	s, err := v.Get(ctx, "github")
+//
+// The boxes are files, or are kept in a BoxStore (OpenIn, CreateIn): a folder,
+// an S3-compatible bucket or a WebDAV collection, see NewStore. The package
+// never reads the terminal nor exits: the key is given by a KeyProvider and the
+// failures are returned as the errors of errors.go.
+package vault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/mas2020-golang/cryptex/packages/security"
+)
+
+// Key opens a box: the password, or the age secret key of a member of a
+// shared box, and the digest of the key file for a box that requires it
+type Key struct {
+	Passphrase string
+	// KeyFile is the digest of the key file, see ReadKeyFile
+	KeyFile []byte
+}
+
+// Derive returns the key as used to encrypt the box: the passphrase combined
+// with the key file digest. The key is a new slice, to be cleared after use.
+func (k Key) Derive() ([]byte, error) {
+	if strings.HasPrefix(k.Passphrase, security.AgeIdentityPrefix) {
+		return []byte(k.Passphrase), nil
+	}
+	return security.WithKeyFile([]byte(k.Passphrase), k.KeyFile)
+}
+
+// KeyProvider gives the key of the box in src, keyFile is true when the box
+// requires a key file with the password
+type KeyProvider interface {
+	Key(ctx context.Context, src string, keyFile bool) (Key, error)
+}
+
+// KeyFunc adapts a function to a KeyProvider
+type KeyFunc func(ctx context.Context, src string, keyFile bool) (Key, error)
+
+// Key calls f
+func (f KeyFunc) Key(ctx context.Context, src string, keyFile bool) (Key, error) {
+	return f(ctx, src, keyFile)
+}
+
+// Passphrase returns a KeyProvider giving the passphrase, with the digest of
+// the key files if given (see ReadKeyFile)
+func Passphrase(passphrase string, keyFile ...[]byte) KeyProvider {
+	return KeyFunc(func(context.Context, string, bool) (Key, error) {
+		k := Key{Passphrase: passphrase}
+		if len(keyFile) > 0 {
+			k.KeyFile = keyFile[0]
+		}
+		return k, nil
+	})
+}
+
+// ReadKeyFile returns the digest of the key file to use in a Key
+func ReadKeyFile(path string) ([]byte, error) {
+	return security.ReadKeyFile(path)
+}
+
+// Vault is an open box, its methods are safe for concurrent use
+type Vault struct {
+	mu    sync.Mutex
+	store BoxStore
+	name  string
+	// version is the version of the box read from the store, Save writes
+	// the box only if it's still the one in the store
+	version string
+	// stored is true when the box is in the store, an empty version is then
+	// a version the store didn't give
+	stored bool
+	src    string
+	box    *Box
+	// key is the key of the box as given to Seal, in locked memory
+	key *security.LockedBuffer
+}
+
+// Open reads the box in the file src and decrypts it with the key given by kp
+func Open(ctx context.Context, src string, kp KeyProvider) (*Vault, error) {
+	v, err := OpenIn(ctx, NewFileStore(filepath.Dir(src)), filepath.Base(src), kp)
+	if err != nil {
+		return nil, err
+	}
+	v.src = src
+	return v, nil
+}
+
+// OpenIn reads the box from the store and decrypts it with the key given by kp
+func OpenIn(ctx context.Context, store BoxStore, name string, kp KeyProvider) (*Vault, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	data, version, err := store.Read(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	src := name
+
+	keyFile := RequiresKeyFile(data)
+	k, err := kp.Key(ctx, src, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	if keyFile && len(k.KeyFile) == 0 && !strings.HasPrefix(k.Passphrase, security.AgeIdentityPrefix) {
+		return nil, fmt.Errorf("%w: %s", ErrKeyFileRequired, src)
+	}
+	key, err := k.Derive()
+	if err != nil {
+		return nil, err
+	}
+	box, err := Unseal(data, key)
+	if err != nil {
+		clear(key)
+		return nil, fmt.Errorf("failed to open the box %s: %w", src, err)
+	}
+	v, err := newVault(store, name, version, box, key)
+	if err != nil {
+		return nil, err
+	}
+	v.stored = true
+	return v, nil
+}
+
+// Create writes the new box in the file src, it fails with ErrBoxExists if
+// src already exists. The box is protected by a key file when the key has one.
+func Create(ctx context.Context, src string, box *Box, key Key) (*Vault, error) {
+	v, err := CreateIn(ctx, NewFileStore(filepath.Dir(src)), filepath.Base(src), box, key)
+	if err != nil {
+		return nil, err
+	}
+	v.src = src
+	return v, nil
+}
+
+// CreateIn writes the new box in the store, it fails with ErrBoxExists if the
+// store already has a box with the name
+func CreateIn(ctx context.Context, store BoxStore, name string, box *Box, key Key) (*Vault, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if box.Layout == "" {
+		box.Layout = LayoutSingle
+	}
+	if err := ValidLayout(box.Layout); err != nil {
+		return nil, err
+	}
+	box.KeyFile = len(key.KeyFile) > 0
+	derived, err := key.Derive()
+	if err != nil {
+		return nil, err
+	}
+	v, err := newVault(store, name, "", box, derived)
+	if err != nil {
+		return nil, err
+	}
+	if err := v.Save(ctx); err != nil {
+		v.Close()
+		if errors.Is(err, ErrConflict) {
+			return nil, fmt.Errorf("%w: %s", ErrBoxExists, name)
+		}
+		return nil, err
+	}
+	return v, nil
+}
+
+// newVault moves the key into locked memory, key is cleared
+func newVault(store BoxStore, name, version string, box *Box, key []byte) (*Vault, error) {
+	buf, err := security.NewLockedBufferFrom(key)
+	if err != nil {
+		box.Wipe()
+		return nil, err
+	}
+	return &Vault{store: store, name: name, version: version, src: name, box: box, key: buf}, nil
+}
+
+// Source returns the path of the box, its name for a box of a store
+func (v *Vault) Source() string {
+	return v.src
+}
+
+// Version returns the version of the box in the store, as read by Open or
+// written by Save
+func (v *Vault) Version() string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.version
+}
+
+// Box returns the open box. The secrets are in locked memory until loaded,
+// call Hide on the box after reading them. The changes are written by Save.
+func (v *Vault) Box() *Box {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.box
+}
+
+// List returns the secrets of the box without their password, notes and
+// item values
+func (v *Vault) List(ctx context.Context) ([]*Secret, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if err := v.check(ctx); err != nil {
+		return nil, err
+	}
+	secrets := make([]*Secret, len(v.box.Secrets))
+	for i, s := range v.box.Secrets {
+		secrets[i] = s.metadata()
+	}
+	return secrets, nil
+}
+
+// Get returns a copy of the secret with its password, notes and items, it
+// fails with ErrSecretNotFound if the box doesn't have it
+func (v *Vault) Get(ctx context.Context, name string) (*Secret, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if err := v.check(ctx); err != nil {
+		return nil, err
+	}
+	s := v.box.Secret(name)
+	if s == nil {
+		return nil, fmt.Errorf("%w: %s", ErrSecretNotFound, name)
+	}
+	if err := s.Load(); err != nil {
+		return nil, err
+	}
+	defer s.Hide()
+	return s.clone(), nil
+}
+
+// Put adds a copy of the secret to the box, replacing the one with the same
+// name
+func (v *Vault) Put(ctx context.Context, s *Secret) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if err := v.check(ctx); err != nil {
+		return err
+	}
+	if len(s.Name) == 0 {
+		return errors.New("the secret has no name")
+	}
+	c := s.clone()
+	if err := c.Hide(); err != nil {
+		return err
+	}
+	if old := v.box.Secret(c.Name); old != nil {
+		old.Wipe()
+	}
+	v.box.Put(c)
+	return nil
+}
+
+// Delete removes the secret from the box, it fails with ErrSecretNotFound if
+// the box doesn't have it
+func (v *Vault) Delete(ctx context.Context, name string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if err := v.check(ctx); err != nil {
+		return err
+	}
+	s := v.box.Remove(name)
+	if s == nil {
+		return fmt.Errorf("%w: %s", ErrSecretNotFound, name)
+	}
+	s.Wipe()
+	return nil
+}
+
+// Share converts the box into a shared box, the key of the vault becomes the
+// one of the owner member. It returns false if the box is already shared.
+func (v *Vault) Share(ctx context.Context, owner string) (bool, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if err := v.check(ctx); err != nil {
+		return false, err
+	}
+	if v.box.Keyring != nil {
+		return false, nil
+	}
+	keyring, err := security.NewKeyring(owner, v.key.Bytes())
+	runtime.KeepAlive(v.key)
+	if err != nil {
+		return false, err
+	}
+	v.box.Keyring = keyring
+	return true, nil
+}
+
+// Save encrypts the box and writes it in its store, it fails with ErrConflict
+// if the box has been changed in the store since it was read and with
+// ErrNoVersion if the store gave no version to check
+func (v *Vault) Save(ctx context.Context) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if err := v.check(ctx); err != nil {
+		return err
+	}
+	if v.stored && v.version == "" {
+		return fmt.Errorf("%w: %s", ErrNoVersion, v.src)
+	}
+	out, err := Seal(v.box, v.key.Bytes())
+	runtime.KeepAlive(v.key)
+	if err != nil {
+		return err
+	}
+	// the secrets of a box of the single layout are loaded by Seal
+	if err := v.box.Hide(); err != nil {
+		return err
+	}
+	version, err := v.store.Write(ctx, v.name, out, v.version)
+	if err != nil {
+		return err
+	}
+	v.version, v.stored = version, true
+	return nil
+}
+
+// Close wipes the box and its key from memory, the methods of a closed vault
+// return ErrClosed
+func (v *Vault) Close() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.box.Wipe()
+	v.key.Destroy()
+	v.box, v.key = nil, nil
+	return nil
+}
+
+func (v *Vault) check(ctx context.Context) error {
+	if v.box == nil {
+		return ErrClosed
+	}
+	return ctx.Err()
+}
+
+// metadata returns a copy of the secret without its password, notes and
+// item values
+func (s *Secret) metadata() *Secret {
+	return &Secret{
+		Name:        s.Name,
+		Id:          s.Id,
+		Url:         s.Url,
+		Version:     s.Version,
+		Login:       s.Login,
+		LastUpdated: s.LastUpdated,
+		Tags:        append([]string(nil), s.Tags...),
+		items:       s.Items(),
+		metaOnly:    true,
+	}
+}
+
+// clone returns a copy of the loaded secret
+func (s *Secret) clone() *Secret {
+	c := s.metadata()
+	c.Pwd, c.Notes, c.items, c.metaOnly = s.Pwd, s.Notes, nil, false
+	if s.Others != nil {
+		c.Others = make(map[string]string, len(s.Others))
+		for k, val := range s.Others {
+			c.Others[k] = val
+		}
+	}
+	return c
+}
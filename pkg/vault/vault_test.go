@@ -0,0 +1,160 @@
+package vault
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// TestVault tests a box created, changed and opened again through the vault
+func TestVault(t *testing.T) {
+	for _, layout := range []string{LayoutSingle, LayoutIndexed} {
+		t.Run(layout, func(t *testing.T) { testVault(t, layout) })
+	}
+}
+
+func testVault(t *testing.T, layout string) {
+	ctx := context.Background()
+	src := filepath.Join(t.TempDir(), "box")
+	v, err := Create(ctx, src, &Box{Name: "test", Layout: layout}, Key{Passphrase: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Put(ctx, &Secret{Name: "github", Pwd: "pwd1", Others: map[string]string{"token": "x"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Put(ctx, &Secret{Name: "gitlab", Pwd: "pwd2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Delete(ctx, "gitlab"); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Delete(ctx, "gitlab"); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Expected ErrSecretNotFound, got %v", err)
+	}
+	if err := v.Save(ctx); err != nil {
+		t.Fatal(err)
+	}
+	v.Close()
+	if _, err := v.Get(ctx, "github"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+	if _, err := Create(ctx, src, &Box{Name: "test"}, Key{Passphrase: "secret"}); !errors.Is(err, ErrBoxExists) {
+		t.Errorf("Expected ErrBoxExists, got %v", err)
+	}
+
+	v, err = Open(ctx, src, Passphrase("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Close()
+	data, _ := os.ReadFile(src)
+	if b, err := Unseal(data, []byte("secret")); err != nil || b.Layout != layout || b.Encoding != EncodingProto {
+		t.Errorf("Expected the layout %s in protobuf, got %+v (%v)", layout, b, err)
+	}
+	list, err := v.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Name != "github" || list[0].Pwd != "" || !slices.Equal(list[0].Items(), []string{"token"}) {
+		t.Fatalf("Expected github without values, got %+v", list)
+	}
+	s, err := v.Get(ctx, "github")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Pwd != "pwd1" || s.Others["token"] != "x" {
+		t.Errorf("Expected the values of github, got %+v", s)
+	}
+	// the copy doesn't change the box
+	s.Pwd = "changed"
+	if s, _ := v.Get(ctx, "github"); s.Pwd != "pwd1" {
+		t.Errorf("Expected pwd1, got %s", s.Pwd)
+	}
+	if _, err := v.Get(ctx, "gitlab"); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Expected ErrSecretNotFound, got %v", err)
+	}
+}
+
+// TestOpen_Errors tests the errors opening a box
+func TestOpen_Errors(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	if _, err := Open(ctx, filepath.Join(dir, "missing"), Passphrase("secret")); !errors.Is(err, ErrBoxNotFound) {
+		t.Errorf("Expected ErrBoxNotFound, got %v", err)
+	}
+
+	src := filepath.Join(dir, "box")
+	v, err := Create(ctx, src, &Box{Name: "test"}, Key{Passphrase: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.Close()
+	if _, err := Open(ctx, src, Passphrase("wrong")); !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("Expected ErrWrongPassword, got %v", err)
+	}
+
+	// a box with a key file
+	digest := []byte("0123456789abcdef0123456789abcdef")
+	src = filepath.Join(dir, "kf")
+	if v, err = Create(ctx, src, &Box{Name: "kf"}, Key{Passphrase: "secret", KeyFile: digest}); err != nil {
+		t.Fatal(err)
+	}
+	v.Close()
+	if _, err := Open(ctx, src, Passphrase("secret")); !errors.Is(err, ErrKeyFileRequired) {
+		t.Errorf("Expected ErrKeyFileRequired, got %v", err)
+	}
+	if v, err = Open(ctx, src, Passphrase("secret", digest)); err != nil {
+		t.Fatal(err)
+	}
+	v.Close()
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := Open(canceled, src, Passphrase("secret", digest)); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+// TestVault_Share tests a box shared with its password as the owner member
+func TestVault_Share(t *testing.T) {
+	ctx := context.Background()
+	src := filepath.Join(t.TempDir(), "box")
+	v, err := Create(ctx, src, &Box{Name: "test"}, Key{Passphrase: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Close()
+	if shared, err := v.Share(ctx, "owner"); err != nil || !shared {
+		t.Fatalf("Expected the box shared, got %v (%v)", shared, err)
+	}
+	if shared, err := v.Share(ctx, "other"); err != nil || shared {
+		t.Errorf("Expected the box already shared, got %v (%v)", shared, err)
+	}
+	if err := v.Save(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	o, err := Open(ctx, src, Passphrase("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+	if k := o.Box().Keyring; k == nil || k.Member("owner") == nil || k.Unlocked() != "owner" {
+		t.Errorf("Expected the box opened by the owner member, got %+v", k)
+	}
+}
+
+// TestUnseal_Corrupt tests that a truncated box is reported as corrupt
+func TestUnseal_Corrupt(t *testing.T) {
+	out, err := Seal(&Box{Name: "test", Layout: LayoutIndexed, Secrets: []*Secret{{Name: "github", Pwd: "pwd"}}}, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Unseal(out[:len(out)-8], []byte("secret")); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Expected ErrCorrupt, got %v", err)
+	}
+}